@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/clouddrove/smurf/internal/output"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+// configCmd groups commands for inspecting the smurf configuration file
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the smurf configuration file",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println("Use 'smurf config [command]' to inspect the smurf configuration")
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the configuration merged with the selected environment profile",
+	Example: `  smurf config show
+  smurf config show --env prod`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if output.Structured() {
+			return output.Print(loadedConfig.Settings)
+		}
+
+		if loadedConfig.Path == "" {
+			pterm.Warning.Println("No smurf.yaml found in the current directory; use --config to point at one.")
+			return nil
+		}
+
+		data, err := yaml.Marshal(loadedConfig.Settings)
+		if err != nil {
+			return fmt.Errorf("failed to render configuration: %w", err)
+		}
+
+		if envName != "" {
+			pterm.Info.Printf("Configuration from %s with environment '%s'\n", loadedConfig.Path, envName)
+		} else {
+			pterm.Info.Printf("Configuration from %s\n", loadedConfig.Path)
+		}
+		fmt.Print(string(data))
+
+		if envName == "" && len(environments) > 0 {
+			pterm.Info.Printf("Available environments: %s (select one with --env or SMURF_ENV)\n", strings.Join(environments, ", "))
+		}
+		return nil
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configShowCmd)
+	RootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,260 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/clouddrove/smurf/internal/output"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+// Flags for the deploy command
+var (
+	deployImageName      string
+	deployImageTag       string
+	deployContextDir     string
+	deployDockerfilePath string
+	deployNoCache        bool
+	deployBuildArgs      []string
+	deployTarget         string
+	deployPlatform       string
+	deployBuildKit       bool
+	deploySecrets        []string
+	deploySSH            []string
+	deployCacheFrom      []string
+	deployCacheTo        []string
+	deployLabels         []string
+
+	deployRegistry     string
+	deployRegistryOpts docker.RegistryOptions
+	deployRepository   string
+
+	deployNamespace       string
+	deploySetValues       []string
+	deploySetStringValues []string
+	deployValuesFiles     []string
+	deployCreateNamespace bool
+	deployAtomic          bool
+	deployTimeout         time.Duration
+	deployInstall         bool
+	deployKubeContext     string
+
+	deployRepositoryKey string
+	deployTagKey        string
+	deployDigestKey     string
+)
+
+// deployStage records the outcome of one step of the deploy pipeline
+type deployStage struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Duration string `json:"duration,omitempty"`
+}
+
+// deployPipeline runs stages in order and skips everything after the first failure
+type deployPipeline struct {
+	stages []deployStage
+	failed bool
+}
+
+func (p *deployPipeline) run(name string, fn func() (string, error)) {
+	if p.failed {
+		p.stages = append(p.stages, deployStage{Name: name, Status: "skipped"})
+		return
+	}
+
+	pterm.DefaultSection.Println(strings.ToUpper(name[:1]) + name[1:])
+	start := time.Now()
+	detail, err := fn()
+	stage := deployStage{Name: name, Status: "succeeded", Detail: detail, Duration: time.Since(start).Round(time.Millisecond).String()}
+	if err != nil {
+		p.failed = true
+		stage.Status = "failed"
+		stage.Detail = err.Error()
+	}
+	p.stages = append(p.stages, stage)
+}
+
+func (p *deployPipeline) printSummary() {
+	data := pterm.TableData{{"Stage", "Status", "Duration", "Detail"}}
+	for _, stage := range p.stages {
+		status := stage.Status
+		switch status {
+		case "succeeded":
+			status = pterm.Green(status)
+		case "failed":
+			status = pterm.Red(status)
+		default:
+			status = pterm.Gray(status)
+		}
+		data = append(data, []string{stage.Name, status, stage.Duration, stage.Detail})
+	}
+	pterm.DefaultSection.Println("Deploy summary")
+	pterm.DefaultTable.WithHasHeader().WithWriter(output.Writer()).WithData(data).Render()
+}
+
+var deployCmd = &cobra.Command{
+	Use:   "deploy [RELEASE] [CHART]",
+	Short: "Build and push an image, then upgrade a Helm release to run it",
+	Long: `Build an image from a Dockerfile, push it to the selected registry and upgrade a
+Helm release with the pushed image repository, tag and digest set at the given
+value keys. The pipeline stops at the first failing stage and prints a summary
+of every stage.`,
+	Example: `  smurf deploy myapp ./chart --image-name myapp --tag v1.2.0 --registry ecr --region us-east-1 --repository myapp
+  smurf deploy myapp ./chart -i myuser/myapp -t v1.2.0 --registry hub --image-digest-key image.digest`,
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		releaseName, chartPath := args[0], args[1]
+
+		reg, err := docker.NewRegistry(deployRegistry, deployRegistryOpts)
+		if err != nil {
+			return err
+		}
+		helm.SetKubeContext(deployKubeContext)
+
+		localImage := fmt.Sprintf("%s:%s", deployImageName, deployImageTag)
+		pipeline := &deployPipeline{}
+		var pushed *docker.PushResult
+		var summary *helm.ReleaseSummary
+
+		pipeline.run("build", func() (string, error) {
+			buildArgsMap := make(map[string]string)
+			for _, arg := range deployBuildArgs {
+				parts := strings.SplitN(arg, "=", 2)
+				if len(parts) == 2 {
+					buildArgsMap[parts[0]] = parts[1]
+				}
+			}
+			labelsMap := make(map[string]string)
+			for _, label := range deployLabels {
+				parts := strings.SplitN(label, "=", 2)
+				if len(parts) == 2 {
+					labelsMap[parts[0]] = parts[1]
+				}
+			}
+			buildOpts := docker.BuildOptions{
+				ContextDir:     deployContextDir,
+				DockerfilePath: deployDockerfilePath,
+				NoCache:        deployNoCache,
+				BuildArgs:      buildArgsMap,
+				Target:         deployTarget,
+				Platform:       deployPlatform,
+				BuildKit:       deployBuildKit,
+				Secrets:        deploySecrets,
+				SSH:            deploySSH,
+				CacheFrom:      deployCacheFrom,
+				CacheTo:        deployCacheTo,
+				Labels:         labelsMap,
+			}
+			if err := docker.Build(cmd.Context(), deployImageName, deployImageTag, buildOpts); err != nil {
+				return "", err
+			}
+			return localImage, nil
+		})
+
+		pipeline.run("push", func() (string, error) {
+			result, err := docker.PushPlatforms(cmd.Context(), reg, localImage, deployRepository, docker.Platforms(deployPlatform))
+			if err != nil {
+				return "", err
+			}
+			pushed = result
+			if result.Digest != "" {
+				return result.Image + "@" + result.Digest, nil
+			}
+			return result.Image, nil
+		})
+
+		pipeline.run("upgrade", func() (string, error) {
+			repository, tag := docker.SplitReference(pushed.Image)
+			if tag == "" {
+				tag = deployImageTag
+			}
+			imageValues := []string{
+				fmt.Sprintf("%s=%s", deployRepositoryKey, repository),
+				fmt.Sprintf("%s=%s", deployTagKey, tag),
+			}
+			if deployDigestKey != "" && pushed.Digest != "" {
+				imageValues = append(imageValues, fmt.Sprintf("%s=%s", deployDigestKey, pushed.Digest))
+			}
+			for _, value := range imageValues {
+				pterm.Info.Println("Setting", value)
+			}
+
+			setStringValues := append(append([]string{}, deploySetStringValues...), imageValues...)
+			rel, err := helm.HelmUpgrade(cmd.Context(), releaseName, chartPath, deployNamespace, helm.UpgradeOptions{
+				ValuesFiles:     deployValuesFiles,
+				SetValues:       deploySetValues,
+				SetStringValues: setStringValues,
+				CreateNamespace: deployCreateNamespace,
+				Atomic:          deployAtomic,
+				Install:         deployInstall,
+				Timeout:         deployTimeout,
+			})
+			if err != nil {
+				return "", err
+			}
+			s := helm.Summarize(rel)
+			summary = &s
+			return fmt.Sprintf("release %s revision %d in namespace %s", rel.Name, rel.Version, rel.Namespace), nil
+		})
+
+		pipeline.printSummary()
+
+		if err := output.Print(map[string]interface{}{
+			"stages":  pipeline.stages,
+			"image":   pushed,
+			"release": summary,
+		}); err != nil {
+			return err
+		}
+
+		if pipeline.failed {
+			return fmt.Errorf("deploy failed")
+		}
+		return nil
+	},
+}
+
+func init() {
+	deployCmd.Flags().StringVarP(&deployImageName, "image-name", "i", "", "Name of the image to build")
+	deployCmd.Flags().StringVarP(&deployImageTag, "tag", "t", "latest", "Tag for the image")
+	deployCmd.Flags().StringVar(&deployContextDir, "context", "", "Build context directory (default is the directory of the Dockerfile)")
+	deployCmd.Flags().StringVar(&deployDockerfilePath, "file", "", "Path to the Dockerfile, or - to read it from stdin (default CONTEXT/Dockerfile)")
+	deployCmd.Flags().BoolVar(&deployNoCache, "no-cache", false, "Do not use cache when building the image")
+	deployCmd.Flags().StringArrayVar(&deployBuildArgs, "build-arg", []string{}, "Set build-time variables")
+	deployCmd.Flags().StringArrayVar(&deployLabels, "label", []string{}, "Set metadata on the image (key=value), in addition to the standard OCI labels")
+	deployCmd.Flags().StringVar(&deployTarget, "target", "", "Set the target build stage to build")
+	deployCmd.Flags().StringVar(&deployPlatform, "platform", "", "Platforms for the build; several (e.g., linux/amd64,linux/arm64) push one multi-platform image")
+	deployCmd.Flags().BoolVar(&deployBuildKit, "buildkit", false, "Build with BuildKit through docker buildx")
+	deployCmd.Flags().StringArrayVar(&deploySecrets, "secret", []string{}, "Secret to expose to the build (id=NAME,src=FILE or id=NAME,env=VAR)")
+	deployCmd.Flags().StringArrayVar(&deploySSH, "ssh", []string{}, "SSH agent socket or keys to forward to the build (default or ID=PATH)")
+	deployCmd.Flags().StringArrayVar(&deployCacheFrom, "cache-from", []string{}, "External cache sources (type=registry,ref=REF or type=local,src=DIR)")
+	deployCmd.Flags().StringArrayVar(&deployCacheTo, "cache-to", []string{}, "Cache export destinations (type=registry,ref=REF or type=local,dest=DIR)")
+
+	AddRegistryFlags(deployCmd, &deployRegistry, &deployRegistryOpts)
+	deployCmd.Flags().StringVarP(&deployRepository, "repository", "R", "", "Remote repository name (defaults to the image name)")
+
+	deployCmd.Flags().StringVarP(&deployNamespace, "namespace", "n", "default", "Namespace of the release")
+	deployCmd.Flags().StringSliceVar(&deploySetValues, "set", []string{}, "Set values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
+	deployCmd.Flags().StringSliceVar(&deploySetStringValues, "set-string", []string{}, "Set STRING values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
+	deployCmd.Flags().StringSliceVar(&deployValuesFiles, "values", []string{}, "Specify values in a YAML file (can specify multiple)")
+	deployCmd.Flags().BoolVar(&deployCreateNamespace, "create-namespace", false, "Create the namespace if it does not exist")
+	deployCmd.Flags().BoolVar(&deployAtomic, "atomic", false, "Roll back the upgrade if it fails")
+	deployCmd.Flags().DurationVar(&deployTimeout, "timeout", 300*time.Second, "Time to wait for any individual Kubernetes operation (like Jobs for hooks)")
+	deployCmd.Flags().BoolVar(&deployInstall, "install", false, "Install the chart if it is not already installed")
+	deployCmd.Flags().StringVar(&deployKubeContext, "kube-context", "", "Name of the kubeconfig context to use")
+
+	deployCmd.Flags().StringVar(&deployRepositoryKey, "image-repository-key", "image.repository", "Values key that receives the pushed image repository")
+	deployCmd.Flags().StringVar(&deployTagKey, "image-tag-key", "image.tag", "Values key that receives the pushed image tag")
+	deployCmd.Flags().StringVar(&deployDigestKey, "image-digest-key", "", "Values key that receives the pushed image digest (not set when empty)")
+
+	deployCmd.MarkFlagRequired("image-name")
+
+	RootCmd.AddCommand(deployCmd)
+}
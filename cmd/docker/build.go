@@ -4,21 +4,34 @@ import (
 	"strings"
 
 	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/spf13/cobra"
 )
 
 var (
+	contextDir     string
 	dockerfilePath string
 	noCache        bool
 	buildArgs      []string
 	target         string
 	platform       string 
+	buildKit       bool
+	secrets        []string
+	sshForwards    []string
+	cacheFrom      []string
+	cacheTo        []string
+	tagStrategy    string
+	labels         []string
 )
 
 var buildCmd = &cobra.Command{
-	Use:   "build [IMAGE_NAME] [TAG]",
+	Use:   "build IMAGE_NAME [TAG]",
 	Short: "Build a Docker image with the given name and tag.",
-	Args:  cobra.ExactArgs(2),
+	Example: `  smurf sdkr build myapp v1.0.0
+  smurf sdkr build myapp v1.0.0 --context . -f docker/Dockerfile.api
+  cat Dockerfile | smurf sdkr build myapp v1.0.0 --context . -f -
+  smurf sdkr build myapp --tag-strategy sha,branch,semver`,
+	Args:  cobra.RangeArgs(1, 2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		buildArgsMap := make(map[string]string)
 		for _, arg := range buildArgs {
@@ -28,24 +41,60 @@ var buildCmd = &cobra.Command{
 			}
 		}
 
+		labelsMap := make(map[string]string)
+		for _, label := range labels {
+			parts := strings.SplitN(label, "=", 2)
+			if len(parts) == 2 {
+				labelsMap[parts[0]] = parts[1]
+			}
+		}
+
+		tag := ""
+		if len(args) == 2 {
+			tag = args[1]
+		}
+		tags, err := resolveTags(cmd.Context(), tag, tagStrategy, contextDir)
+		if err != nil {
+			return err
+		}
+
 		opts := docker.BuildOptions{
+			ContextDir:     contextDir,
 			DockerfilePath: dockerfilePath,
 			NoCache:        noCache,
 			BuildArgs:      buildArgsMap,
 			Target:         target,
 			Platform:       platform, 
+			BuildKit:       buildKit,
+			Secrets:        secrets,
+			SSH:            sshForwards,
+			CacheFrom:      cacheFrom,
+			CacheTo:        cacheTo,
+			AdditionalTags: tags[1:],
+			Labels:         labelsMap,
 		}
 
-		return docker.Build(args[0], args[1], opts)
+		if err := docker.Build(cmd.Context(), args[0], tags[0], opts); err != nil {
+			return err
+		}
+		return output.Print(map[string]interface{}{"image": args[0] + ":" + tags[0], "tags": tags})
 	},
 }
 
 func init() {
-	buildCmd.Flags().StringVarP(&dockerfilePath, "file", "f", "Dockerfile", "Name of the Dockerfile (Default is 'Dockerfile')")
+	buildCmd.Flags().StringVar(&contextDir, "context", "", "Build context directory (default is the directory of the Dockerfile)")
+	buildCmd.Flags().StringVarP(&dockerfilePath, "file", "f", "", "Path to the Dockerfile, or - to read it from stdin (default CONTEXT/Dockerfile)")
 	buildCmd.Flags().BoolVar(&noCache, "no-cache", false, "Do not use cache when building the image")
 	buildCmd.Flags().StringArrayVar(&buildArgs, "build-arg", []string{}, "Set build-time variables")
+	buildCmd.Flags().StringArrayVar(&labels, "label", []string{}, "Set metadata on the image (key=value), in addition to the standard OCI labels")
 	buildCmd.Flags().StringVar(&target, "target", "", "Set the target build stage to build")
-	buildCmd.Flags().StringVar(&platform, "platform", "", "Set the platform for the build (e.g., linux/amd64, linux/arm64)")
+	buildCmd.Flags().StringVar(&platform, "platform", "", "Platforms for the build; several (e.g., linux/amd64,linux/arm64) build one image per platform")
+	buildCmd.Flags().StringVar(&tagStrategy, "tag-strategy", "", "Derive tags from git: any of sha, branch and semver, comma separated")
+	buildCmd.Flags().BoolVar(&buildKit, "buildkit", false, "Build with BuildKit through docker buildx")
+	buildCmd.Flags().StringArrayVar(&secrets, "secret", []string{}, "Secret to expose to the build (id=NAME,src=FILE or id=NAME,env=VAR)")
+	buildCmd.Flags().StringArrayVar(&sshForwards, "ssh", []string{}, "SSH agent socket or keys to forward to the build (default or ID=PATH)")
+	buildCmd.Flags().StringArrayVar(&cacheFrom, "cache-from", []string{}, "External cache sources (type=registry,ref=REF or type=local,src=DIR)")
+	buildCmd.Flags().StringArrayVar(&cacheTo, "cache-to", []string{}, "Cache export destinations (type=registry,ref=REF or type=local,dest=DIR)")
 
 	sdkrCmd.AddCommand(buildCmd)
 }
\ No newline at end of file
@@ -0,0 +1,97 @@
+package docker
+
+import (
+	"fmt"
+
+	"github.com/clouddrove/smurf/cmd"
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/dryrun"
+	"github.com/clouddrove/smurf/internal/output"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cleanupRegistry     string
+	cleanupRegistryOpts docker.RegistryOptions
+	cleanupKeepLast     int
+	cleanupKeepTags     []string
+	cleanupOlderThan    string
+	cleanupUntagged     bool
+	cleanupYes          bool
+)
+
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup REPOSITORY",
+	Short: "Delete old images from a remote repository according to retention rules.",
+	Long: `Delete old images from a repository in ECR, ACR, GCR, Docker Hub or a generic registry.
+
+A tagged image is deleted only when every rule that is set allows it:
+  --keep-last N      it is not among the N most recently pushed images
+  --keep-tag REGEX   none of its tags match a pattern (repeatable)
+  --older-than AGE   it was pushed more than AGE ago, e.g. 30d or 12h
+Untagged images are deleted with --untagged, unless they are younger than
+--older-than. Platform images of a multi-platform image, and signatures, SBOMs
+and other referrers of an image, are never selected on their own: they are kept
+with the image they belong to and deleted with it.
+
+The images to delete are listed first and deleted after confirmation. Use --yes
+to skip the confirmation, or --dry-run to only preview.`,
+	Example: `  smurf sdkr cleanup myapp --registry ecr --region us-east-1 --keep-last 20 --untagged
+  smurf sdkr cleanup myapp --registry ecr --region us-east-1 --older-than 90d --keep-tag '^v\d+\.\d+\.\d+$' -y
+  smurf sdkr cleanup myorg/myapp --registry hub --keep-last 10 --dry-run`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		reg, err := docker.NewRegistry(cleanupRegistry, cleanupRegistryOpts)
+		if err != nil {
+			return err
+		}
+		policy := docker.RetentionPolicy{
+			KeepLast: cleanupKeepLast,
+			KeepTags: cleanupKeepTags,
+			Untagged: cleanupUntagged,
+		}
+		if cleanupOlderThan != "" {
+			if policy.OlderThan, err = docker.ParseAge(cleanupOlderThan); err != nil {
+				return err
+			}
+		}
+
+		plan, err := docker.PlanCleanup(cmd.Context(), reg, args[0], policy)
+		if err != nil {
+			return err
+		}
+		docker.PrintCleanupPlan(plan)
+		if len(plan.Delete) == 0 {
+			return output.Print(plan)
+		}
+
+		confirmed := cleanupYes || dryrun.Enabled()
+		if !confirmed {
+			confirmed, _ = pterm.DefaultInteractiveConfirm.
+				WithDefaultText(fmt.Sprintf("Delete %d images from %s?", len(plan.Delete), args[0])).
+				Show()
+		}
+		if !confirmed {
+			pterm.Info.Println("Cleanup cancelled, nothing was deleted.")
+			return output.Print(plan)
+		}
+
+		if err := docker.ApplyCleanup(cmd.Context(), reg, plan); err != nil {
+			return err
+		}
+		return output.Print(plan)
+	},
+}
+
+func init() {
+	cleanupCmd.Flags().IntVar(&cleanupKeepLast, "keep-last", 0, "Keep the N most recently pushed tagged images")
+	cleanupCmd.Flags().StringArrayVar(&cleanupKeepTags, "keep-tag", []string{}, "Keep images with a tag matching this regular expression")
+	cleanupCmd.Flags().StringVar(&cleanupOlderThan, "older-than", "", "Only delete images pushed longer ago than this, e.g. 30d or 12h")
+	cleanupCmd.Flags().BoolVar(&cleanupUntagged, "untagged", false, "Delete untagged images")
+	cleanupCmd.Flags().BoolVarP(&cleanupYes, "yes", "y", false, "Delete without confirmation")
+	cmd.AddRegistryFlags(cleanupCmd, &cleanupRegistry, &cleanupRegistryOpts)
+
+	sdkrCmd.AddCommand(cleanupCmd)
+}
@@ -0,0 +1,74 @@
+package docker
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/output"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	inspectRemote   bool
+	inspectPlatform string
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect IMAGE",
+	Short: "Show the labels of a local or remote image, such as the commit it was built from.",
+	Example: `  smurf sdkr inspect myapp:v1.0.0
+  smurf sdkr inspect --remote 123456789012.dkr.ecr.us-east-1.amazonaws.com/myapp:v1.0.0
+  smurf sdkr inspect --remote ghcr.io/myorg/myapp:v1.0.0 --platform linux/arm64`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var info *docker.ImageInfo
+		var err error
+		if inspectRemote {
+			info, err = docker.InspectRemoteImage(cmd.Context(), args[0], inspectPlatform)
+		} else {
+			info, err = docker.InspectImage(cmd.Context(), args[0])
+		}
+		if err != nil {
+			return err
+		}
+
+		if output.Structured() {
+			return output.Print(info)
+		}
+
+		data := pterm.TableData{{"Field", "Value"}}
+		data = append(data, []string{"Image", info.Image})
+		if info.Digest != "" {
+			data = append(data, []string{"Digest", info.Digest})
+		}
+		data = append(data, []string{"Platform", info.Platform}, []string{"Created", info.Created})
+		pterm.DefaultTable.WithHasHeader().WithWriter(output.Writer()).WithData(data).Render()
+
+		if len(info.Labels) == 0 {
+			pterm.Warning.Println("The image has no labels")
+			return nil
+		}
+		keys := make([]string, 0, len(info.Labels))
+		for key := range info.Labels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		labels := pterm.TableData{{"Label", "Value"}}
+		for _, key := range keys {
+			labels = append(labels, []string{key, info.Labels[key]})
+		}
+		fmt.Fprintln(output.Writer())
+		pterm.DefaultTable.WithHasHeader().WithWriter(output.Writer()).WithData(labels).Render()
+		return nil
+	},
+}
+
+func init() {
+	inspectCmd.Flags().BoolVar(&inspectRemote, "remote", false, "Read the image from its registry instead of the local Docker daemon")
+	inspectCmd.Flags().StringVar(&inspectPlatform, "platform", "", "Platform to read from a multi-platform image (default linux on the host architecture)")
+
+	sdkrCmd.AddCommand(inspectCmd)
+}
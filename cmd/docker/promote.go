@@ -0,0 +1,51 @@
+package docker
+
+import (
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var promoteRegistryOpts docker.RegistryOptions
+
+var promoteCmd = &cobra.Command{
+	Use:   "promote SRC DEST",
+	Short: "Copy an image from one registry to another without pulling it.",
+	Long: `Copy an image from one registry to another, directly between the registries.
+
+Nothing is pulled into the local Docker daemon. Manifests are copied unchanged,
+so the digest stays the same and a multi-platform image keeps all of its
+platforms. Blobs that already exist at the destination are skipped. When DEST
+has no tag, the tag or digest of SRC is used.
+
+Each registry is recognized by its host and authenticated the same way as the
+matching push command:
+  - ECR (ACCOUNT.dkr.ecr.REGION.amazonaws.com): the AWS credential chain
+  - ACR (NAME.azurecr.io): Azure credentials with --subscription-id and
+    --resource-group, or the Docker config (az acr login)
+  - GCR and Artifact Registry: Google application default credentials
+  - Docker Hub: DOCKER_USERNAME and DOCKER_PASSWORD, or the Docker config
+  - any other registry: REGISTRY_USERNAME, REGISTRY_PASSWORD or REGISTRY_TOKEN,
+    or the Docker config`,
+	Example: `  smurf sdkr promote 111111111111.dkr.ecr.us-east-1.amazonaws.com/myapp:v1.0.0 222222222222.dkr.ecr.us-east-1.amazonaws.com/myapp
+  smurf sdkr promote 111111111111.dkr.ecr.us-east-1.amazonaws.com/myapp:v1.0.0 customer.azurecr.io/myapp:v1.0.0 \
+    --subscription-id $AZURE_SUBSCRIPTION_ID --resource-group customer-rg
+  smurf sdkr promote localhost:5000/myapp:rc1 localhost:5001/myapp:v1.0.0`,
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		promotion, err := docker.PromoteImage(cmd.Context(), args[0], args[1], promoteRegistryOpts)
+		if err != nil {
+			return err
+		}
+		return output.Print(promotion)
+	},
+}
+
+func init() {
+	promoteCmd.Flags().StringVar(&promoteRegistryOpts.SubscriptionID, "subscription-id", "", "Azure subscription ID of an ACR source or destination")
+	promoteCmd.Flags().StringVar(&promoteRegistryOpts.ResourceGroup, "resource-group", "", "Azure resource group of an ACR source or destination")
+	promoteCmd.Flags().BoolVar(&promoteRegistryOpts.Insecure, "insecure", false, "Use plain HTTP for registries that are not on a local address")
+
+	sdkrCmd.AddCommand(promoteCmd)
+}
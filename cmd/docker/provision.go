@@ -0,0 +1,331 @@
+package docker
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/clouddrove/smurf/cmd"
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/output"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+// Flags for the provision command
+var (
+	provisionImageName       string
+	provisionImageTag        string
+	provisionContextDir      string
+	provisionDockerfilePath  string
+	provisionNoCache         bool
+	provisionBuildArgs       []string
+	provisionTarget          string
+	provisionSarifFile       string
+	provisionFailOn          string
+	provisionIgnoreFile      string
+	provisionScanner         string
+	provisionSBOM            string
+	provisionSBOMFile        string
+	provisionSBOMAttach      bool
+	provisionTargetTag       string
+	provisionConfirmPush     bool
+	provisionDeleteAfterPush bool
+	provisionPlatform        string
+	provisionBuildKit        bool
+	provisionSecrets         []string
+	provisionSSH             []string
+	provisionCacheFrom       []string
+	provisionCacheTo         []string
+	provisionRegistry        string
+	provisionRegistryOpts    docker.RegistryOptions
+	provisionRepository      string
+	provisionTagStrategy     string
+	provisionLabels          []string
+	provisionDigestFile      string
+	provisionSign            bool
+	provisionSignKey         string
+)
+
+var provisionCmd = &cobra.Command{
+	Use:   "provision",
+	Short: "Build, scan, tag, and push a Docker image to a registry.",
+	Example: `  smurf sdkr provision -i myapp -t v1.0.0 --registry hub -y
+  smurf sdkr provision -i myapp -t v1.0.0 --registry ecr --region us-east-1 --repository myapp -y
+  smurf sdkr provision -i myapp -t v1.0.0 --registry generic --registry-url registry.example.com -y
+  smurf sdkr provision -i myapp --tag-strategy sha,branch --registry ecr --region us-east-1 -y`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runProvision(cmd)
+	},
+}
+
+func runProvision(cmd *cobra.Command) error {
+	reg, err := docker.NewRegistry(provisionRegistry, provisionRegistryOpts)
+	if err != nil {
+		return err
+	}
+	if err := docker.ValidateSeverity(provisionFailOn); err != nil {
+		return err
+	}
+	if _, err := docker.NewScanner(provisionScanner); err != nil {
+		return err
+	}
+	if err := docker.ValidateSBOMFormat(provisionSBOM); err != nil {
+		return err
+	}
+	if provisionSBOMAttach && provisionSBOM == "" {
+		return fmt.Errorf("--sbom-attach needs an SBOM format in --sbom")
+	}
+	signKey, err := loadSignKey(provisionSign, provisionSignKey)
+	if err != nil {
+		return err
+	}
+
+	imageTag := provisionImageTag
+	if provisionTagStrategy != "" && !cmd.Flags().Changed("tag") {
+		// The default tag 'latest' is only used without a strategy
+		imageTag = ""
+	}
+	tags, err := resolveTags(cmd.Context(), imageTag, provisionTagStrategy, provisionContextDir)
+	if err != nil {
+		return err
+	}
+	fullImageName := fmt.Sprintf("%s:%s", provisionImageName, tags[0])
+
+	buildArgsMap := make(map[string]string)
+	for _, arg := range provisionBuildArgs {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) == 2 {
+			buildArgsMap[parts[0]] = parts[1]
+		}
+	}
+
+	labelsMap := make(map[string]string)
+	for _, label := range provisionLabels {
+		parts := strings.SplitN(label, "=", 2)
+		if len(parts) == 2 {
+			labelsMap[parts[0]] = parts[1]
+		}
+	}
+
+	buildOpts := docker.BuildOptions{
+		ContextDir:     provisionContextDir,
+		DockerfilePath: provisionDockerfilePath,
+		NoCache:        provisionNoCache,
+		BuildArgs:      buildArgsMap,
+		Target:         provisionTarget,
+		Platform:       provisionPlatform,
+		BuildKit:       provisionBuildKit,
+		Secrets:        provisionSecrets,
+		SSH:            provisionSSH,
+		CacheFrom:      provisionCacheFrom,
+		CacheTo:        provisionCacheTo,
+		AdditionalTags: tags[1:],
+		Labels:         labelsMap,
+	}
+
+	pterm.Info.Println("Starting build...")
+	if err := docker.Build(cmd.Context(), provisionImageName, tags[0], buildOpts); err != nil {
+		pterm.Error.Println("Build failed:", err)
+		return err
+	}
+	pterm.Success.Println("Build completed successfully.")
+
+	var wg sync.WaitGroup
+	var scanErr, tagErr error
+
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		pterm.Info.Println("Starting scan...")
+		scanOpts := docker.ScanOptions{
+			SarifFile:  provisionSarifFile,
+			FailOn:     provisionFailOn,
+			IgnoreFile: provisionIgnoreFile,
+			Scanner:    provisionScanner,
+		}
+		_, scanErr = docker.Scan(cmd.Context(), fullImageName, scanOpts)
+		if scanErr != nil {
+			pterm.Error.Println("Scan failed, the image will not be pushed:", scanErr)
+		} else {
+			pterm.Success.Println("Scan completed successfully.")
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		if provisionTargetTag != "" {
+			pterm.Info.Printf("Tagging image as %s...\n", provisionTargetTag)
+			tagOpts := docker.TagOptions{
+				Source: fullImageName,
+				Target: provisionTargetTag,
+			}
+			tagErr = docker.TagImage(cmd.Context(), tagOpts)
+			if platforms := docker.Platforms(provisionPlatform); tagErr == nil && len(platforms) > 1 {
+				for _, platform := range platforms {
+					tagOpts := docker.TagOptions{
+						Source: docker.PlatformReference(fullImageName, platform),
+						Target: docker.PlatformReference(provisionTargetTag, platform),
+					}
+					if tagErr = docker.TagImage(cmd.Context(), tagOpts); tagErr != nil {
+						break
+					}
+				}
+			}
+			if tagErr != nil {
+				pterm.Error.Println("Tagging failed:", tagErr)
+			} else {
+				pterm.Success.Println("Tagging completed successfully.")
+			}
+		}
+	}()
+
+	wg.Wait()
+
+	if scanErr != nil || tagErr != nil {
+		return fmt.Errorf("provisioning failed due to previous errors")
+	}
+
+	var sbom *docker.SBOM
+	if provisionSBOM != "" {
+		if sbom, err = docker.CreateSBOM(cmd.Context(), fullImageName, provisionSBOM, provisionSBOMFile); err != nil {
+			pterm.Error.Println("SBOM generation failed:", err)
+			return err
+		}
+	}
+
+	pushImages := make([]string, len(tags))
+	for i, tag := range tags {
+		pushImages[i] = fmt.Sprintf("%s:%s", provisionImageName, tag)
+	}
+	if provisionTargetTag != "" {
+		pushImages[0] = provisionTargetTag
+	}
+
+	push := provisionConfirmPush
+	if !push {
+		push, _ = pterm.DefaultInteractiveConfirm.
+			WithDefaultText(fmt.Sprintf("Do you want to push the image to %s?", reg.Name())).
+			Show()
+	}
+
+	var pushResult *docker.PushResult
+	if push {
+		for _, pushImage := range pushImages {
+			pterm.Info.Printf("Pushing image %s to %s...\n", pushImage, reg.Name())
+			result, err := docker.PushPlatforms(cmd.Context(), reg, pushImage, provisionRepository, docker.Platforms(provisionPlatform))
+			if err != nil {
+				pterm.Error.Println("Push failed:", err)
+				return err
+			}
+			if pushResult == nil {
+				pushResult = result
+			}
+		}
+		if provisionDigestFile != "" {
+			if err := docker.WriteDigestFile(provisionDigestFile, pushResult); err != nil {
+				return err
+			}
+		}
+		pterm.Success.Println("Push completed successfully.")
+		if err := signPushed(cmd.Context(), reg, pushResult, signKey); err != nil {
+			return err
+		}
+		if provisionSBOMAttach {
+			subject := pushResult.Reference
+			if subject == "" {
+				subject = pushResult.Image
+			}
+			if err := docker.AttachSBOM(cmd.Context(), reg, subject, sbom); err != nil {
+				pterm.Error.Println("Failed to attach the SBOM:", err)
+				return err
+			}
+		}
+	} else {
+		pterm.Info.Println("Image push skipped.")
+		if provisionSBOMAttach {
+			pterm.Warning.Println("The SBOM was not attached because the image was not pushed.")
+		}
+		if provisionSign {
+			pterm.Warning.Println("The image was not signed because it was not pushed.")
+		}
+	}
+
+	if provisionDeleteAfterPush {
+		for _, tag := range tags {
+			localImage := fmt.Sprintf("%s:%s", provisionImageName, tag)
+			pterm.Info.Printf("Deleting local image %s...\n", localImage)
+			if err := docker.RemoveImage(cmd.Context(), localImage); err != nil {
+				pterm.Error.Println("Failed to delete local image:", err)
+				return err
+			}
+			pterm.Success.Println("Successfully deleted local image:", localImage)
+			if platforms := docker.Platforms(provisionPlatform); len(platforms) > 1 {
+				for _, platform := range platforms {
+					if err := docker.RemoveImage(cmd.Context(), docker.PlatformReference(localImage, platform)); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	pterm.Success.Println("Provisioning completed successfully.")
+	return output.Print(map[string]interface{}{"image": fullImageName, "tags": tags, "push": pushResult, "sbom": sbom})
+}
+
+// addProvisionFlags registers the flags shared by provision and its per-registry aliases
+func addProvisionFlags(c *cobra.Command) {
+	c.Flags().StringVarP(&provisionImageName, "image-name", "i", "", "Name of the image to build")
+	c.Flags().StringVarP(&provisionImageTag, "tag", "t", "latest", "Tag for the image")
+	c.Flags().StringVar(&provisionDigestFile, "digest-file", "", "Write the digest of the pushed image to this file")
+	c.Flags().StringVar(&provisionTagStrategy, "tag-strategy", "", "Derive tags from git: any of sha, branch and semver, comma separated")
+	c.Flags().StringVar(&provisionContextDir, "context", "", "Build context directory (default is the directory of the Dockerfile)")
+	c.Flags().StringVarP(&provisionDockerfilePath, "file", "f", "", "Path to the Dockerfile, or - to read it from stdin (default CONTEXT/Dockerfile)")
+	c.Flags().BoolVar(&provisionNoCache, "no-cache", false, "Do not use cache when building the image")
+	c.Flags().StringArrayVar(&provisionBuildArgs, "build-arg", []string{}, "Set build-time variables")
+	c.Flags().StringArrayVar(&provisionLabels, "label", []string{}, "Set metadata on the image (key=value), in addition to the standard OCI labels")
+	c.Flags().StringVar(&provisionTarget, "target", "", "Set the target build stage to build")
+	c.Flags().StringVarP(&provisionSarifFile, "sarif", "o", "", "Output file for SARIF report")
+	c.Flags().StringVar(&provisionScanner, "scanner", "scout", "Vulnerability scanner: "+strings.Join(docker.Scanners, ", "))
+	c.Flags().StringVar(&provisionFailOn, "fail-on", "", "Block the push when a vulnerability is at least this severe: critical, high, medium or low")
+	c.Flags().StringVar(&provisionIgnoreFile, "ignore-file", docker.DefaultIgnoreFile, "YAML file of accepted vulnerabilities with expiry dates")
+	c.Flags().StringVar(&provisionSBOM, "sbom", "", "Generate an SBOM of the image: "+strings.Join(docker.SBOMFormats, " or "))
+	c.Flags().StringVar(&provisionSBOMFile, "sbom-file", "", "File to write the SBOM to (default sbom.spdx.json or sbom.cdx.json)")
+	c.Flags().BoolVar(&provisionSBOMAttach, "sbom-attach", false, "Attach the SBOM to the pushed image as an OCI referrer")
+	c.Flags().StringVar(&provisionTargetTag, "target-tag", "", "Target tag for tagging the image")
+	c.Flags().BoolVarP(&provisionConfirmPush, "yes", "y", false, "Push the image without confirmation")
+	c.Flags().BoolVarP(&provisionDeleteAfterPush, "delete", "d", false, "Delete the local image after pushing")
+	c.Flags().StringVar(&provisionPlatform, "platform", "", "Platforms for the image; several (e.g., linux/amd64,linux/arm64) push one multi-platform image")
+	c.Flags().BoolVar(&provisionBuildKit, "buildkit", false, "Build with BuildKit through docker buildx")
+	c.Flags().StringArrayVar(&provisionSecrets, "secret", []string{}, "Secret to expose to the build (id=NAME,src=FILE or id=NAME,env=VAR)")
+	c.Flags().StringArrayVar(&provisionSSH, "ssh", []string{}, "SSH agent socket or keys to forward to the build (default or ID=PATH)")
+	c.Flags().StringArrayVar(&provisionCacheFrom, "cache-from", []string{}, "External cache sources (type=registry,ref=REF or type=local,src=DIR)")
+	c.Flags().StringArrayVar(&provisionCacheTo, "cache-to", []string{}, "Cache export destinations (type=registry,ref=REF or type=local,dest=DIR)")
+	addSignFlags(c, &provisionSign, &provisionSignKey)
+	c.Flags().StringVarP(&provisionRepository, "repository", "R", "", "Remote repository name (defaults to the image name)")
+	cmd.AddRegistryFlags(c, &provisionRegistry, &provisionRegistryOpts)
+
+	c.MarkFlagRequired("image-name")
+}
+
+func init() {
+	addProvisionFlags(provisionCmd)
+	sdkrCmd.AddCommand(provisionCmd)
+
+	// The per-registry commands predate --registry and are kept for existing scripts
+	for _, kind := range []string{"hub", "ecr", "acr", "gcr"} {
+		alias := &cobra.Command{
+			Use:        "provision-" + kind,
+			Short:      fmt.Sprintf("Build, scan, tag, and push a Docker image to %s.", strings.ToUpper(kind)),
+			Deprecated: fmt.Sprintf("use 'smurf sdkr provision --registry %s' instead", kind),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				provisionRegistry = kind
+				return runProvision(cmd)
+			},
+		}
+		addProvisionFlags(alias)
+		sdkrCmd.AddCommand(alias)
+	}
+}
@@ -0,0 +1,98 @@
+package docker
+
+import (
+	"fmt"
+
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/dryrun"
+	"github.com/clouddrove/smurf/internal/output"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneRepositories []string
+	pruneKeepLast     int
+	pruneOlderThan    string
+	pruneDangling     bool
+	pruneCacheBudget  string
+	pruneYes          bool
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove local images and build cache according to retention rules.",
+	Long: `Remove local images and build cache to free disk space, e.g. on CI runners.
+
+A tagged image is removed only when every rule that is set allows it:
+  --repository PATTERN  its repository matches a pattern (repeatable), e.g. 'myorg/*'
+  --keep-last N         it is not among the N most recent images of its repository
+  --older-than AGE      it was created more than AGE ago, e.g. 7d or 12h
+Dangling images are removed with --dangling, unless they are younger than
+--older-than. --cache-budget prunes the build cache down to a size such as 10GB.
+
+Images used by running containers are never removed, and images used by stopped
+containers are kept with a warning. The images to remove are listed first and
+removed after confirmation. Use --yes to skip the confirmation, or --dry-run to
+only preview.`,
+	Example: `  smurf sdkr prune --repository 'myorg/*' --keep-last 3 --dangling -y
+  smurf sdkr prune --older-than 7d --dangling --cache-budget 10GB -y
+  smurf sdkr prune --repository myapp --dry-run`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		policy := docker.PrunePolicy{
+			Repositories: pruneRepositories,
+			KeepLast:     pruneKeepLast,
+			Dangling:     pruneDangling,
+			CacheBudget:  -1,
+		}
+		var err error
+		if pruneOlderThan != "" {
+			if policy.OlderThan, err = docker.ParseAge(pruneOlderThan); err != nil {
+				return err
+			}
+		}
+		if pruneCacheBudget != "" {
+			if policy.CacheBudget, err = docker.ParseSize(pruneCacheBudget); err != nil {
+				return err
+			}
+		}
+
+		plan, err := docker.PlanPrune(cmd.Context(), policy)
+		if err != nil {
+			return err
+		}
+		docker.PrintPrunePlan(plan)
+		if len(plan.Remove) == 0 && (plan.CacheBudget < 0 || plan.CacheSize <= plan.CacheBudget) {
+			return output.Print(plan)
+		}
+
+		confirmed := pruneYes || dryrun.Enabled()
+		if !confirmed {
+			confirmed, _ = pterm.DefaultInteractiveConfirm.
+				WithDefaultText(fmt.Sprintf("Remove %d local images and prune the build cache as listed?", len(plan.Remove))).
+				Show()
+		}
+		if !confirmed {
+			pterm.Info.Println("Prune cancelled, nothing was removed.")
+			return output.Print(plan)
+		}
+
+		if err := docker.ApplyPrune(cmd.Context(), plan); err != nil {
+			return err
+		}
+		return output.Print(plan)
+	},
+}
+
+func init() {
+	pruneCmd.Flags().StringArrayVar(&pruneRepositories, "repository", []string{}, "Only remove images of repositories matching this pattern, e.g. 'myorg/*'")
+	pruneCmd.Flags().IntVar(&pruneKeepLast, "keep-last", 0, "Keep the N most recent images of each repository")
+	pruneCmd.Flags().StringVar(&pruneOlderThan, "older-than", "", "Only remove images created longer ago than this, e.g. 7d or 12h")
+	pruneCmd.Flags().BoolVar(&pruneDangling, "dangling", false, "Remove dangling images")
+	pruneCmd.Flags().StringVar(&pruneCacheBudget, "cache-budget", "", "Prune the build cache down to this size, e.g. 10GB")
+	pruneCmd.Flags().BoolVarP(&pruneYes, "yes", "y", false, "Remove without confirmation")
+
+	sdkrCmd.AddCommand(pruneCmd)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
@@ -15,6 +16,10 @@ var (
 	acrImageName       string
 	acrImageTag        string
 	acrDeleteAfterPush bool
+	acrPlatform        string
+	acrDigestFile      string
+	acrSign            bool
+	acrSignKey         string
 )
 
 var pushAcrCmd = &cobra.Command{
@@ -25,22 +30,37 @@ var pushAcrCmd = &cobra.Command{
 			return fmt.Errorf("azure requires --subscription-id, --resource-group, and --registry-name flags")
 		}
 
+		key, err := loadSignKey(acrSign, acrSignKey)
+		if err != nil {
+			return err
+		}
+
 		acrImage := fmt.Sprintf("%s.azurecr.io/%s:%s", acrRegistryName, acrImageName, acrImageTag)
 
 		pterm.Info.Println("Pushing image to Azure Container Registry...")
-		if err := docker.PushImageToACR(acrSubscriptionID, acrResourceGroup, acrRegistryName, acrImageName); err != nil {
+		reg := docker.NewACRRegistry(acrSubscriptionID, acrResourceGroup, acrRegistryName)
+		result, err := docker.PushPlatforms(cmd.Context(), reg, acrImageName, "", docker.Platforms(acrPlatform))
+		if err != nil {
+			return err
+		}
+		if err := signPushed(cmd.Context(), reg, result, key); err != nil {
 			return err
 		}
+		if acrDigestFile != "" {
+			if err := docker.WriteDigestFile(acrDigestFile, result); err != nil {
+				return err
+			}
+		}
 		pterm.Success.Println("Successfully pushed image to ACR:", acrImage)
 
 		if acrDeleteAfterPush {
-			if err := docker.RemoveImage(acrImageName); err != nil {
+			if err := docker.RemoveImage(cmd.Context(), acrImageName); err != nil {
 				return err
 			}
 			pterm.Success.Println("Successfully deleted local image:", acrImageName)
 		}
 
-		return nil
+		return output.Print(result)
 	},
 }
 
@@ -48,11 +68,15 @@ func init() {
 	pushAcrCmd.Flags().StringVarP(&acrImageName, "image", "i", "", "Image name (e.g., myapp)")
 	pushAcrCmd.Flags().StringVarP(&acrImageTag, "tag", "t", "latest", "Image tag (default: latest)")
 	pushAcrCmd.Flags().BoolVarP(&acrDeleteAfterPush, "delete", "d", false, "Delete the local image after pushing")
+	pushAcrCmd.Flags().StringVar(&acrPlatform, "platform", "", "Platforms of a multi-platform build to push as one image index (e.g., linux/amd64,linux/arm64)")
+	pushAcrCmd.Flags().StringVar(&acrDigestFile, "digest-file", "", "Write the digest of the pushed image to this file")
 
 	pushAcrCmd.Flags().StringVar(&acrSubscriptionID, "subscription-id", "", "Azure subscription ID (required with --azure)")
 	pushAcrCmd.Flags().StringVar(&acrResourceGroup, "resource-group", "", "Azure resource group name (required with --azure)")
 	pushAcrCmd.Flags().StringVar(&acrRegistryName, "registry-name", "", "Azure Container Registry name (required with --azure)")
 
+	addSignFlags(pushAcrCmd, &acrSign, &acrSignKey)
+
 	pushAcrCmd.MarkFlagRequired("subscription-id")
 	pushAcrCmd.MarkFlagRequired("resource-group")
 	pushAcrCmd.MarkFlagRequired("registry-name")
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
@@ -14,6 +15,10 @@ var (
 	ecrRegionName     string
 	ecrImageTag   string
 	ecrDeleteAfterPush bool
+	ecrPlatform string
+	ecrDigestFile string
+	ecrSign bool
+	ecrSignKey string
 )
 
 var pushEcrCmd = &cobra.Command{
@@ -24,20 +29,35 @@ var pushEcrCmd = &cobra.Command{
 			return fmt.Errorf("aws requires both --region and --repository flags")
 		}
 
+		key, err := loadSignKey(ecrSign, ecrSignKey)
+		if err != nil {
+			return err
+		}
+
 		ecrImage := fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com/%s:%s", ecrImageName, ecrRegionName, ecrRepositoryName, ecrImageTag)
 		pterm.Info.Println("Pushing image to AWS ECR...")
-		if err := docker.PushImageToECR(ecrImageName, ecrRegionName, ecrRepositoryName); err != nil {
+		reg := docker.NewECRRegistry(ecrRegionName)
+		result, err := docker.PushPlatforms(cmd.Context(), reg, ecrImageName, ecrRepositoryName, docker.Platforms(ecrPlatform))
+		if err != nil {
+			return err
+		}
+		if err := signPushed(cmd.Context(), reg, result, key); err != nil {
 			return err
 		}
+		if ecrDigestFile != "" {
+			if err := docker.WriteDigestFile(ecrDigestFile, result); err != nil {
+				return err
+			}
+		}
 		pterm.Success.Println("Successfully pushed image to ECR:", ecrImage)
 
 		if ecrDeleteAfterPush {
-			if err := docker.RemoveImage(ecrImageName); err != nil {
+			if err := docker.RemoveImage(cmd.Context(), ecrImageName); err != nil {
 				return err
 			}
 			pterm.Success.Println("Successfully deleted local image:", ecrImageName)
 		}
-		return nil
+		return output.Print(result)
 	},
 }
 
@@ -45,10 +65,14 @@ func init() {
 	pushEcrCmd.Flags().StringVarP(&ecrImageName, "image", "i", "", "Image name (e.g., myapp)")
 	pushEcrCmd.Flags().StringVarP(&ecrImageTag, "tag", "t", "latest", "Image tag (default: latest)")
 	pushEcrCmd.Flags().BoolVarP(&ecrDeleteAfterPush, "delete", "d", false, "Delete the local image after pushing")
+	pushEcrCmd.Flags().StringVar(&ecrPlatform, "platform", "", "Platforms of a multi-platform build to push as one image index (e.g., linux/amd64,linux/arm64)")
+	pushEcrCmd.Flags().StringVar(&ecrDigestFile, "digest-file", "", "Write the digest of the pushed image to this file")
 
 	pushEcrCmd.Flags().StringVarP(&ecrRegionName, "region", "r", "", "AWS region (required with --aws)")
 	pushEcrCmd.Flags().StringVarP(&ecrRepositoryName, "repository", "R", "", "AWS ECR repository name (required with --aws)")
 
+	addSignFlags(pushEcrCmd, &ecrSign, &ecrSignKey)
+
 	pushEcrCmd.MarkFlagRequired("region")
 	pushEcrCmd.MarkFlagRequired("repository")
 	pushEcrCmd.MarkFlagRequired("image")
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
@@ -13,6 +14,10 @@ var (
 	gcrImageName       string
 	gcrImageTag        string
 	gcrDeleteAfterPush bool
+	gcrPlatform        string
+	gcrDigestFile      string
+	gcrSign            bool
+	gcrSignKey         string
 )
 
 var pushGcrCmd = &cobra.Command{
@@ -26,22 +31,37 @@ var pushGcrCmd = &cobra.Command{
 			return fmt.Errorf("gcp requires --project-id flag")
 		}
 
+		key, err := loadSignKey(gcrSign, gcrSignKey)
+		if err != nil {
+			return err
+		}
+
 		gcrImage := fmt.Sprintf("gcr.io/%s/%s:%s", gcrProjectID, gcrImageName, gcrImageTag)
 
 		pterm.Info.Println("Pushing image to Google Container Registry...")
-		if err := docker.PushImageToGCR(gcrProjectID, gcrImageName); err != nil {
+		reg := docker.NewGCRRegistry(gcrProjectID)
+		result, err := docker.PushPlatforms(cmd.Context(), reg, gcrImageName, "", docker.Platforms(gcrPlatform))
+		if err != nil {
+			return err
+		}
+		if err := signPushed(cmd.Context(), reg, result, key); err != nil {
 			return err
 		}
+		if gcrDigestFile != "" {
+			if err := docker.WriteDigestFile(gcrDigestFile, result); err != nil {
+				return err
+			}
+		}
 		pterm.Success.Println("Successfully pushed image to GCR:", gcrImage)
 
 		if gcrDeleteAfterPush {
-			if err := docker.RemoveImage(gcrImageName); err != nil {
+			if err := docker.RemoveImage(cmd.Context(), gcrImageName); err != nil {
 				return err
 			}
 			pterm.Success.Println("Successfully deleted local image:", gcrImageName)
 		}
 
-		return nil
+		return output.Print(result)
 	},
 }
 
@@ -49,9 +69,13 @@ func init() {
 	pushGcrCmd.Flags().StringVarP(&gcrImageName, "image", "i", "", "Image name (e.g., myapp)")
 	pushGcrCmd.Flags().StringVarP(&gcrImageTag, "tag", "t", "latest", "Image tag (default: latest)")
 	pushGcrCmd.Flags().BoolVarP(&gcrDeleteAfterPush, "delete", "d", false, "Delete the local image after pushing")
+	pushGcrCmd.Flags().StringVar(&gcrPlatform, "platform", "", "Platforms of a multi-platform build to push as one image index (e.g., linux/amd64,linux/arm64)")
+	pushGcrCmd.Flags().StringVar(&gcrDigestFile, "digest-file", "", "Write the digest of the pushed image to this file")
 
 	pushGcrCmd.Flags().StringVar(&gcrProjectID, "project-id", "", "GCP project ID (required with --gcp)")
 
+	addSignFlags(pushGcrCmd, &gcrSign, &gcrSignKey)
+
 	pushGcrCmd.MarkFlagRequired("project-id")
 	pushGcrCmd.MarkFlagRequired("image")
 
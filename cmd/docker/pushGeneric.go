@@ -0,0 +1,84 @@
+package docker
+
+import (
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/output"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	genericImageName       string
+	genericRepository      string
+	genericDeleteAfterPush bool
+	genericPlatform        string
+	genericDigestFile      string
+	genericSign            bool
+	genericSignKey         string
+	genericRegistryOpts    docker.RegistryOptions
+)
+
+var pushGenericCmd = &cobra.Command{
+	Use:   "generic",
+	Short: "push Docker images to any OCI registry",
+	Long: `push Docker images to any registry implementing the OCI distribution API,
+such as GitHub Container Registry, Harbor, Quay or a self-hosted registry:2.
+Authenticate with --username and --password for basic auth or with --token for
+a bearer token, or set REGISTRY_USERNAME, REGISTRY_PASSWORD or REGISTRY_TOKEN.
+Without credentials the push is anonymous.`,
+	Example: `  smurf sdkr push generic -i myapp:v1 --registry-url ghcr.io --repository myorg/myapp --username me --password $GITHUB_TOKEN
+  smurf sdkr push generic -i myapp:v1 --registry-url harbor.internal --repository team/myapp --token $HARBOR_TOKEN
+  smurf sdkr push generic -i myapp:v1 --registry-url localhost:5000`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		reg, err := docker.NewRegistry("generic", genericRegistryOpts)
+		if err != nil {
+			return err
+		}
+		key, err := loadSignKey(genericSign, genericSignKey)
+		if err != nil {
+			return err
+		}
+
+		result, err := docker.PushPlatforms(cmd.Context(), reg, genericImageName, genericRepository, docker.Platforms(genericPlatform))
+		if err != nil {
+			return err
+		}
+		if err := signPushed(cmd.Context(), reg, result, key); err != nil {
+			return err
+		}
+		if genericDigestFile != "" {
+			if err := docker.WriteDigestFile(genericDigestFile, result); err != nil {
+				return err
+			}
+		}
+
+		if genericDeleteAfterPush {
+			if err := docker.RemoveImage(cmd.Context(), genericImageName); err != nil {
+				return err
+			}
+			pterm.Success.Println("Successfully deleted local image:", genericImageName)
+		}
+		return output.Print(result)
+	},
+}
+
+func init() {
+	pushGenericCmd.Flags().StringVarP(&genericImageName, "image", "i", "", "Local image to push (e.g., myapp:v1)")
+	pushGenericCmd.Flags().StringVarP(&genericRepository, "repository", "R", "", "Repository in the registry (defaults to the image name)")
+	pushGenericCmd.Flags().BoolVarP(&genericDeleteAfterPush, "delete", "d", false, "Delete the local image after pushing")
+	pushGenericCmd.Flags().StringVar(&genericPlatform, "platform", "", "Platforms of a multi-platform build to push as one image index (e.g., linux/amd64,linux/arm64)")
+	pushGenericCmd.Flags().StringVar(&genericDigestFile, "digest-file", "", "Write the digest of the pushed image to this file")
+
+	pushGenericCmd.Flags().StringVar(&genericRegistryOpts.URL, "registry-url", "", "Registry host, e.g. ghcr.io or localhost:5000 (required)")
+	pushGenericCmd.Flags().StringVar(&genericRegistryOpts.Username, "username", "", "Registry username for basic auth")
+	pushGenericCmd.Flags().StringVar(&genericRegistryOpts.Password, "password", "", "Registry password or access token for basic auth")
+	pushGenericCmd.Flags().StringVar(&genericRegistryOpts.Token, "token", "", "Bearer token for the registry")
+	pushGenericCmd.Flags().BoolVar(&genericRegistryOpts.Insecure, "insecure", false, "Use plain HTTP for the registry API (local addresses always do)")
+
+	addSignFlags(pushGenericCmd, &genericSign, &genericSignKey)
+
+	pushGenericCmd.MarkFlagRequired("registry-url")
+	pushGenericCmd.MarkFlagRequired("image")
+
+	pushCmd.AddCommand(pushGenericCmd)
+}
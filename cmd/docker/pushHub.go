@@ -2,6 +2,7 @@ package docker
 
 import (
 	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
@@ -10,25 +11,41 @@ var (
 	hubImageName       string
 	hubImageTag        string
 	hubDeleteAfterPush bool
+	hubPlatform        string
+	hubDigestFile      string
+	hubSign            bool
+	hubSignKey         string
 )
 
 var pushHubCmd = &cobra.Command{
 	Use:   "hub",
 	Short: "push Docker images to Docker Hub",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		opts := docker.PushOptions{
-			ImageName: hubImageName,
+		key, err := loadSignKey(hubSign, hubSignKey)
+		if err != nil {
+			return err
+		}
+
+		reg := docker.NewHubRegistry("", "")
+		result, err := docker.PushPlatforms(cmd.Context(), reg, hubImageName, "", docker.Platforms(hubPlatform))
+		if err != nil {
+			return err
 		}
-		if err := docker.PushImage(opts); err != nil {
+		if err := signPushed(cmd.Context(), reg, result, key); err != nil {
 			return err
 		}
+		if hubDigestFile != "" {
+			if err := docker.WriteDigestFile(hubDigestFile, result); err != nil {
+				return err
+			}
+		}
 		if hubDeleteAfterPush {
-			if err := docker.RemoveImage(hubImageName); err != nil {
+			if err := docker.RemoveImage(cmd.Context(), hubImageName); err != nil {
 				return err
 			}
 			pterm.Success.Println("Successfully deleted local image:", hubImageName)
 		}
-		return nil
+		return output.Print(result)
 	},
 }
 
@@ -36,6 +53,10 @@ func init() {
 	pushHubCmd.Flags().StringVarP(&hubImageName, "image", "i", "", "Image name (e.g., myapp)")
 	pushHubCmd.Flags().StringVarP(&hubImageTag, "tag", "t", "latest", "Image tag (default: latest)")
 	pushHubCmd.Flags().BoolVarP(&hubDeleteAfterPush, "delete", "d", false, "Delete the local image after pushing")
+	pushHubCmd.Flags().StringVar(&hubPlatform, "platform", "", "Platforms of a multi-platform build to push as one image index (e.g., linux/amd64,linux/arm64)")
+	pushHubCmd.Flags().StringVar(&hubDigestFile, "digest-file", "", "Write the digest of the pushed image to this file")
+
+	addSignFlags(pushHubCmd, &hubSign, &hubSignKey)
 
 	pushHubCmd.MarkFlagRequired("image")
 
@@ -2,26 +2,25 @@ package docker
 
 import (
 	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 )
 
 var imageTag string
-var local bool
-var hub bool
 
 var remove = &cobra.Command{
 	Use:   "remove",
-	Short: "Remove Docker images",
+	Short: "Remove a local Docker image (see cleanup for remote images)",
 	RunE: func(cmd *cobra.Command, args []string) error {
 
-		err := docker.RemoveImage(imageTag)
+		err := docker.RemoveImage(cmd.Context(), imageTag)
 		if err != nil {
 			pterm.Error.Println(err)
 			return err
 		}
 		pterm.Success.Println("Image removal completed successfully.")
-		return nil
+		return output.Print(map[string]string{"removed": imageTag})
 	},
 }
 
@@ -0,0 +1,51 @@
+package docker
+
+import (
+	"strings"
+
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sbomFormat     string
+	sbomOutputFile string
+	sbomAttach     bool
+)
+
+var sbomCmd = &cobra.Command{
+	Use:   "sbom IMAGE",
+	Short: "Generate a software bill of materials for a local image.",
+	Long: `Generate a software bill of materials (SBOM) for an image in the local Docker daemon.
+
+The image is exported from the daemon and its filesystem is inventoried for OS
+packages (dpkg and apk) and language dependencies (Go binaries, npm, Python,
+Java archives and Ruby gems). The SBOM is written as SPDX or CycloneDX JSON and
+can be attached to the pushed image in its registry as an OCI referrer.`,
+	Example: `  smurf sdkr sbom myapp:v1.0.0
+  smurf sdkr sbom myapp:v1.0.0 --format cyclonedx --output-file myapp.cdx.json
+  smurf sdkr sbom ghcr.io/myorg/myapp:v1.0.0 --attach`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sbom, err := docker.CreateSBOM(cmd.Context(), args[0], sbomFormat, sbomOutputFile)
+		if err != nil {
+			return err
+		}
+		if sbomAttach {
+			if err := docker.AttachSBOMToImage(cmd.Context(), args[0], sbom); err != nil {
+				return err
+			}
+		}
+		return output.Print(sbom)
+	},
+}
+
+func init() {
+	sbomCmd.Flags().StringVar(&sbomFormat, "format", "spdx", "SBOM format: "+strings.Join(docker.SBOMFormats, ", "))
+	sbomCmd.Flags().StringVar(&sbomOutputFile, "output-file", "", "File to write the SBOM to (default sbom.spdx.json or sbom.cdx.json)")
+	sbomCmd.Flags().BoolVar(&sbomAttach, "attach", false, "Attach the SBOM to the pushed image in its registry as an OCI referrer")
+
+	sdkrCmd.AddCommand(sbomCmd)
+}
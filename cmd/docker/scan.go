@@ -1,30 +1,55 @@
 package docker
 
 import (
+    "strings"
+
     "github.com/clouddrove/smurf/internal/docker"
+    "github.com/clouddrove/smurf/internal/output"
     "github.com/pterm/pterm"
     "github.com/spf13/cobra"
 )
 
 var dockerTag string
 var sarifFile string
+var scanFailOn string
+var scanIgnoreFile string
+var scanScanner string
 
 var scan = &cobra.Command{
     Use:   "scan",
     Short: "Scan Docker images for known vulnerabilities",
+    SilenceUsage: true,
+    Example: `  smurf sdkr scan -t myapp:v1.0.0
+  smurf sdkr scan -t myapp:v1.0.0 --fail-on high --ignore-file .smurf-ignore.yaml
+  smurf sdkr scan -t myapp:v1.0.0 --scanner trivy --sarif results.sarif`,
     RunE: func(cmd *cobra.Command, args []string) error {
-        err := docker.Scout(dockerTag, sarifFile)
+        opts := docker.ScanOptions{
+            SarifFile:  sarifFile,
+            FailOn:     scanFailOn,
+            IgnoreFile: scanIgnoreFile,
+            Scanner:    scanScanner,
+        }
+        report, err := docker.Scan(cmd.Context(), dockerTag, opts)
+        if report != nil {
+            if printErr := output.Print(report); printErr != nil {
+                return printErr
+            }
+        }
         if err != nil {
             pterm.Error.Println(err)
             return err
         }
+        pterm.Success.Println("Scan completed successfully.")
         return nil
     },
 }
 
 func init() {
     scan.Flags().StringVarP(&dockerTag, "tag", "t", "", "Docker image tag to scan")
-    scan.Flags().StringVarP(&sarifFile, "output", "o", "", "Output file for SARIF report")
+    scan.Flags().StringVarP(&sarifFile, "sarif", "o", "", "Output file for SARIF report")
+    scan.Flags().StringVar(&scanScanner, "scanner", "scout", "Vulnerability scanner: "+strings.Join(docker.Scanners, ", "))
+    scan.Flags().StringVar(&scanFailOn, "fail-on", "", "Fail when a vulnerability is at least this severe: critical, high, medium or low")
+    scan.Flags().StringVar(&scanIgnoreFile, "ignore-file", docker.DefaultIgnoreFile, "YAML file of accepted vulnerabilities with expiry dates")
     scan.MarkFlagRequired("tag")
 
     sdkrCmd.AddCommand(scan)
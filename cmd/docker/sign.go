@@ -0,0 +1,99 @@
+package docker
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/output"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	signKey         string
+	signGenerateKey bool
+)
+
+var signCmd = &cobra.Command{
+	Use:   "sign [IMAGE]",
+	Short: "Sign an image in its registry with a local key pair.",
+	Long: `Sign an image in its registry with a local ECDSA key pair.
+
+The signature is stored next to the image in the same repository, in the format
+cosign uses, so it can be checked with 'smurf sdkr verify' or with
+'cosign verify --key cosign.pub --insecure-ignore-tlog=true'. A tag is resolved
+to its digest first and the digest is what gets signed.
+
+Create a key pair with --generate-key. The private key is encrypted with the
+password in COSIGN_PASSWORD, or one you are prompted for.`,
+	Example: `  smurf sdkr sign --generate-key
+  smurf sdkr sign ghcr.io/myorg/myapp:v1.0.0
+  smurf sdkr sign ghcr.io/myorg/myapp@sha256:... --key release.key`,
+	Args:         cobra.RangeArgs(0, 1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if signGenerateKey {
+			publicKey, err := docker.GenerateKeyPair(signKey)
+			if err != nil {
+				return err
+			}
+			pterm.Success.Printf("Private key written to %s, public key to %s\n", signKey, publicKey)
+			if len(args) == 0 {
+				return output.Print(map[string]string{"privateKey": signKey, "publicKey": publicKey})
+			}
+		}
+		if len(args) == 0 {
+			return fmt.Errorf("an image to sign is required, or --generate-key to create a key pair")
+		}
+
+		key, err := docker.LoadSigningKey(signKey)
+		if err != nil {
+			return err
+		}
+		result, err := docker.SignRemoteImage(cmd.Context(), args[0], key)
+		if err != nil {
+			return err
+		}
+		return output.Print(result)
+	},
+}
+
+// addSignFlags registers the flags that sign an image after it is pushed.
+func addSignFlags(c *cobra.Command, sign *bool, key *string) {
+	c.Flags().BoolVar(sign, "sign", false, "Sign the pushed image with the private key in --key")
+	c.Flags().StringVar(key, "key", docker.DefaultKeyFile, "Private key to sign with (see 'smurf sdkr sign --generate-key')")
+}
+
+// loadSignKey loads the private key when signing was requested, so that a
+// missing key or wrong password fails before anything is pushed.
+func loadSignKey(sign bool, path string) (*ecdsa.PrivateKey, error) {
+	if !sign {
+		return nil, nil
+	}
+	return docker.LoadSigningKey(path)
+}
+
+// signPushed signs a pushed image with key, unless signing was not requested.
+func signPushed(ctx context.Context, reg docker.Registry, result *docker.PushResult, key *ecdsa.PrivateKey) error {
+	if key == nil {
+		return nil
+	}
+	subject := result.Reference
+	if subject == "" {
+		subject = result.Image
+	}
+	if _, err := docker.SignImage(ctx, reg, subject, key); err != nil {
+		pterm.Error.Println("Failed to sign the image:", err)
+		return err
+	}
+	return nil
+}
+
+func init() {
+	signCmd.Flags().StringVar(&signKey, "key", docker.DefaultKeyFile, "Private key to sign with")
+	signCmd.Flags().BoolVar(&signGenerateKey, "generate-key", false, "Create a key pair at --key and its .pub file; existing files are kept")
+
+	sdkrCmd.AddCommand(signCmd)
+}
@@ -2,6 +2,7 @@ package docker
 
 import (
 	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/spf13/cobra"
 )
 
@@ -16,7 +17,10 @@ var tagCmd = &cobra.Command{
 			Source: sourceTag,
 			Target: targetTag,
 		}
-		return docker.TagImage(opts)
+		if err := docker.TagImage(cmd.Context(), opts); err != nil {
+			return err
+		}
+		return output.Print(map[string]string{"source": sourceTag, "target": targetTag})
 	},
 }
 
@@ -0,0 +1,48 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/clouddrove/smurf/internal/git"
+	"github.com/pterm/pterm"
+)
+
+// resolveTags returns the tags to apply to a build: tag, when given, followed
+// by the tags the comma separated strategy derives from the git repository at dir.
+func resolveTags(ctx context.Context, tag, strategy, dir string) ([]string, error) {
+	var tags []string
+	if tag != "" {
+		tags = append(tags, tag)
+	}
+	if strategy == "" {
+		if len(tags) == 0 {
+			return nil, fmt.Errorf("an image tag or --tag-strategy is required")
+		}
+		return tags, nil
+	}
+
+	if dir == "" {
+		dir = "."
+	}
+	info, err := git.Describe(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	derived, err := git.ImageTags(info, strings.Split(strategy, ","))
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range derived {
+		if t != tag {
+			tags = append(tags, t)
+		}
+	}
+
+	if info.Dirty {
+		pterm.Warning.Println("The working tree has uncommitted changes, tags get a -dirty suffix")
+	}
+	pterm.Info.Printf("Image tags: %s\n", strings.Join(tags, ", "))
+	return tags, nil
+}
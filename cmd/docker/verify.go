@@ -0,0 +1,40 @@
+package docker
+
+import (
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/clouddrove/smurf/internal/output"
+	"github.com/spf13/cobra"
+)
+
+var verifyKey string
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify IMAGE",
+	Short: "Verify the signatures of an image in its registry.",
+	Long: `Verify that an image in its registry is signed with the private key that
+belongs to a public key. A tag is resolved to its digest, and the command fails
+unless at least one signature of that digest is valid for the key.
+
+Signatures made by 'smurf sdkr sign' and by 'cosign sign --key' are both accepted.`,
+	Example: `  smurf sdkr verify ghcr.io/myorg/myapp:v1.0.0
+  smurf sdkr verify ghcr.io/myorg/myapp:v1.0.0 --key release.pub`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, err := docker.LoadPublicKey(verifyKey)
+		if err != nil {
+			return err
+		}
+		verification, err := docker.VerifyImage(cmd.Context(), args[0], key)
+		if err != nil {
+			return err
+		}
+		return output.Print(verification)
+	},
+}
+
+func init() {
+	verifyCmd.Flags().StringVar(&verifyKey, "key", docker.PublicKeyFile(docker.DefaultKeyFile), "Public key to verify with")
+
+	sdkrCmd.AddCommand(verifyCmd)
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/clouddrove/smurf/internal/doctor"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/clouddrove/smurf/internal/output"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+)
+
+var doctorKubeContext string
+
+// doctorCmd checks the tools and credentials the other commands rely on
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the local environment for the tools and credentials smurf needs",
+	Long: `Check that Terraform, the Docker daemon and its Scout plugin, the Kubernetes
+cluster, the Helm storage driver and the AWS, Azure and GCP credentials used by
+the push commands are available. Missing optional pieces are reported as
+warnings; the command exits with a non-zero status if any check fails.`,
+	Example: `  smurf doctor
+  smurf doctor --kube-context prod-cluster --output json`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		helm.SetKubeContext(doctorKubeContext)
+
+		results := doctor.Run(cmd.Context(), doctor.Checks())
+		if err := output.Print(results); err != nil {
+			return err
+		}
+
+		if failed := doctor.Failures(results); failed > 0 {
+			return fmt.Errorf("%d of %d checks failed", failed, len(results))
+		}
+		pterm.Success.Println("All required checks passed.")
+		return nil
+	},
+}
+
+func init() {
+	doctorCmd.Flags().StringVar(&doctorKubeContext, "kube-context", "", "Name of the kubeconfig context to check (defaults to the current context)")
+	RootCmd.AddCommand(doctorCmd)
+}
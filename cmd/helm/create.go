@@ -2,6 +2,7 @@ package helm
 
 import (
 	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/spf13/cobra"
 )
 
@@ -10,7 +11,10 @@ var createChartCmd = &cobra.Command{
 	Short: "Create a new Helm chart in the specified directory.",
 	Args:  cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return helm.CreateChart(args[0], args[1])
+		if err := helm.CreateChart(args[0], args[1]); err != nil {
+			return err
+		}
+		return output.Print(map[string]string{"chart": args[0], "directory": args[1]})
 	},
 }
 
@@ -2,6 +2,7 @@ package helm
 
 import (
     "github.com/clouddrove/smurf/internal/helm"
+    "github.com/clouddrove/smurf/internal/output"
     "github.com/spf13/cobra"
 )
 
@@ -17,11 +18,20 @@ var installCmd = &cobra.Command{
         if installNamespace == "" { 
             installNamespace = "default"
         }
-        return helm.HelmInstall(releaseName, chartPath, installNamespace)
+        tfValues, err := helm.TerraformValues(cmd.Context(), tfDir, tfOutputs)
+        if err != nil {
+            return err
+        }
+        rel, err := helm.HelmInstall(cmd.Context(), releaseName, chartPath, installNamespace, tfValues, helm.InstallOptions{})
+        if err != nil {
+            return err
+        }
+        return output.Print(helm.Summarize(rel))
     },
 }
 
 func init() {
     installCmd.Flags().StringVarP(&installNamespace, "namespace", "n", "", "Specify the namespace to install the Helm chart")
+    addTerraformValueFlags(installCmd)
     selmCmd.AddCommand(installCmd)
 }
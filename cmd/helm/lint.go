@@ -2,6 +2,7 @@ package helm
 
 import (
 	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/spf13/cobra"
 )
 
@@ -11,7 +12,11 @@ var lintCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		chartPath := args[0]
-		return helm.HelmLint(chartPath)
+		messages, err := helm.HelmLint(chartPath)
+		if err != nil {
+			return err
+		}
+		return output.Print(map[string]interface{}{"chart": chartPath, "messages": messages})
 	},
 }
 
@@ -1,8 +1,8 @@
 package helm
 
 import (
-	"fmt"
 	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/spf13/cobra"
 )
 
@@ -10,14 +10,11 @@ var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all Helm releases.",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		releases, err := helm.HelmList("default") 
+		releases, err := helm.HelmList(cmd.Context(), "default") 
 		if err != nil {
 			return err
 		}
-		for _, release := range releases {
-			fmt.Printf("%v\n", release.Name)
-		}
-		return nil
+		return output.Print(helm.SummarizeAll(releases))
 	},
 }
 
@@ -2,6 +2,7 @@ package helm
 
 import (
 	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/spf13/cobra"
 )
 
@@ -10,7 +11,11 @@ var provisionCmd = &cobra.Command{
 	Short: "Its the combination of install, upgrade, lint, template for Helm",
 	Args:  cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return helm.HelmProvision(args[0], args[1], "default")
+		rel, err := helm.HelmProvision(cmd.Context(), args[0], args[1], "default")
+		if err != nil {
+			return err
+		}
+		return output.Print(helm.Summarize(rel))
 	},
 }
 
@@ -4,9 +4,18 @@ import (
 	"fmt"
 
 	"github.com/clouddrove/smurf/cmd"
+	"github.com/clouddrove/smurf/internal/helm"
 	"github.com/spf13/cobra"
 )
 
+var kubeContext string
+
+// Terraform outputs mapped onto Helm values by install and upgrade
+var (
+	tfDir     string
+	tfOutputs []string
+)
+
 // selmCmd represents the 'selm' subcommand command
 var selmCmd = &cobra.Command{
 	Use:   "selm",
@@ -15,8 +24,18 @@ var selmCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("Use 'smurf selm [command]' to run Helm-related actions")
 	},
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		helm.SetKubeContext(kubeContext)
+	},
+}
+
+// addTerraformValueFlags registers the flags that feed Terraform outputs into the release values
+func addTerraformValueFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&tfDir, "tf-dir", ".", "Terraform directory to read outputs from")
+	cmd.Flags().StringArrayVar(&tfOutputs, "tf-output", []string{}, "Map a Terraform output onto a value path, e.g. db_endpoint=database.host (can specify multiple)")
 }
 
 func init() {
+	selmCmd.PersistentFlags().StringVar(&kubeContext, "kube-context", "", "Name of the kubeconfig context to use")
 	cmd.RootCmd.AddCommand(selmCmd)
 }
@@ -2,6 +2,7 @@ package helm
 
 import (
 	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/spf13/cobra"
 )
 
@@ -16,7 +17,11 @@ var statusCmd = &cobra.Command{
 		if statusNamespace == "" { 
             uninstallNamespace = "default"
         }
-		return helm.HelmStatus(releaseName, statusNamespace) 
+		rel, err := helm.HelmStatus(cmd.Context(), releaseName, statusNamespace)
+		if err != nil {
+			return err
+		}
+		return output.Print(helm.Summarize(rel))
 	},
 }
 
@@ -2,6 +2,7 @@ package helm
 
 import (
 	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/spf13/cobra"
 )
 
@@ -11,7 +12,11 @@ var templateCmd = &cobra.Command{
     Short: "Render chart templates ",
     Args:  cobra.ExactArgs(2),
     RunE: func(cmd *cobra.Command, args []string) error {
-        return helm.HelmTemplate(args[0], args[1], "default")
+        manifest, err := helm.HelmTemplate(cmd.Context(), args[0], args[1], "default")
+        if err != nil {
+            return err
+        }
+        return output.Print(map[string]string{"release": args[0], "manifest": manifest})
     },
 }
 
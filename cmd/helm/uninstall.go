@@ -2,6 +2,7 @@ package helm
 
 import (
 	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/spf13/cobra"
 )
 
@@ -16,7 +17,10 @@ var uninstallCmd = &cobra.Command{
 		if uninstallNamespace == "" { 
             uninstallNamespace = "default"
         }
-		return helm.HelmUninstall(releaseName, uninstallNamespace) 
+		if err := helm.HelmUninstall(cmd.Context(), releaseName, uninstallNamespace); err != nil {
+			return err
+		}
+		return output.Print(map[string]string{"release": releaseName, "namespace": uninstallNamespace, "status": "uninstalled"})
 	},
 }
 
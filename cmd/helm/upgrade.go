@@ -2,12 +2,14 @@ package helm
 
 import (
     "github.com/clouddrove/smurf/internal/helm"
+    "github.com/clouddrove/smurf/internal/output"
     "github.com/spf13/cobra"
     "time"
 )
 
 var (
     setValues       []string
+    setStringValues []string
     valuesFiles     []string
     namespace       string
     createNamespace bool
@@ -24,29 +26,38 @@ var upgradeCmd = &cobra.Command{
     RunE: func(cmd *cobra.Command, args []string) error {
         releaseName := args[0]
         chartPath := args[1]
-        if installIfNotPresent {
-            exists, err := helm.HelmReleaseExists(releaseName, namespace)
-            if err != nil {
-                return err 
-            }
-            if !exists {
-                if err := helm.HelmInstall(releaseName, chartPath, namespace); err != nil {
-                    return err
-                }
-            }
+        tfValues, err := helm.TerraformValues(cmd.Context(), tfDir, tfOutputs)
+        if err != nil {
+            return err
         }
-        return helm.HelmUpgrade(releaseName, chartPath, namespace, setValues, valuesFiles, createNamespace, atomic, timeout, debug)
+        rel, err := helm.HelmUpgrade(cmd.Context(), releaseName, chartPath, namespace, helm.UpgradeOptions{
+            ValuesFiles:     valuesFiles,
+            Values:          tfValues,
+            SetValues:       setValues,
+            SetStringValues: setStringValues,
+            CreateNamespace: createNamespace,
+            Atomic:          atomic,
+            Install:         installIfNotPresent,
+            Timeout:         timeout,
+            Debug:           debug,
+        })
+        if err != nil {
+            return err
+        }
+        return output.Print(helm.Summarize(rel))
     },
 }
 
 func init() {
     selmCmd.AddCommand(upgradeCmd)
     upgradeCmd.Flags().StringSliceVar(&setValues, "set", []string{}, "Set values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
+    upgradeCmd.Flags().StringSliceVar(&setStringValues, "set-string", []string{}, "Set STRING values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
     upgradeCmd.Flags().StringSliceVarP(&valuesFiles, "values", "f", []string{}, "Specify values in a YAML file (can specify multiple)")
     upgradeCmd.Flags().StringVarP(&namespace, "namespace", "n", "default", "Specify the namespace to install the release into")
     upgradeCmd.Flags().BoolVar(&createNamespace, "create-namespace", false, "Create the namespace if it does not exist")
     upgradeCmd.Flags().BoolVar(&atomic, "atomic", false, "If set, the installation process purges the chart on fail, the upgrade process rolls back changes, and the upgrade process waits for the resources to be ready")
     upgradeCmd.Flags().DurationVar(&timeout, "timeout", 300*time.Second, "Time to wait for any individual Kubernetes operation (like Jobs for hooks)")
     upgradeCmd.Flags().BoolVar(&debug, "debug", false, "Enable verbose output")
+    addTerraformValueFlags(upgradeCmd)
     upgradeCmd.Flags().BoolVar(&installIfNotPresent, "install", false, "Install the chart if it is not already installed")
 }
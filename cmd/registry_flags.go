@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/clouddrove/smurf/internal/docker"
+	"github.com/spf13/cobra"
+)
+
+// AddRegistryFlags registers the --registry selector and the provider specific
+// flags NewRegistry needs, shared by every command that publishes images.
+func AddRegistryFlags(c *cobra.Command, kind *string, opts *docker.RegistryOptions) {
+	c.Flags().StringVar(kind, "registry", "hub", "Registry to push to: "+strings.Join(docker.Registries, ", "))
+	c.Flags().StringVarP(&opts.Region, "region", "r", "", "AWS region (required with --registry ecr)")
+	c.Flags().StringVar(&opts.RegistryID, "registry-id", "", "AWS account ID of the ECR registry, if not the one of the credentials (ecr)")
+	c.Flags().StringVar(&opts.SubscriptionID, "subscription-id", "", "Azure subscription ID (required with --registry acr)")
+	c.Flags().StringVar(&opts.ResourceGroup, "resource-group", "", "Azure resource group name (required with --registry acr)")
+	c.Flags().StringVar(&opts.RegistryName, "registry-name", "", "Azure Container Registry name (required with --registry acr)")
+	c.Flags().StringVarP(&opts.ProjectID, "project-id", "p", "", "GCP project ID (required with --registry gcr)")
+	c.Flags().StringVar(&opts.URL, "registry-url", "", "Registry host (required with --registry generic)")
+	c.Flags().StringVar(&opts.Username, "username", "", "Registry username for basic auth (hub and generic)")
+	c.Flags().StringVar(&opts.Password, "password", "", "Registry password or access token for basic auth (hub and generic)")
+	c.Flags().StringVar(&opts.Token, "token", "", "Bearer token for the registry (generic)")
+	c.Flags().BoolVar(&opts.Insecure, "insecure", false, "Use plain HTTP for the registry API (generic; local addresses always do)")
+}
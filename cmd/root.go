@@ -4,11 +4,32 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
+	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/dryrun"
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/pterm/pterm"
 	"github.com/pterm/pterm/putils"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	cfgFile      string
+	envName      string
+	outputFormat string
+	dryRun       bool
+)
+
+// loadedConfig is the configuration resolved for the current invocation and
+// environments lists the profiles the config file declares.
+var (
+	loadedConfig *configs.Config
+	environments []string
 )
 
 var originalHelpFunc func(*cobra.Command, []string)
@@ -22,16 +43,69 @@ var RootCmd = &cobra.Command{
 		// Remove the displayBigText() call here
 		cmd.Help()
 	},
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := configs.LoadConfig(cfgFile)
+		if err != nil {
+			return err
+		}
+		environments = cfg.Environments()
+		cfg, err = cfg.ForEnvironment(envName)
+		if err != nil {
+			return err
+		}
+		loadedConfig = cfg
+		if err := applyConfig(cmd, cfg); err != nil {
+			return err
+		}
+		if dryRun {
+			dryrun.Enable()
+		}
+		if err := sarifOutput(cmd); err != nil {
+			return err
+		}
+		return output.SetFormat(outputFormat)
+	},
+}
+
+// sarifOutput keeps the --output FILE of the commands that wrote their SARIF
+// report there before --output selected the output format, passing the file on
+// to --sarif with a deprecation warning.
+func sarifOutput(cmd *cobra.Command) error {
+	switch output.Format(outputFormat) {
+	case output.Text, output.JSON, output.YAML, "":
+		return nil
+	}
+	sarif := cmd.Flags().Lookup("sarif")
+	if sarif == nil {
+		return nil
+	}
+	if sarif.Changed {
+		return fmt.Errorf("unsupported output format '%s': --output selects text, json or yaml, and the SARIF report is already written to '%s'", outputFormat, sarif.Value)
+	}
+	pterm.Warning.Printf("--output FILE for the SARIF report is deprecated, use --sarif %s (or -o) instead\n", outputFormat)
+	if err := cmd.Flags().Set("sarif", outputFormat); err != nil {
+		return err
+	}
+	outputFormat = string(output.Text)
+	return nil
 }
 
 func Execute() {
-	err := RootCmd.Execute()
+	ctx, stop := signalContext()
+	err := RootCmd.ExecuteContext(ctx)
+	stop()
 	if err != nil {
 		os.Exit(1)
 	}
+	if dryrun.Enabled() {
+		dryrun.Report()
+	}
 }
 
 func init() {
+	// Run the persistent hooks of every parent so subcommand groups can add their own setup
+	cobra.EnableTraverseRunHooks = true
+
 	// Save the original help function
 	originalHelpFunc = RootCmd.HelpFunc()
 
@@ -45,7 +119,10 @@ func init() {
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	// RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.smurf.yaml)")
+	RootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is smurf.yaml in the current directory)")
+	RootCmd.PersistentFlags().StringVar(&envName, "env", os.Getenv("SMURF_ENV"), "environment profile from the config file to apply (defaults to $SMURF_ENV)")
+	RootCmd.PersistentFlags().StringVar(&outputFormat, "output", "text", "output format: text, json or yaml (progress is written to stderr for json and yaml)")
+	RootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "report the changes a command would make without performing them")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -58,3 +135,53 @@ func displayBigText() {
 		putils.LettersFromStringWithStyle("murf", pterm.FgLightMagenta.ToStyle())).
 		Render()
 }
+
+// applyConfig fills every flag of cmd that was not set on the command line
+// with the matching value from the configuration file.
+func applyConfig(cmd *cobra.Command, cfg *configs.Config) error {
+	path := strings.Fields(cmd.CommandPath())[1:]
+	defaults := cfg.FlagDefaults(path)
+
+	var applyErr error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		value, ok := defaults[f.Name]
+		if !ok || f.Changed || f.Name == "config" || f.Name == "env" || applyErr != nil {
+			return
+		}
+		if err := setFlagValue(f, value); err != nil {
+			applyErr = fmt.Errorf("invalid value for '%s' in %s: %w", f.Name, cfg.Path, err)
+			return
+		}
+		f.Changed = true
+	})
+
+	return applyErr
+}
+
+// setFlagValue assigns a value decoded from YAML to a flag.
+func setFlagValue(f *pflag.Flag, value interface{}) error {
+	if list, ok := value.([]interface{}); ok {
+		items := make([]string, 0, len(list))
+		for _, item := range list {
+			items = append(items, configValueString(item))
+		}
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			return slice.Replace(items)
+		}
+		return f.Value.Set(strings.Join(items, ","))
+	}
+	return f.Value.Set(configValueString(value))
+}
+
+func configValueString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/pterm/pterm"
+)
+
+// signalContext returns a context that is cancelled on the first SIGINT or
+// SIGTERM so running operations can stop cleanly. A second signal exits
+// immediately.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-signals:
+			pterm.Warning.Println("Interrupt received, stopping... press Ctrl-C again to force exit")
+			cancel()
+		case <-ctx.Done():
+			return
+		}
+
+		<-signals
+		pterm.Error.Println("Forced exit")
+		os.Exit(130)
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
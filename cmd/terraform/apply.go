@@ -1,6 +1,8 @@
 package terraform
 
 import (
+	"github.com/clouddrove/smurf/internal/dryrun"
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/clouddrove/smurf/internal/terraform"
 	"github.com/spf13/cobra"
 )
@@ -9,11 +11,14 @@ var applyCmd = &cobra.Command{
 	Use:   "apply",
 	Short: "Apply the changes required to reach the desired state of Terraform Infrastructure",
 	RunE: func(cmd *cobra.Command, args []string) error {
-
-		return terraform.Apply()
+		if err := terraform.Apply(cmd.Context(), vars, varFiles); err != nil {
+			return err
+		}
+		return output.Print(map[string]bool{"applied": !dryrun.Enabled()})
 	},
 }
 
 func init() {
+	addVarFlags(applyCmd)
 	stfCmd.AddCommand(applyCmd)
 }
@@ -1,6 +1,8 @@
 package terraform
 
 import (
+	"github.com/clouddrove/smurf/internal/dryrun"
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/clouddrove/smurf/internal/terraform"
 	"github.com/spf13/cobra"
 )
@@ -9,11 +11,14 @@ var destroyCmd = &cobra.Command{
 	Use:   "destroy",
 	Short: "Destroy the Terraform Infrastructure",
 	RunE: func(cmd *cobra.Command, args []string) error {
-
-		return terraform.Destroy()
+		if err := terraform.Destroy(cmd.Context(), vars, varFiles); err != nil {
+			return err
+		}
+		return output.Print(map[string]bool{"destroyed": !dryrun.Enabled()})
 	},
 }
 
 func init() {
+	addVarFlags(destroyCmd)
 	stfCmd.AddCommand(destroyCmd)
 }
@@ -1,6 +1,7 @@
 package terraform
 
 import (
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/clouddrove/smurf/internal/terraform"
 	"github.com/spf13/cobra"
 )
@@ -9,8 +10,11 @@ var driftCmd = &cobra.Command{
 	Use:   "drift",
 	Short: "Detect drift between state and infrastructure  for Terraform",
 	RunE: func(cmd *cobra.Command, args []string) error {
-
-		return terraform.DetectDrift()
+		changes, err := terraform.DetectDrift(cmd.Context())
+		if err != nil {
+			return err
+		}
+		return output.Print(map[string]interface{}{"drift": len(changes) > 0, "changes": changes})
 	},
 }
 
@@ -1,6 +1,8 @@
 package terraform
 
 import (
+	"github.com/clouddrove/smurf/internal/dryrun"
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/clouddrove/smurf/internal/terraform"
 	"github.com/spf13/cobra"
 )
@@ -9,8 +11,10 @@ var formatCmd = &cobra.Command{
 	Use:   "format",
 	Short: "Format the Terraform Infrastructure",
 	RunE: func(cmd *cobra.Command, args []string) error {
-
-		return terraform.Format()
+		if err := terraform.Format(cmd.Context()); err != nil {
+			return err
+		}
+		return output.Print(map[string]bool{"formatted": !dryrun.Enabled()})
 	},
 }
 
@@ -1,6 +1,7 @@
 package terraform
 
 import (
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/clouddrove/smurf/internal/terraform"
 	"github.com/spf13/cobra"
 )
@@ -9,8 +10,10 @@ var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Initialize Terraform",
 	RunE: func(cmd *cobra.Command, args []string) error {
-
-		return terraform.Init()
+		if err := terraform.Init(cmd.Context()); err != nil {
+			return err
+		}
+		return output.Print(map[string]bool{"initialized": true})
 	},
 }
 
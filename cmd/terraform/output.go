@@ -1,6 +1,7 @@
 package terraform
 
 import (
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/clouddrove/smurf/internal/terraform"
 	"github.com/spf13/cobra"
 )
@@ -9,8 +10,11 @@ var outputCmd = &cobra.Command{
 	Use:   "output",
 	Short: "Generate output for the current state of Terraform Infrastructure",
 	RunE: func(cmd *cobra.Command, args []string) error {
-
-		return terraform.Output()
+		outputs, err := terraform.Output(cmd.Context())
+		if err != nil {
+			return err
+		}
+		return output.Print(outputs)
 	},
 }
 
@@ -1,25 +1,35 @@
 package terraform
 
 import (
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/clouddrove/smurf/internal/terraform"
 	"github.com/spf13/cobra"
 )
 
-var varNameValue string
-var varFile string
+var vars []string
+var varFiles []string
 
 var planCmd = &cobra.Command{
 	Use:   "plan",
 	Short: "Generate and show an execution plan for Terraform",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return terraform.Plan(varNameValue, varFile)
+		hasChanges, err := terraform.Plan(cmd.Context(), vars, varFiles)
+		if err != nil {
+			return err
+		}
+		return output.Print(map[string]bool{"changes": hasChanges})
 	},
 }
 
+// addVarFlags registers the -var and -var-file flags on a Terraform command
+func addVarFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&vars, "var", []string{}, "Specify a variable in 'NAME=VALUE' format (can specify multiple)")
+	cmd.Flags().StringArrayVar(&varFiles, "var-file", []string{}, "Specify a file containing variables (can specify multiple)")
+}
+
 func init() {
 	// Add flags for -var and -var-file
-	planCmd.Flags().StringVar(&varNameValue, "var", "", "Specify a variable in 'NAME=VALUE' format")
-	planCmd.Flags().StringVar(&varFile, "var-file", "", "Specify a file containing variables")
+	addVarFlags(planCmd)
 
 	stfCmd.AddCommand(planCmd)
 }
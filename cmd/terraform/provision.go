@@ -3,6 +3,8 @@ package terraform
 import (
 	"sync"
 
+	"github.com/clouddrove/smurf/internal/dryrun"
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/clouddrove/smurf/internal/terraform"
 	"github.com/spf13/cobra"
 )
@@ -13,30 +15,41 @@ var provisionCmd = &cobra.Command{
 	RunE: func(cmd *cobra.Command, args []string) error {
 		var wg sync.WaitGroup
 		errChan := make(chan error, 5) // Buffer to store up to 5 errors
-		if err := terraform.Init(); err != nil {
+		if err := terraform.Init(cmd.Context()); err != nil {
 			return err
 		}
 
+		var drift []terraform.DriftChange
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err := terraform.DetectDrift(); err != nil {
+			changes, err := terraform.DetectDrift(cmd.Context())
+			if err != nil {
 				errChan <- err
 			}
+			drift = changes
 		}()
-		if err := terraform.Plan("", ""); err != nil {
+		hasChanges, err := terraform.Plan(cmd.Context(), vars, varFiles)
+		if err != nil {
 			return err
 		}
 
-		if err := terraform.Apply(); err != nil {
+		// The plan above already shows what apply would change, so a dry run stops here
+		if dryrun.Enabled() {
+			terraform.RecordPlan("apply", hasChanges)
+		} else if err := terraform.Apply(cmd.Context(), vars, varFiles); err != nil {
 			return err
 		}
+
+		var outputs map[string]terraform.OutputValue
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			if err := terraform.Output(); err != nil {
+			values, err := terraform.Output(cmd.Context())
+			if err != nil {
 				errChan <- err
 			}
+			outputs = values
 		}()
 
 		wg.Wait()
@@ -49,10 +62,15 @@ var provisionCmd = &cobra.Command{
 			}
 		}
 
-		return nil
+		return output.Print(map[string]interface{}{
+			"changes": hasChanges,
+			"drift":   drift,
+			"outputs": outputs,
+		})
 	},
 }
 
 func init() {
+	addVarFlags(provisionCmd)
 	stfCmd.AddCommand(provisionCmd)
 }
@@ -1,6 +1,7 @@
 package terraform
 
 import (
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/clouddrove/smurf/internal/terraform"
 	"github.com/spf13/cobra"
 )
@@ -9,8 +10,11 @@ var validateCmd = &cobra.Command{
 	Use:   "validate",
 	Short: "Validate  Terraform changes",
 	RunE: func(cmd *cobra.Command, args []string) error {
-
-		return terraform.Validate()
+		result, err := terraform.Validate(cmd.Context())
+		if err != nil {
+			return err
+		}
+		return output.Print(result)
 	},
 }
 
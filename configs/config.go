@@ -0,0 +1,125 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultConfigFiles are looked up in the working directory when no config file is given.
+var DefaultConfigFiles = []string{"smurf.yaml", "smurf.yml"}
+
+// EnvironmentsKey is the top-level key holding the named environment profiles.
+const EnvironmentsKey = "environments"
+
+// LoadConfig reads the smurf configuration file at path. When path is empty the
+// working directory is searched for one of DefaultConfigFiles and an empty
+// configuration is returned if none exists.
+func LoadConfig(path string) (*Config, error) {
+	if path == "" {
+		for _, name := range DefaultConfigFiles {
+			if _, err := os.Stat(name); err == nil {
+				path = name
+				break
+			}
+		}
+		if path == "" {
+			return &Config{Settings: map[string]interface{}{}}, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	settings := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	if settings == nil {
+		settings = map[string]interface{}{}
+	}
+
+	return &Config{Path: path, Settings: settings}, nil
+}
+
+// FlagDefaults returns the flag values that apply to the command at the given
+// path, e.g. []string{"sdkr", "provision-ecr"}. Values declared closer to the
+// command override values declared on its parents.
+func (c *Config) FlagDefaults(path []string) map[string]interface{} {
+	defaults := map[string]interface{}{}
+	level := c.Settings
+
+	for i := 0; level != nil; i++ {
+		for key, value := range level {
+			if _, isSection := value.(map[string]interface{}); !isSection {
+				defaults[key] = value
+			}
+		}
+		if i == len(path) {
+			break
+		}
+		level, _ = level[path[i]].(map[string]interface{})
+	}
+
+	return defaults
+}
+
+// Environments returns the names of the environment profiles declared in the config.
+func (c *Config) Environments() []string {
+	envs, _ := c.Settings[EnvironmentsKey].(map[string]interface{})
+	names := make([]string, 0, len(envs))
+	for name := range envs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ForEnvironment returns a copy of the config with the named environment
+// profile merged over the base settings. An empty name returns the base
+// settings without any profiles.
+func (c *Config) ForEnvironment(name string) (*Config, error) {
+	base := map[string]interface{}{}
+	for key, value := range c.Settings {
+		if key != EnvironmentsKey {
+			base[key] = value
+		}
+	}
+	if name == "" {
+		return &Config{Path: c.Path, Settings: base}, nil
+	}
+
+	envs, _ := c.Settings[EnvironmentsKey].(map[string]interface{})
+	profile, ok := envs[name]
+	if !ok {
+		return nil, fmt.Errorf("environment '%s' is not defined in %s (available: %v)", name, c.Path, c.Environments())
+	}
+	overrides, ok := profile.(map[string]interface{})
+	if !ok && profile != nil {
+		return nil, fmt.Errorf("environment '%s' in %s must be a map", name, c.Path)
+	}
+
+	return &Config{Path: c.Path, Settings: mergeSettings(base, overrides)}, nil
+}
+
+// mergeSettings deep merges overrides into base without modifying either map.
+func mergeSettings(base, overrides map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		baseSection, baseIsMap := merged[key].(map[string]interface{})
+		overrideSection, overrideIsMap := value.(map[string]interface{})
+		if baseIsMap && overrideIsMap {
+			merged[key] = mergeSettings(baseSection, overrideSection)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
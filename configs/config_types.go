@@ -0,0 +1,19 @@
+package configs
+
+// Config holds the settings read from a smurf.yaml file.
+//
+// Settings mirrors the command tree: a nested map is a subcommand and any
+// other value is the default for the flag of the same name, e.g.
+//
+//	sdkr:
+//	  image-name: myapp
+//	  provision-ecr:
+//	    region: us-east-1
+//	    repository: myapp
+//
+// Named profiles under the environments key are layered over the base
+// settings by ForEnvironment.
+type Config struct {
+	Path     string
+	Settings map[string]interface{}
+}
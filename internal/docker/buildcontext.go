@@ -0,0 +1,311 @@
+package docker
+
+import (
+	"archive/tar"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/moby/patternmatcher"
+	"github.com/moby/patternmatcher/ignorefile"
+)
+
+// buildContext is the set of files sent to the daemon for a build: every
+// file in the context directory that .dockerignore does not exclude, plus the
+// Dockerfile when it is read from stdin or lives outside the directory.
+type buildContext struct {
+	dir     string
+	entries []string
+	// dockerfile holds the content of a Dockerfile added under dockerfileName.
+	dockerfile     []byte
+	dockerfileName string
+	// Files counts the regular files and symlinks in the context, Size their bytes.
+	Files int
+	Size  int64
+}
+
+// resolveBuildContext collects the build context in contextDir and returns it
+// with the name the daemon should read the Dockerfile from. A relative
+// dockerfilePath is relative to the working directory, as with 'docker build -f',
+// and "-" reads the Dockerfile from stdin. An empty contextDir defaults to the
+// directory of the Dockerfile and an empty dockerfilePath to CONTEXT/Dockerfile.
+func resolveBuildContext(contextDir, dockerfilePath string) (*buildContext, string, error) {
+	if dockerfilePath == "-" {
+		if contextDir == "" {
+			contextDir = "."
+		}
+		content, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read Dockerfile from stdin: %w", err)
+		}
+		bc, err := newBuildContext(contextDir, "")
+		if err != nil {
+			return nil, "", err
+		}
+		return bc, bc.addDockerfile(content), nil
+	}
+
+	if contextDir == "" {
+		if dockerfilePath == "" {
+			dockerfilePath = "Dockerfile"
+		}
+		contextDir = filepath.Dir(dockerfilePath)
+	}
+	if dockerfilePath == "" {
+		dockerfilePath = filepath.Join(contextDir, "Dockerfile")
+	}
+
+	if info, err := os.Stat(contextDir); err != nil || !info.IsDir() {
+		return nil, "", fmt.Errorf("build context %s is not a directory", contextDir)
+	}
+	if _, err := os.Stat(dockerfilePath); err != nil {
+		return nil, "", fmt.Errorf("cannot read Dockerfile: %w", err)
+	}
+
+	absContext, err := filepath.Abs(contextDir)
+	if err != nil {
+		return nil, "", err
+	}
+	absDockerfile, err := filepath.Abs(dockerfilePath)
+	if err != nil {
+		return nil, "", err
+	}
+	rel, err := filepath.Rel(absContext, absDockerfile)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		// The daemon only sees the context, so a Dockerfile outside it is sent along as an extra file
+		content, err := os.ReadFile(dockerfilePath)
+		if err != nil {
+			return nil, "", fmt.Errorf("cannot read Dockerfile: %w", err)
+		}
+		bc, err := newBuildContext(contextDir, "")
+		if err != nil {
+			return nil, "", err
+		}
+		return bc, bc.addDockerfile(content), nil
+	}
+
+	bc, err := newBuildContext(contextDir, rel)
+	if err != nil {
+		return nil, "", err
+	}
+	return bc, filepath.ToSlash(rel), nil
+}
+
+// newBuildContext walks dir and collects the files to send, excluding those
+// matched by its .dockerignore with Docker's semantics. As with 'docker build',
+// .dockerignore and the Dockerfile at the relative path dockerfile, if any, are
+// always kept so the daemon can read them.
+func newBuildContext(dir, dockerfile string) (*buildContext, error) {
+	excludes, err := readDockerignore(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(excludes) > 0 {
+		excludes = append(excludes, "!.dockerignore")
+		if dockerfile != "" {
+			excludes = append(excludes, "!"+filepath.ToSlash(dockerfile))
+		}
+	}
+	pm, err := patternmatcher.New(excludes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern in .dockerignore: %w", err)
+	}
+
+	bc := &buildContext{dir: dir}
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		skip, err := pm.MatchesOrParentMatches(rel)
+		if err != nil {
+			return err
+		}
+		if skip {
+			if d.IsDir() && !keepsDescendants(pm, rel) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.Mode().IsRegular():
+			bc.Files++
+			bc.Size += info.Size()
+		case info.Mode()&os.ModeSymlink != 0:
+			bc.Files++
+		case !info.IsDir():
+			// Sockets, pipes and devices cannot be part of an image
+			return nil
+		}
+		bc.entries = append(bc.entries, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read build context %s: %w", dir, err)
+	}
+	return bc, nil
+}
+
+// readDockerignore returns the patterns of dir/.dockerignore, or none if it does not exist.
+func readDockerignore(dir string) ([]string, error) {
+	f, err := os.Open(filepath.Join(dir, ".dockerignore"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	excludes, err := ignorefile.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .dockerignore: %w", err)
+	}
+	return excludes, nil
+}
+
+// keepsDescendants reports whether an exception pattern such as !dir/file can
+// re-include something below the excluded directory dir, so it must still be walked.
+func keepsDescendants(pm *patternmatcher.PatternMatcher, dir string) bool {
+	if !pm.Exclusions() {
+		return false
+	}
+	dirSlash := dir + string(filepath.Separator)
+	for _, pattern := range pm.Patterns() {
+		if pattern.Exclusion() && strings.HasPrefix(pattern.String()+string(filepath.Separator), dirSlash) {
+			return true
+		}
+	}
+	return false
+}
+
+// addDockerfile adds a Dockerfile that is not part of the context directory under
+// a random name, which it returns. Like the Docker CLI, it also lists that name in
+// the .dockerignore sent to the daemon so the file does not end up in the image.
+func (bc *buildContext) addDockerfile(content []byte) string {
+	suffix := make([]byte, 10)
+	rand.Read(suffix)
+	bc.dockerfile = content
+	bc.dockerfileName = ".dockerfile." + hex.EncodeToString(suffix)
+	bc.Files++
+	bc.Size += int64(len(content))
+	return bc.dockerfileName
+}
+
+// Stream writes the context as a tar archive to a pipe while the daemon reads
+// it, so the context is never held in memory. Closing the reader stops the writer.
+func (bc *buildContext) Stream() io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(bc.writeTar(pw))
+	}()
+	return pr
+}
+
+func (bc *buildContext) writeTar(w io.Writer) error {
+	tw := tar.NewWriter(w)
+	for _, rel := range bc.entries {
+		if bc.dockerfileName != "" && rel == ".dockerignore" {
+			continue
+		}
+		if err := addTarEntry(tw, filepath.Join(bc.dir, rel), filepath.ToSlash(rel)); err != nil {
+			return err
+		}
+	}
+
+	if bc.dockerfileName != "" {
+		ignore, err := os.ReadFile(filepath.Join(bc.dir, ".dockerignore"))
+		if errors.Is(err, fs.ErrNotExist) {
+			ignore = []byte(".dockerignore")
+		} else if err != nil {
+			return err
+		}
+		ignore = append(ignore, "\n"+bc.dockerfileName+"\n"...)
+
+		if err := addTarContent(tw, bc.dockerfileName, bc.dockerfile); err != nil {
+			return err
+		}
+		if err := addTarContent(tw, ".dockerignore", ignore); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// addTarContent writes a generated file to the archive.
+func addTarContent(tw *tar.Writer, name string, content []byte) error {
+	header := &tar.Header{
+		Name:     name,
+		Mode:     0o600,
+		Size:     int64(len(content)),
+		ModTime:  time.Now(),
+		Typeflag: tar.TypeReg,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := tw.Write(content)
+	return err
+}
+
+// addTarEntry writes the file at path to the archive under name, owned by root as 'docker build' does.
+func addTarEntry(tw *tar.Writer, path, name string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	link := ""
+	if info.Mode()&os.ModeSymlink != 0 {
+		if link, err = os.Readlink(path); err != nil {
+			return err
+		}
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+	}
+	header.Uid, header.Gid = 0, 0
+	header.Uname, header.Gname = "", ""
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
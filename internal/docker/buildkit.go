@@ -0,0 +1,235 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/output"
+	"github.com/fatih/color"
+	"github.com/pterm/pterm"
+)
+
+// usesBuildKit reports whether the build needs BuildKit, because it was asked
+// for or because it uses a feature the classic builder does not have.
+func (opts BuildOptions) usesBuildKit() bool {
+	return opts.BuildKit || len(opts.Secrets) > 0 || len(opts.SSH) > 0 || len(opts.CacheFrom) > 0 || len(opts.CacheTo) > 0
+}
+
+// buildKitArgs returns the 'docker buildx build' arguments for opts, checking
+// that secret sources and SSH agents exist before the build starts.
+func buildKitArgs(tags []string, dockerfile, contextDir string, opts BuildOptions) ([]string, error) {
+	args := []string{"buildx", "build", "--progress=rawjson", "--load", "-f", dockerfile}
+	for _, tag := range tags {
+		args = append(args, "-t", tag)
+	}
+	if opts.NoCache {
+		args = append(args, "--no-cache")
+	}
+	for key, value := range opts.BuildArgs {
+		args = append(args, "--build-arg", key+"="+value)
+	}
+	if opts.Target != "" {
+		args = append(args, "--target", opts.Target)
+	}
+	if opts.Platform != "" {
+		args = append(args, "--platform", opts.Platform)
+	}
+	for key, value := range opts.Labels {
+		args = append(args, "--label", key+"="+value)
+	}
+
+	for _, secret := range opts.Secrets {
+		if err := checkSecret(secret); err != nil {
+			return nil, err
+		}
+		args = append(args, "--secret", secret)
+	}
+	for _, ssh := range opts.SSH {
+		if (ssh == "default" || ssh == "") && os.Getenv("SSH_AUTH_SOCK") == "" {
+			return nil, fmt.Errorf("--ssh default forwards the SSH agent, but SSH_AUTH_SOCK is not set")
+		}
+		args = append(args, "--ssh", ssh)
+	}
+	for _, cache := range opts.CacheFrom {
+		args = append(args, "--cache-from", cache)
+	}
+	for _, cache := range opts.CacheTo {
+		args = append(args, "--cache-to", cache)
+	}
+
+	return append(args, contextDir), nil
+}
+
+// checkSecret validates a secret spec such as id=npmrc,src=$HOME/.npmrc.
+func checkSecret(spec string) error {
+	fields := map[string]string{}
+	for _, field := range strings.Split(spec, ",") {
+		key, value, _ := strings.Cut(field, "=")
+		fields[strings.TrimSpace(key)] = value
+	}
+	if fields["id"] == "" {
+		return fmt.Errorf("invalid secret '%s': an id is required, as in id=NAME,src=FILE", spec)
+	}
+	src := fields["src"]
+	if src == "" {
+		src = fields["source"]
+	}
+	if src != "" {
+		if _, err := os.Stat(src); err != nil {
+			return fmt.Errorf("secret '%s' cannot be read: %w", fields["id"], err)
+		}
+	} else if env := fields["env"]; env != "" {
+		if _, ok := os.LookupEnv(env); !ok {
+			return fmt.Errorf("secret '%s' reads the environment variable %s, which is not set", fields["id"], env)
+		}
+	}
+	return nil
+}
+
+// buildWithBuildKit builds an image with 'docker buildx build' and loads it
+// into the local image store, printing the progress of each build step.
+func buildWithBuildKit(ctx context.Context, tags []string, bc *buildContext, dockerfile string, opts BuildOptions) error {
+	var stdin io.Reader
+	if bc.dockerfileName != "" {
+		// buildx reads a Dockerfile that is not in the context from stdin
+		dockerfile = "-"
+		stdin = bytes.NewReader(bc.dockerfile)
+	} else {
+		dockerfile = filepath.Join(bc.dir, filepath.FromSlash(dockerfile))
+	}
+
+	args, err := buildKitArgs(tags, dockerfile, bc.dir, opts)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdin = stdin
+	cmd.Stdout = output.Writer()
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	pterm.Info.Println("Building with BuildKit...")
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to run 'docker buildx build': %w", err)
+	}
+	progress := newSolveProgress()
+	messages := progress.read(stderr)
+
+	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			pterm.Warning.Println("Build cancelled, BuildKit stops the running steps")
+			return fmt.Errorf("build cancelled: %w", ctx.Err())
+		}
+		for _, msg := range messages {
+			pterm.Error.Println(msg)
+		}
+		if len(messages) > 0 {
+			return fmt.Errorf("BuildKit build failed: %s", messages[len(messages)-1])
+		}
+		return fmt.Errorf("BuildKit build failed: %w", err)
+	}
+
+	color.New(color.FgGreen).Printf("Successfully built %s\n", strings.Join(tags, ", "))
+	return nil
+}
+
+// solveStatus is one progress update written by 'docker buildx build --progress=rawjson'.
+type solveStatus struct {
+	Vertexes []struct {
+		Digest    string     `json:"digest"`
+		Name      string     `json:"name"`
+		Started   *time.Time `json:"started"`
+		Completed *time.Time `json:"completed"`
+		Cached    bool       `json:"cached"`
+		Error     string     `json:"error"`
+	} `json:"vertexes"`
+	Logs []struct {
+		Vertex string `json:"vertex"`
+		Data   []byte `json:"data"`
+	} `json:"logs"`
+	Warnings []struct {
+		Short []byte `json:"short"`
+	} `json:"warnings"`
+}
+
+// solveProgress prints build steps in the order BuildKit starts them,
+// numbered like the plain progress output of 'docker build'.
+type solveProgress struct {
+	steps   map[string]int
+	started map[string]bool
+	done    map[string]bool
+}
+
+func newSolveProgress() *solveProgress {
+	return &solveProgress{steps: map[string]int{}, started: map[string]bool{}, done: map[string]bool{}}
+}
+
+// read prints the progress from r until it is closed, and returns the lines
+// that were not progress updates, such as the error buildx reports on failure.
+func (p *solveProgress) read(r io.Reader) []string {
+	var messages []string
+	out := output.Writer()
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var status solveStatus
+		if err := json.Unmarshal(line, &status); err != nil {
+			if text := strings.TrimSpace(string(line)); text != "" {
+				messages = append(messages, text)
+			}
+			continue
+		}
+
+		for _, v := range status.Vertexes {
+			step := p.step(v.Digest)
+			if v.Started != nil && !p.started[v.Digest] {
+				p.started[v.Digest] = true
+				fmt.Fprintf(out, "#%d %s\n", step, v.Name)
+			}
+			if v.Error != "" && !p.done[v.Digest] {
+				p.done[v.Digest] = true
+				color.New(color.FgRed).Fprintf(out, "#%d ERROR: %s\n", step, v.Error)
+			} else if v.Cached && !p.done[v.Digest] {
+				p.done[v.Digest] = true
+				fmt.Fprintf(out, "#%d CACHED\n", step)
+			} else if v.Completed != nil && !p.done[v.Digest] {
+				p.done[v.Digest] = true
+				elapsed := ""
+				if v.Started != nil {
+					elapsed = fmt.Sprintf(" %.1fs", v.Completed.Sub(*v.Started).Seconds())
+				}
+				color.New(color.FgGreen).Fprintf(out, "#%d DONE%s\n", step, elapsed)
+			}
+		}
+		for _, l := range status.Logs {
+			step := p.step(l.Vertex)
+			for _, text := range strings.Split(strings.TrimRight(string(l.Data), "\n"), "\n") {
+				fmt.Fprintf(out, "#%d %s\n", step, text)
+			}
+		}
+		for _, w := range status.Warnings {
+			pterm.Warning.Println(string(w.Short))
+		}
+	}
+	return messages
+}
+
+func (p *solveProgress) step(digest string) int {
+	if _, ok := p.steps[digest]; !ok {
+		p.steps[digest] = len(p.steps) + 1
+	}
+	return p.steps[digest]
+}
@@ -0,0 +1,427 @@
+package docker
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"debug/buildinfo"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	// The SQLite driver rpmdb.sqlite is read with
+	_ "github.com/glebarez/go-sqlite"
+	rpmdb "github.com/knqyf263/go-rpmdb/pkg"
+)
+
+// Package is a software package found in an image.
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Type is the package ecosystem: deb, apk, rpm, golang, npm, pypi, maven or gem.
+	Type    string `json:"type"`
+	PURL    string `json:"purl"`
+	License string `json:"license,omitempty"`
+	// Location is the file the package was read from.
+	Location string `json:"location"`
+}
+
+// maxCatalogFile bounds the size of the binaries and archives read into memory.
+const maxCatalogFile = 256 << 20
+
+var (
+	npmManifest    = regexp.MustCompile(`(^|/)node_modules/(@[^/]+/)?[^/]+/package\.json$`)
+	pythonMetadata = regexp.MustCompile(`(\.dist-info/METADATA|\.egg-info/PKG-INFO|\.egg-info)$`)
+	gemSpec        = regexp.MustCompile(`(^|/)specifications/([^/]+)-([0-9][^/-]*)\.gemspec$`)
+	javaArchive    = regexp.MustCompile(`\.(jar|war|ear)$`)
+	rpmDatabase    = regexp.MustCompile(`^(var/lib/rpm|usr/lib/sysimage/rpm)/(Packages|Packages\.db|rpmdb\.sqlite)$`)
+	pythonNameSep  = regexp.MustCompile(`[-_.]+`)
+	pomProperties  = regexp.MustCompile(`^META-INF/maven/[^/]+/[^/]+/pom\.properties$`)
+)
+
+// catalog collects the packages of a flattened image filesystem.
+type catalog struct {
+	distroID      string
+	distroVersion string
+	packages      []Package
+	seen          map[string]bool
+	// osPackages holds dpkg, apk and rpm entries until the distribution is
+	// known, as os-release may come after the package database in the stream.
+	osPackages []osPackage
+}
+
+// osPackage is a dpkg, apk or rpm entry with its architecture and epoch.
+type osPackage struct {
+	Package
+	arch  string
+	epoch string
+}
+
+// catalogFilesystem inventories OS packages from the dpkg, apk and rpm
+// databases and language dependencies from Go binaries, node_modules, Python
+// metadata, Java archives and gem specifications in the tar stream of an image
+// filesystem. An rpm database that cannot be read fails the inventory rather
+// than leave its packages out.
+func catalogFilesystem(r io.Reader) (*catalog, error) {
+	c := &catalog{seen: map[string]bool{}}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the image filesystem: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/")
+		if err := c.addFile(name, hdr, tr); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+	}
+
+	for _, pkg := range c.osPackages {
+		c.add(c.distroPackage(pkg))
+	}
+	return c, nil
+}
+
+func (c *catalog) addFile(name string, hdr *tar.Header, r io.Reader) error {
+	switch {
+	case name == "etc/os-release" || (name == "usr/lib/os-release" && c.distroID == ""):
+		fields := parseKeyValues(r)
+		c.distroID, c.distroVersion = fields["ID"], fields["VERSION_ID"]
+	case name == "var/lib/dpkg/status" || (strings.HasPrefix(name, "var/lib/dpkg/status.d/") && !strings.HasSuffix(name, ".md5sums")):
+		c.osPackages = append(c.osPackages, parseDpkg(r, name)...)
+	case name == "lib/apk/db/installed":
+		c.osPackages = append(c.osPackages, parseApk(r, name)...)
+	case rpmDatabase.MatchString(name):
+		packages, err := parseRpmdb(r, name)
+		if err != nil {
+			return err
+		}
+		c.osPackages = append(c.osPackages, packages...)
+	case npmManifest.MatchString(name):
+		var manifest struct {
+			Name    string          `json:"name"`
+			Version string          `json:"version"`
+			License json.RawMessage `json:"license"`
+		}
+		if json.NewDecoder(r).Decode(&manifest) != nil || manifest.Name == "" {
+			return nil
+		}
+		var license string
+		if json.Unmarshal(manifest.License, &license) != nil {
+			var object struct{ Type string }
+			json.Unmarshal(manifest.License, &object)
+			license = object.Type
+		}
+		purlName := url.PathEscape(manifest.Name)
+		if scope, pkg, ok := strings.Cut(manifest.Name, "/"); ok {
+			purlName = "%40" + url.PathEscape(strings.TrimPrefix(scope, "@")) + "/" + url.PathEscape(pkg)
+		}
+		c.add(Package{Name: manifest.Name, Version: manifest.Version, Type: "npm", License: license, Location: name,
+			PURL: "pkg:npm/" + purlName + "@" + url.PathEscape(manifest.Version)})
+	case pythonMetadata.MatchString(name):
+		fields := parseKeyValues(r)
+		if fields["Name"] == "" {
+			return nil
+		}
+		license := fields["License-Expression"]
+		if license == "" {
+			license = fields["License"]
+		}
+		normalized := strings.ToLower(pythonNameSep.ReplaceAllString(fields["Name"], "-"))
+		c.add(Package{Name: fields["Name"], Version: fields["Version"], Type: "pypi", License: license, Location: name,
+			PURL: "pkg:pypi/" + url.PathEscape(normalized) + "@" + url.PathEscape(fields["Version"])})
+	case gemSpec.MatchString(name):
+		m := gemSpec.FindStringSubmatch(name)
+		c.add(Package{Name: m[2], Version: m[3], Type: "gem", Location: name,
+			PURL: "pkg:gem/" + url.PathEscape(m[2]) + "@" + url.PathEscape(m[3])})
+	case javaArchive.MatchString(name) && hdr.Size <= maxCatalogFile:
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		c.addJavaArchive(name, data)
+	case hdr.Mode&0111 != 0 && hdr.Size <= maxCatalogFile:
+		magic := make([]byte, 4)
+		if _, err := io.ReadFull(r, magic); err != nil || !isExecutable(magic) {
+			return nil
+		}
+		rest, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		c.addGoBinary(name, append(magic, rest...))
+	}
+	return nil
+}
+
+func (c *catalog) add(pkg Package) {
+	if c.seen[pkg.PURL] {
+		return
+	}
+	c.seen[pkg.PURL] = true
+	c.packages = append(c.packages, pkg)
+}
+
+// distroPackage sets the package URL of a dpkg, apk or rpm entry, qualified
+// with the distribution. The epoch of an rpm entry is a qualifier of the URL
+// and a prefix of the version.
+func (c *catalog) distroPackage(entry osPackage) Package {
+	pkg := entry.Package
+	namespace := c.distroID
+	if namespace == "" {
+		namespace = map[string]string{"deb": "debian", "apk": "alpine"}[pkg.Type]
+	}
+	qualifiers := url.Values{}
+	if entry.arch != "" {
+		qualifiers.Set("arch", entry.arch)
+	}
+	if c.distroID != "" && c.distroVersion != "" {
+		qualifiers.Set("distro", c.distroID+"-"+c.distroVersion)
+	}
+	if entry.epoch != "" {
+		qualifiers.Set("epoch", entry.epoch)
+		pkg.Version = entry.epoch + ":" + entry.Version
+	}
+	pkg.PURL = fmt.Sprintf("pkg:%s/%s@%s", pkg.Type, url.PathEscape(entry.Name), url.PathEscape(entry.Version))
+	if namespace != "" {
+		pkg.PURL = fmt.Sprintf("pkg:%s/%s/%s@%s", pkg.Type, namespace, url.PathEscape(entry.Name), url.PathEscape(entry.Version))
+	}
+	if len(qualifiers) > 0 {
+		pkg.PURL += "?" + qualifiers.Encode()
+	}
+	return pkg
+}
+
+// addGoBinary records the main module, dependencies and standard library of a Go binary.
+func (c *catalog) addGoBinary(name string, data []byte) {
+	info, err := buildinfo.Read(bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	goPackage := func(module, version string) Package {
+		return Package{Name: module, Version: version, Type: "golang", Location: name,
+			PURL: "pkg:golang/" + module + "@" + url.PathEscape(version)}
+	}
+	if info.Main.Path != "" {
+		c.add(goPackage(info.Main.Path, info.Main.Version))
+	}
+	for _, dep := range info.Deps {
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		c.add(goPackage(dep.Path, dep.Version))
+	}
+	c.add(goPackage("stdlib", strings.TrimPrefix(info.GoVersion, "go")))
+}
+
+// addJavaArchive records the Maven artifacts whose pom.properties are bundled
+// in a jar, war or ear, including those of the libraries nested in it, such as
+// the BOOT-INF/lib jars of a Spring Boot application. The location of a nested
+// library is the archive path followed by its path inside the archive.
+func (c *catalog) addJavaArchive(name string, data []byte) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return
+	}
+	for _, file := range archive.File {
+		if javaArchive.MatchString(file.Name) && file.UncompressedSize64 <= maxCatalogFile {
+			f, err := file.Open()
+			if err != nil {
+				continue
+			}
+			nested, err := io.ReadAll(f)
+			f.Close()
+			if err == nil {
+				c.addJavaArchive(name+":"+file.Name, nested)
+			}
+			continue
+		}
+		if !pomProperties.MatchString(file.Name) {
+			continue
+		}
+		f, err := file.Open()
+		if err != nil {
+			continue
+		}
+		props := parseKeyValues(f)
+		f.Close()
+		group, artifact, version := props["groupId"], props["artifactId"], props["version"]
+		if artifact == "" || version == "" {
+			continue
+		}
+		c.add(Package{Name: group + ":" + artifact, Version: version, Type: "maven", Location: name,
+			PURL: fmt.Sprintf("pkg:maven/%s/%s@%s", url.PathEscape(group), url.PathEscape(artifact), url.PathEscape(version))})
+	}
+}
+
+// isExecutable reports whether magic starts an ELF, Mach-O or PE binary.
+func isExecutable(magic []byte) bool {
+	switch {
+	case bytes.Equal(magic, []byte("\x7fELF")):
+		return true
+	case bytes.Equal(magic[:2], []byte("MZ")):
+		return true
+	case bytes.Equal(magic, []byte{0xcf, 0xfa, 0xed, 0xfe}), bytes.Equal(magic, []byte{0xce, 0xfa, 0xed, 0xfe}):
+		return true
+	}
+	return false
+}
+
+// parseDpkg reads the installed packages of a dpkg status file.
+func parseDpkg(r io.Reader, location string) []osPackage {
+	var packages []osPackage
+	for _, stanza := range splitStanzas(r) {
+		fields := parseKeyValues(strings.NewReader(stanza))
+		// The per-package files of distroless images have no Status field
+		if status, ok := fields["Status"]; ok && !strings.HasSuffix(status, " installed") {
+			continue
+		}
+		if fields["Package"] == "" {
+			continue
+		}
+		packages = append(packages, osPackage{
+			Package: Package{Name: fields["Package"], Version: fields["Version"], Type: "deb", Location: location},
+			arch:    fields["Architecture"],
+		})
+	}
+	return packages
+}
+
+// parseRpmdb reads the installed packages of an rpm database in the Berkeley
+// DB, NDB or SQLite format, which is copied to a temporary file to be opened.
+func parseRpmdb(r io.Reader, location string) ([]osPackage, error) {
+	tmp, err := os.CreateTemp("", "smurf-rpmdb-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := rpmdb.Open(tmp.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the rpm database: %w", err)
+	}
+	defer db.Close()
+	infos, err := db.ListPackages()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the rpm packages: %w", err)
+	}
+	var packages []osPackage
+	for _, info := range infos {
+		// Imported signing keys are recorded as gpg-pubkey packages
+		if info.Name == "" || info.Name == "gpg-pubkey" {
+			continue
+		}
+		pkg := osPackage{
+			Package: Package{Name: info.Name, Version: info.Version + "-" + info.Release, Type: "rpm", License: info.License, Location: location},
+			arch:    info.Arch,
+		}
+		if info.Epoch != nil && *info.Epoch != 0 {
+			pkg.epoch = strconv.Itoa(*info.Epoch)
+		}
+		packages = append(packages, pkg)
+	}
+	return packages, nil
+}
+
+// parseApk reads the installed database of apk, whose fields are single letters.
+func parseApk(r io.Reader, location string) []osPackage {
+	var packages []osPackage
+	for _, stanza := range splitStanzas(r) {
+		var pkg osPackage
+		for _, line := range strings.Split(stanza, "\n") {
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "P":
+				pkg.Name = value
+			case "V":
+				pkg.Version = value
+			case "A":
+				pkg.arch = value
+			case "L":
+				pkg.License = value
+			}
+		}
+		if pkg.Name != "" {
+			pkg.Type, pkg.Location = "apk", location
+			packages = append(packages, pkg)
+		}
+	}
+	return packages
+}
+
+// splitStanzas splits a file into blocks separated by blank lines.
+func splitStanzas(r io.Reader) []string {
+	var stanzas []string
+	var current strings.Builder
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			if current.Len() > 0 {
+				stanzas = append(stanzas, current.String())
+				current.Reset()
+			}
+			continue
+		}
+		current.WriteString(line)
+		current.WriteByte('\n')
+	}
+	if current.Len() > 0 {
+		stanzas = append(stanzas, current.String())
+	}
+	return stanzas
+}
+
+// parseKeyValues reads the leading "Key: value" or "KEY=value" lines of a file,
+// such as os-release, Python metadata headers or Java properties. Reading
+// stops at the first blank line after a field, where metadata bodies start.
+func parseKeyValues(r io.Reader) map[string]string {
+	fields := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			if len(fields) > 0 {
+				break
+			}
+			continue
+		}
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sep := strings.IndexAny(line, ":=")
+		if sep <= 0 {
+			continue
+		}
+		key, value := strings.TrimSpace(line[:sep]), strings.TrimSpace(line[sep+1:])
+		if _, ok := fields[key]; !ok {
+			fields[key] = strings.Trim(value, `"'`)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,364 @@
+package docker
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// file is an entry of a test image filesystem.
+type file struct {
+	name string
+	data []byte
+}
+
+// imageFilesystem returns the tar stream of files, in order.
+func imageFilesystem(t *testing.T, files ...file) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+// javaArchiveOf returns a zip archive of files, in order.
+func javaArchiveOf(t *testing.T, files ...file) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(f.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func pomFile(group, artifact, version string) file {
+	return file{
+		name: "META-INF/maven/" + group + "/" + artifact + "/pom.properties",
+		data: []byte("#Generated by Maven\ngroupId=" + group + "\nartifactId=" + artifact + "\nversion=" + version + "\n"),
+	}
+}
+
+// rpmPackage is the part of an rpm header the catalog reads.
+type rpmPackage struct {
+	name, version, release, arch, license string
+	epoch                                 int32
+}
+
+// header encodes the package as a v3 rpm header, which has no region tag.
+func (p rpmPackage) header() []byte {
+	var index, data bytes.Buffer
+	entry := func(tag, typ uint32, value []byte) {
+		binary.Write(&index, binary.BigEndian, []uint32{tag, typ, uint32(data.Len()), 1})
+		data.Write(value)
+	}
+	// The epoch comes first so that it is aligned without padding
+	if p.epoch != 0 {
+		entry(1003, 4, binary.BigEndian.AppendUint32(nil, uint32(p.epoch)))
+	}
+	for _, tag := range []struct {
+		tag   uint32
+		value string
+	}{{1000, p.name}, {1001, p.version}, {1002, p.release}, {1014, p.license}, {1022, p.arch}} {
+		entry(tag.tag, 6, append([]byte(tag.value), 0))
+	}
+	var header bytes.Buffer
+	binary.Write(&header, binary.BigEndian, []uint32{uint32(index.Len() / 16), uint32(data.Len())})
+	header.Write(index.Bytes())
+	header.Write(data.Bytes())
+	return header.Bytes()
+}
+
+// rpmdbSQLite returns an rpmdb.sqlite database of packages.
+func rpmdbSQLite(t *testing.T, packages ...rpmPackage) []byte {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rpmdb.sqlite")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE Packages (hnum INTEGER PRIMARY KEY AUTOINCREMENT, blob BLOB NOT NULL)"); err != nil {
+		t.Fatal(err)
+	}
+	for _, pkg := range packages {
+		if _, err := db.Exec("INSERT INTO Packages (blob) VALUES (?)", pkg.header()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseDpkg(t *testing.T) {
+	tests := []struct {
+		name   string
+		status string
+		want   []osPackage
+	}{
+		{
+			name: "installed",
+			status: "Package: curl\nStatus: install ok installed\nArchitecture: amd64\nVersion: 7.88.1-10\nDescription: a tool\n to transfer data\n\n" +
+				"Package: libc6\nStatus: install ok installed\nArchitecture: arm64\nVersion: 2.36-9\n",
+			want: []osPackage{
+				{Package: Package{Name: "curl", Version: "7.88.1-10", Type: "deb", Location: "var/lib/dpkg/status"}, arch: "amd64"},
+				{Package: Package{Name: "libc6", Version: "2.36-9", Type: "deb", Location: "var/lib/dpkg/status"}, arch: "arm64"},
+			},
+		},
+		{
+			name: "removed with its configuration left",
+			status: "Package: vim\nStatus: deinstall ok config-files\nVersion: 9.0\n\n" +
+				"Package: nano\nStatus: install ok half-installed\nVersion: 7.2\n\n" +
+				"Package: bash\nStatus: install ok installed\nVersion: 5.2\n",
+			want: []osPackage{
+				{Package: Package{Name: "bash", Version: "5.2", Type: "deb", Location: "var/lib/dpkg/status"}},
+			},
+		},
+		{
+			name:   "distroless entry without a status",
+			status: "Package: tzdata\nVersion: 2024a-0\nArchitecture: all\n",
+			want: []osPackage{
+				{Package: Package{Name: "tzdata", Version: "2024a-0", Type: "deb", Location: "var/lib/dpkg/status"}, arch: "all"},
+			},
+		},
+		{
+			name:   "stanza without a package name",
+			status: "Status: install ok installed\nVersion: 1.0\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseDpkg(strings.NewReader(tt.status), "var/lib/dpkg/status")
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseDpkg = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseApk(t *testing.T) {
+	tests := []struct {
+		name      string
+		installed string
+		want      []osPackage
+	}{
+		{
+			name: "packages",
+			installed: "C:Q1abc=\nP:musl\nV:1.2.4-r2\nA:x86_64\nS:383152\nL:MIT\n\n" +
+				"P:busybox\nV:1.36.1-r5\nA:aarch64\nL:GPL-2.0-only\n",
+			want: []osPackage{
+				{Package: Package{Name: "musl", Version: "1.2.4-r2", Type: "apk", License: "MIT", Location: "lib/apk/db/installed"}, arch: "x86_64"},
+				{Package: Package{Name: "busybox", Version: "1.36.1-r5", Type: "apk", License: "GPL-2.0-only", Location: "lib/apk/db/installed"}, arch: "aarch64"},
+			},
+		},
+		{
+			name:      "stanza without a package name",
+			installed: "V:1.0\nA:x86_64\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseApk(strings.NewReader(tt.installed), "lib/apk/db/installed")
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseApk = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDistroPackage(t *testing.T) {
+	tests := []struct {
+		name          string
+		distroID      string
+		distroVersion string
+		entry         osPackage
+		wantVersion   string
+		wantPURL      string
+	}{
+		{
+			name:     "deb with os-release",
+			distroID: "debian", distroVersion: "12",
+			entry:       osPackage{Package: Package{Name: "curl", Version: "7.88.1-10+deb12u5", Type: "deb"}, arch: "amd64"},
+			wantVersion: "7.88.1-10+deb12u5",
+			wantPURL:    "pkg:deb/debian/curl@7.88.1-10+deb12u5?arch=amd64&distro=debian-12",
+		},
+		{
+			name:        "deb without os-release",
+			entry:       osPackage{Package: Package{Name: "libc6", Version: "2.36-9", Type: "deb"}, arch: "arm64"},
+			wantVersion: "2.36-9",
+			wantPURL:    "pkg:deb/debian/libc6@2.36-9?arch=arm64",
+		},
+		{
+			name:        "apk without os-release or architecture",
+			entry:       osPackage{Package: Package{Name: "musl", Version: "1.2.4-r2", Type: "apk"}},
+			wantVersion: "1.2.4-r2",
+			wantPURL:    "pkg:apk/alpine/musl@1.2.4-r2",
+		},
+		{
+			name:        "distribution without a version",
+			distroID:    "wolfi",
+			entry:       osPackage{Package: Package{Name: "glibc", Version: "2.39-r1", Type: "apk"}, arch: "x86_64"},
+			wantVersion: "2.39-r1",
+			wantPURL:    "pkg:apk/wolfi/glibc@2.39-r1?arch=x86_64",
+		},
+		{
+			name:     "rpm with an epoch",
+			distroID: "amzn", distroVersion: "2023",
+			entry:       osPackage{Package: Package{Name: "openssl-libs", Version: "3.0.8-1.amzn2023.0.14", Type: "rpm"}, arch: "x86_64", epoch: "1"},
+			wantVersion: "1:3.0.8-1.amzn2023.0.14",
+			wantPURL:    "pkg:rpm/amzn/openssl-libs@3.0.8-1.amzn2023.0.14?arch=x86_64&distro=amzn-2023&epoch=1",
+		},
+		{
+			name:        "rpm without os-release",
+			entry:       osPackage{Package: Package{Name: "bash", Version: "5.2.15-5.fc40", Type: "rpm"}, arch: "aarch64"},
+			wantVersion: "5.2.15-5.fc40",
+			wantPURL:    "pkg:rpm/bash@5.2.15-5.fc40?arch=aarch64",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &catalog{distroID: tt.distroID, distroVersion: tt.distroVersion}
+			pkg := c.distroPackage(tt.entry)
+			if pkg.Version != tt.wantVersion || pkg.PURL != tt.wantPURL {
+				t.Errorf("distroPackage = %s %s, want %s %s", pkg.Version, pkg.PURL, tt.wantVersion, tt.wantPURL)
+			}
+		})
+	}
+}
+
+func TestCatalogFilesystem(t *testing.T) {
+	osRelease := func(id, version string) file {
+		return file{name: "etc/os-release", data: []byte("NAME=\"Test\"\nID=" + id + "\nVERSION_ID=\"" + version + "\"\n")}
+	}
+	springBoot := javaArchiveOf(t,
+		pomFile("com.example", "app", "1.0.0"),
+		file{name: "BOOT-INF/lib/spring-core-6.1.5.jar", data: javaArchiveOf(t, pomFile("org.springframework", "spring-core", "6.1.5"))},
+		file{name: "BOOT-INF/lib/jackson-databind-2.17.0.jar", data: javaArchiveOf(t,
+			pomFile("com.fasterxml.jackson.core", "jackson-databind", "2.17.0"),
+			file{name: "META-INF/versions/lib/shaded.jar", data: javaArchiveOf(t, pomFile("org.shaded", "inner", "0.1"))},
+		)},
+	)
+
+	tests := []struct {
+		name    string
+		files   []file
+		want    []Package
+		wantErr string
+	}{
+		{
+			name: "npm packages",
+			files: []file{
+				{name: "app/node_modules/@types/node/package.json", data: []byte(`{"name":"@types/node","version":"20.11.5","license":"MIT"}`)},
+				{name: "app/node_modules/left-pad/package.json", data: []byte(`{"name":"left-pad","version":"1.3.0","license":{"type":"WTFPL"}}`)},
+				{name: "app/node_modules/broken/package.json", data: []byte(`{"version":"1.0.0"}`)},
+			},
+			want: []Package{
+				{Name: "@types/node", Version: "20.11.5", Type: "npm", License: "MIT", Location: "app/node_modules/@types/node/package.json", PURL: "pkg:npm/%40types/node@20.11.5"},
+				{Name: "left-pad", Version: "1.3.0", Type: "npm", License: "WTFPL", Location: "app/node_modules/left-pad/package.json", PURL: "pkg:npm/left-pad@1.3.0"},
+			},
+		},
+		{
+			name: "python packages",
+			files: []file{
+				{name: "usr/lib/python3/site-packages/Flask_SQLAlchemy-3.1.1.dist-info/METADATA", data: []byte("Metadata-Version: 2.1\nName: Flask_SQLAlchemy\nVersion: 3.1.1\nLicense-Expression: BSD-3-Clause\n\nName: body\n")},
+				{name: "usr/lib/python3/site-packages/zope.interface-6.2.egg-info/PKG-INFO", data: []byte("Name: zope.interface\nVersion: 6.2\nLicense: ZPL 2.1\n")},
+			},
+			want: []Package{
+				{Name: "Flask_SQLAlchemy", Version: "3.1.1", Type: "pypi", License: "BSD-3-Clause", Location: "usr/lib/python3/site-packages/Flask_SQLAlchemy-3.1.1.dist-info/METADATA", PURL: "pkg:pypi/flask-sqlalchemy@3.1.1"},
+				{Name: "zope.interface", Version: "6.2", Type: "pypi", License: "ZPL 2.1", Location: "usr/lib/python3/site-packages/zope.interface-6.2.egg-info/PKG-INFO", PURL: "pkg:pypi/zope-interface@6.2"},
+			},
+		},
+		{
+			name:  "java archive with nested libraries",
+			files: []file{{name: "app/app.jar", data: springBoot}},
+			want: []Package{
+				{Name: "com.example:app", Version: "1.0.0", Type: "maven", Location: "app/app.jar", PURL: "pkg:maven/com.example/app@1.0.0"},
+				{Name: "org.springframework:spring-core", Version: "6.1.5", Type: "maven", Location: "app/app.jar:BOOT-INF/lib/spring-core-6.1.5.jar", PURL: "pkg:maven/org.springframework/spring-core@6.1.5"},
+				{Name: "com.fasterxml.jackson.core:jackson-databind", Version: "2.17.0", Type: "maven", Location: "app/app.jar:BOOT-INF/lib/jackson-databind-2.17.0.jar", PURL: "pkg:maven/com.fasterxml.jackson.core/jackson-databind@2.17.0"},
+				{Name: "org.shaded:inner", Version: "0.1", Type: "maven", Location: "app/app.jar:BOOT-INF/lib/jackson-databind-2.17.0.jar:META-INF/versions/lib/shaded.jar", PURL: "pkg:maven/org.shaded/inner@0.1"},
+			},
+		},
+		{
+			name: "dpkg database before os-release",
+			files: []file{
+				{name: "var/lib/dpkg/status", data: []byte("Package: curl\nStatus: install ok installed\nArchitecture: amd64\nVersion: 7.81.0-1ubuntu1.16\n")},
+				osRelease("ubuntu", "22.04"),
+			},
+			want: []Package{
+				{Name: "curl", Version: "7.81.0-1ubuntu1.16", Type: "deb", Location: "var/lib/dpkg/status", PURL: "pkg:deb/ubuntu/curl@7.81.0-1ubuntu1.16?arch=amd64&distro=ubuntu-22.04"},
+			},
+		},
+		{
+			name: "rpm database",
+			files: []file{
+				osRelease("rhel", "9.4"),
+				{name: "var/lib/rpm/rpmdb.sqlite", data: rpmdbSQLite(t,
+					rpmPackage{name: "bash", version: "5.1.8", release: "9.el9", arch: "x86_64", license: "GPLv3+"},
+					rpmPackage{name: "openssl-libs", version: "3.0.7", release: "27.el9", arch: "x86_64", license: "ASL 2.0", epoch: 1},
+					rpmPackage{name: "gpg-pubkey", version: "fd431d51", release: "4ae0493b"},
+				)},
+			},
+			want: []Package{
+				{Name: "bash", Version: "5.1.8-9.el9", Type: "rpm", License: "GPLv3+", Location: "var/lib/rpm/rpmdb.sqlite", PURL: "pkg:rpm/rhel/bash@5.1.8-9.el9?arch=x86_64&distro=rhel-9.4"},
+				{Name: "openssl-libs", Version: "1:3.0.7-27.el9", Type: "rpm", License: "ASL 2.0", Location: "var/lib/rpm/rpmdb.sqlite", PURL: "pkg:rpm/rhel/openssl-libs@3.0.7-27.el9?arch=x86_64&distro=rhel-9.4&epoch=1"},
+			},
+		},
+		{
+			name: "unreadable rpm database",
+			files: []file{
+				osRelease("fedora", "40"),
+				{name: "usr/lib/sysimage/rpm/rpmdb.sqlite", data: []byte("SQLite format 3\x00 but truncated")},
+			},
+			wantErr: "usr/lib/sysimage/rpm/rpmdb.sqlite",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := catalogFilesystem(imageFilesystem(t, tt.files...))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("catalogFilesystem error = %v, want one about %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(c.packages, tt.want) {
+				t.Errorf("packages = %+v\nwant %+v", c.packages, tt.want)
+			}
+		})
+	}
+}
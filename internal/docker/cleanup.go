@@ -0,0 +1,496 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/dryrun"
+	"github.com/clouddrove/smurf/internal/output"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pterm/pterm"
+)
+
+// artifactTag matches the tags cosign and the OCI referrers fallback store
+// signatures, attestations and SBOMs under. They belong to the image they
+// refer to and are never treated as images of their own.
+var artifactTag = regexp.MustCompile(`^sha256-[a-f0-9]{64}(\.[a-z]+)?$`)
+
+// listConcurrency limits the manifests read at once by registries that have
+// no API listing images with their push times.
+const listConcurrency = 8
+
+// RemoteImage is a manifest in a remote repository.
+type RemoteImage struct {
+	Digest    string   `json:"digest"`
+	Tags      []string `json:"tags,omitempty"`
+	MediaType string   `json:"mediaType,omitempty"`
+	// Pushed is when the image was pushed or, where the registry does not
+	// record it, when the image was built.
+	Pushed time.Time `json:"pushed"`
+}
+
+// RetentionPolicy selects the images of a repository to delete. A tagged image
+// is deleted only when it is not among the KeepLast most recent ones, has no
+// tag matching a KeepTags pattern and is older than OlderThan; rules that are
+// not set do not keep anything. Untagged manifests are deleted when Untagged is
+// set and they are older than OlderThan. Platform images, signatures and SBOMs
+// are not selected on their own but deleted with the image they belong to.
+type RetentionPolicy struct {
+	KeepLast  int
+	KeepTags  []string
+	OlderThan time.Duration
+	Untagged  bool
+}
+
+// CleanupItem is an image a cleanup deletes, with the rule that selected it.
+type CleanupItem struct {
+	RemoteImage
+	Reason string `json:"reason"`
+}
+
+// CleanupPlan lists the images of a repository a retention policy deletes.
+type CleanupPlan struct {
+	Repository string        `json:"repository"`
+	Kept       int           `json:"kept"`
+	Delete     []CleanupItem `json:"delete"`
+	// Deleted counts the images deleted once the plan is applied.
+	Deleted int `json:"deleted"`
+}
+
+// ParseAge parses an age such as 30d, 12h or 90m.
+func ParseAge(age string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(age, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age '%s' (expected e.g. 30d or 12h)", age)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(age)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age '%s' (expected e.g. 30d or 12h)", age)
+	}
+	return d, nil
+}
+
+// PlanCleanup authenticates with reg and works out which images of repository
+// policy deletes. Nothing is deleted until the plan is applied.
+func PlanCleanup(ctx context.Context, reg Registry, repository string, policy RetentionPolicy) (*CleanupPlan, error) {
+	deleteTagged := policy.KeepLast > 0 || policy.OlderThan > 0
+	if !deleteTagged && !policy.Untagged {
+		return nil, errors.New("no retention rule given: set a number of images to keep, a minimum age or untagged deletion")
+	}
+	if !deleteTagged && len(policy.KeepTags) > 0 {
+		return nil, errors.New("tag patterns to keep need a number of images to keep or a minimum age")
+	}
+	keepTags := make([]*regexp.Regexp, len(policy.KeepTags))
+	for i, pattern := range policy.KeepTags {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag pattern '%s': %w", pattern, err)
+		}
+		keepTags[i] = re
+	}
+
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Authenticating with %s...", reg.Name()))
+	if err := reg.Authenticate(ctx); err != nil {
+		spinner.Fail(fmt.Sprintf("Failed to authenticate with %s", reg.Name()))
+		return nil, fmt.Errorf("failed to authenticate with %s: %w", reg.Name(), err)
+	}
+	spinner.Success(fmt.Sprintf("Authenticated with %s", reg.Name()))
+
+	spinner, _ = pterm.DefaultSpinner.Start(fmt.Sprintf("Listing images of %s...", repository))
+	images, err := reg.ListImages(ctx, repository)
+	if err != nil {
+		spinner.Fail(fmt.Sprintf("Failed to list images of %s", repository))
+		return nil, err
+	}
+	spinner.Success(fmt.Sprintf("Found %d images in %s", len(images), repository))
+
+	// Manifests that belong to another one, such as the platform images of a
+	// multi-platform image and the signatures and SBOMs of an image, are not
+	// ranked on their own: they stay as long as what they belong to does
+	owners, err := manifestOwners(ctx, reg, repository, images)
+	if err != nil {
+		return nil, err
+	}
+
+	var tagged, untagged []RemoteImage
+	for _, img := range images {
+		if len(owners[img.Digest]) > 0 {
+			continue
+		}
+		var tags []string
+		for _, tag := range img.Tags {
+			if !artifactTag.MatchString(tag) {
+				tags = append(tags, tag)
+			}
+		}
+		switch {
+		case len(tags) > 0:
+			img.Tags = tags
+			tagged = append(tagged, img)
+		case len(img.Tags) == 0:
+			untagged = append(untagged, img)
+		}
+	}
+	sort.SliceStable(tagged, func(i, j int) bool { return tagged[i].Pushed.After(tagged[j].Pushed) })
+
+	plan := &CleanupPlan{Repository: repository, Delete: []CleanupItem{}}
+	now := time.Now()
+	for i, img := range tagged {
+		old := policy.OlderThan == 0 || olderThan(img, policy.OlderThan, now)
+		if !deleteTagged || (policy.KeepLast > 0 && i < policy.KeepLast) || !old || matchesAny(keepTags, img.Tags) {
+			continue
+		}
+		var reasons []string
+		if policy.KeepLast > 0 {
+			reasons = append(reasons, fmt.Sprintf("not among the last %d", policy.KeepLast))
+		}
+		if policy.OlderThan > 0 {
+			reasons = append(reasons, "older than "+formatAge(policy.OlderThan))
+		}
+		plan.Delete = append(plan.Delete, CleanupItem{RemoteImage: img, Reason: strings.Join(reasons, ", ")})
+	}
+	if policy.Untagged {
+		for _, img := range untagged {
+			if policy.OlderThan > 0 && !olderThan(img, policy.OlderThan, now) {
+				continue
+			}
+			plan.Delete = append(plan.Delete, CleanupItem{RemoteImage: img, Reason: "untagged"})
+		}
+	}
+
+	// A manifest that belongs to others goes once all of them go, with every
+	// tag it has, including the sha256-<digest> tags of signatures and SBOMs
+	deleted := map[string]bool{}
+	for _, item := range plan.Delete {
+		deleted[item.Digest] = true
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, img := range images {
+			belongsTo := owners[img.Digest]
+			if deleted[img.Digest] || len(belongsTo) == 0 || !allDeleted(belongsTo, deleted) {
+				continue
+			}
+			deleted[img.Digest] = true
+			changed = true
+			plan.Delete = append(plan.Delete, CleanupItem{RemoteImage: img, Reason: "belongs to " + shortDigest(belongsTo[0])})
+		}
+	}
+	plan.Kept = len(images) - len(plan.Delete)
+	return plan, nil
+}
+
+// ApplyCleanup deletes the images of plan from reg, deleting every tag of a
+// tagged image. A tag that is gone after another tag of the same image was
+// deleted counts as deleted. It carries on past failures and reports them at
+// the end.
+func ApplyCleanup(ctx context.Context, reg Registry, plan *CleanupPlan) error {
+	failed := 0
+	for _, item := range plan.Delete {
+		var err error
+		if len(item.Tags) == 0 {
+			err = reg.DeleteDigest(ctx, plan.Repository, item.Digest)
+		}
+		for i, tag := range item.Tags {
+			err = reg.Delete(ctx, plan.Repository, tag)
+			// A registry that only deletes by digest removed the other tags of the
+			// image along with the first one
+			var terr *transport.Error
+			if i > 0 && errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+				err = nil
+			}
+			if err != nil {
+				break
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("cleanup cancelled after deleting %d images: %w", plan.Deleted, ctx.Err())
+			}
+			pterm.Error.Println(err)
+			failed++
+			continue
+		}
+		plan.Deleted++
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to delete %d of %d images from %s", failed, len(plan.Delete), plan.Repository)
+	}
+	if !dryrun.Enabled() {
+		pterm.Success.Printf("Deleted %d images from %s\n", plan.Deleted, plan.Repository)
+	}
+	return nil
+}
+
+// PrintCleanupPlan previews the images a cleanup deletes.
+func PrintCleanupPlan(plan *CleanupPlan) {
+	if len(plan.Delete) == 0 {
+		pterm.Info.Printf("Nothing to delete in %s, %d images kept\n", plan.Repository, plan.Kept)
+		return
+	}
+	data := pterm.TableData{{"Digest", "Tags", "Pushed", "Reason"}}
+	for _, item := range plan.Delete {
+		pushed := "unknown"
+		if !item.Pushed.IsZero() {
+			pushed = item.Pushed.Local().Format(time.DateTime)
+		}
+		data = append(data, []string{shortDigest(item.Digest), strings.Join(item.Tags, ", "), pushed, item.Reason})
+	}
+	pterm.DefaultTable.WithHasHeader().WithWriter(output.Writer()).WithData(data).Render()
+	pterm.Info.Printf("%d images to delete from %s, %d kept\n", len(plan.Delete), plan.Repository, plan.Kept)
+}
+
+// ListImages reads the manifest of every tag, since the registry API does not
+// list images. The build time in the image configuration stands in for the
+// push time, and untagged manifests cannot be found. The sha256-<digest> tags
+// of signatures and SBOMs are only resolved to their digest.
+func (b *baseRegistry) ListImages(ctx context.Context, repository string) ([]RemoteImage, error) {
+	repo, err := name.NewRepository(fmt.Sprintf("%s/%s", b.host, repository), b.nameOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository '%s': %w", repository, err)
+	}
+	tags, err := b.ListTags(ctx, repository)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		byDigest = map[string]*RemoteImage{}
+		slots    = make(chan struct{}, listConcurrency)
+	)
+	for _, tag := range tags {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(tag string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			var img *RemoteImage
+			var err error
+			if artifactTag.MatchString(tag) {
+				img, err = b.headTag(ctx, repo.Tag(tag))
+			} else {
+				img, err = b.describeTag(ctx, repo.Tag(tag))
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			if existing, ok := byDigest[img.Digest]; ok {
+				existing.Tags = append(existing.Tags, tag)
+				return
+			}
+			byDigest[img.Digest] = img
+		}(tag)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	images := make([]RemoteImage, 0, len(byDigest))
+	for _, img := range byDigest {
+		sort.Strings(img.Tags)
+		images = append(images, *img)
+	}
+	return images, nil
+}
+
+// describeTag returns the image a tag points to, with the build time of the
+// image or, for a multi-platform image, of its first platform.
+func (b *baseRegistry) describeTag(ctx context.Context, tag name.Tag) (*RemoteImage, error) {
+	desc, err := remote.Get(tag, b.remoteOptions(ctx)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", tag, err)
+	}
+	img := &RemoteImage{Digest: desc.Digest.String(), MediaType: string(desc.MediaType), Tags: []string{tag.TagStr()}}
+	if desc.MediaType.IsIndex() {
+		index, err := desc.ImageIndex()
+		if err != nil {
+			return nil, err
+		}
+		manifest, err := index.IndexManifest()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", tag, err)
+		}
+		if len(manifest.Manifests) == 0 {
+			return img, nil
+		}
+		child, err := index.Image(manifest.Manifests[0].Digest)
+		if err != nil {
+			return nil, err
+		}
+		if config, err := child.ConfigFile(); err == nil {
+			img.Pushed = config.Created.Time
+		}
+		return img, nil
+	}
+	if !desc.MediaType.IsImage() {
+		return img, nil
+	}
+	image, err := desc.Image()
+	if err != nil {
+		return nil, err
+	}
+	if config, err := image.ConfigFile(); err == nil {
+		img.Pushed = config.Created.Time
+	}
+	return img, nil
+}
+
+// headTag returns the manifest a tag points to without reading it.
+func (b *baseRegistry) headTag(ctx context.Context, tag name.Tag) (*RemoteImage, error) {
+	desc, err := remote.Head(tag, b.remoteOptions(ctx)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", tag, err)
+	}
+	return &RemoteImage{Digest: desc.Digest.String(), MediaType: string(desc.MediaType), Tags: []string{tag.TagStr()}}, nil
+}
+
+func (b *baseRegistry) DeleteDigest(ctx context.Context, repository, digest string) error {
+	ref := fmt.Sprintf("%s/%s@%s", b.host, repository, digest)
+	if dryrun.Enabled() {
+		dryrun.Record("docker", "delete", ref, "untagged manifest")
+		return nil
+	}
+	digestRef, err := name.NewDigest(ref, b.nameOptions()...)
+	if err != nil {
+		return fmt.Errorf("invalid reference '%s': %w", ref, err)
+	}
+	if err := remote.Delete(digestRef, b.remoteOptions(ctx)...); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", ref, err)
+	}
+	pterm.Success.Println("Deleted remote manifest:", ref)
+	return nil
+}
+
+// manifestOwners returns, for each manifest among images that belongs to
+// others, the digests of those. A manifest belongs to the image indexes
+// listing it, to the image its subject field refers to, and, when all of its
+// tags are sha256-<digest> tags, to the image with that digest.
+func manifestOwners(ctx context.Context, reg Registry, repository string, images []RemoteImage) (map[string][]string, error) {
+	owners := map[string][]string{}
+	add := func(digest, owner string) {
+		if digest != owner && !slices.Contains(owners[digest], owner) {
+			owners[digest] = append(owners[digest], owner)
+		}
+	}
+	client, ok := reg.(remoteClient)
+	if !ok {
+		return owners, nil
+	}
+	base := strings.TrimSuffix(reg.Reference(repository, "latest"), ":latest")
+
+	for _, img := range images {
+		if img.Digest == "" {
+			continue
+		}
+		artifact := len(img.Tags) > 0
+		for _, tag := range img.Tags {
+			if !artifactTag.MatchString(tag) {
+				artifact = false
+				break
+			}
+		}
+		if artifact {
+			for _, tag := range img.Tags {
+				subject, _, _ := strings.Cut(tag, ".")
+				add(img.Digest, strings.Replace(subject, "-", ":", 1))
+			}
+		}
+
+		// Only indexes list other manifests, and only untagged manifests are
+		// referrers, such as SBOMs pushed with a subject
+		if !types.MediaType(img.MediaType).IsIndex() && len(img.Tags) > 0 {
+			continue
+		}
+		ref, err := name.NewDigest(base+"@"+img.Digest, client.nameOptions()...)
+		if err != nil {
+			return nil, fmt.Errorf("invalid reference '%s@%s': %w", base, img.Digest, err)
+		}
+		desc, err := remote.Get(ref, client.remoteOptions(ctx)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", ref, err)
+		}
+		var manifest struct {
+			Manifests []v1.Descriptor `json:"manifests"`
+			Subject   *v1.Descriptor  `json:"subject"`
+		}
+		if err := json.Unmarshal(desc.Manifest, &manifest); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", ref, err)
+		}
+		for _, child := range manifest.Manifests {
+			add(child.Digest.String(), img.Digest)
+		}
+		if manifest.Subject != nil {
+			add(img.Digest, manifest.Subject.Digest.String())
+		}
+	}
+	return owners, nil
+}
+
+// allDeleted reports whether every digest in digests is deleted.
+func allDeleted(digests []string, deleted map[string]bool) bool {
+	for _, digest := range digests {
+		if !deleted[digest] {
+			return false
+		}
+	}
+	return true
+}
+
+// olderThan reports whether img was pushed more than age ago. An image whose
+// push time is unknown is never old enough.
+func olderThan(img RemoteImage, age time.Duration, now time.Time) bool {
+	return !img.Pushed.IsZero() && now.Sub(img.Pushed) > age
+}
+
+func matchesAny(patterns []*regexp.Regexp, tags []string) bool {
+	for _, re := range patterns {
+		for _, tag := range tags {
+			if re.MatchString(tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// formatAge formats a duration parsed by ParseAge the way it is usually written.
+func formatAge(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%d days", d/(24*time.Hour))
+	}
+	return d.String()
+}
+
+func shortDigest(digest string) string {
+	if _, hex, ok := strings.Cut(digest, ":"); ok && len(hex) > 12 {
+		return hex[:12]
+	}
+	return digest
+}
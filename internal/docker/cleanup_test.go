@@ -0,0 +1,265 @@
+package docker
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// writeCreated pushes a random image built at created to the registry as ref
+// and returns its digest.
+func (reg *testRegistry) writeCreated(t *testing.T, ref string, created time.Time) string {
+	t.Helper()
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err = mutate.CreatedAt(img, v1.Time{Time: created})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(mustReference(t, ref), img, reg.auth()); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return digest.String()
+}
+
+// exists reports whether ref still resolves in the registry.
+func (reg *testRegistry) exists(t *testing.T, ref string) bool {
+	t.Helper()
+	_, err := remote.Head(mustReference(t, ref), reg.auth())
+	return err == nil
+}
+
+// attachArtifacts signs subject and pushes an SBOM referring to it, returning
+// the digest of the SBOM.
+func attachArtifacts(t *testing.T, reg *GenericRegistry, subject string) string {
+	t.Helper()
+	ctx := context.Background()
+	payload := []byte(`{"critical":{"identity":{"docker-reference":"` + subject + `"}}}`)
+	if _, err := reg.PushSignature(ctx, subject, payload, "c2lnbmF0dXJl"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := reg.PushReferrer(ctx, subject, "application/spdx+json", []byte(`{"spdxVersion":"SPDX-2.3"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result.Digest
+}
+
+// referrersTag returns the tag referrers of digest are listed under by
+// registries without the referrers API.
+func referrersTag(digest string) string {
+	return strings.Replace(digest, ":", "-", 1)
+}
+
+func deletedDigests(plan *CleanupPlan) []string {
+	var digests []string
+	for _, item := range plan.Delete {
+		digests = append(digests, item.Digest)
+	}
+	return digests
+}
+
+func TestCleanupKeepsPartsOfKeptImages(t *testing.T) {
+	clearCredentials(t)
+	reg := newTestRegistry(t, "", "", "")
+	generic := NewGenericRegistry(reg.host, "", "", "")
+	ctx := context.Background()
+	repo := reg.host + "/team/myapp"
+	now := time.Now()
+
+	v1Digest := reg.writeCreated(t, repo+":v1", now.Add(-30*24*time.Hour))
+	attachArtifacts(t, generic, repo+"@"+v1Digest)
+	v2Digest := reg.writeCreated(t, repo+":v2", now.Add(-20*24*time.Hour))
+
+	// The platform images are as recent as the index and must not use up the
+	// one slot --keep-last leaves
+	var platforms []PlatformImage
+	for _, platform := range []string{"linux/amd64", "linux/arm64"} {
+		ref := PlatformReference(repo+":v3", platform)
+		reg.writeCreated(t, ref, now.Add(-24*time.Hour))
+		platforms = append(platforms, PlatformImage{Platform: platform, Ref: ref})
+	}
+	index, err := generic.PushIndex(ctx, repo+":v3", platforms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attachArtifacts(t, generic, repo+"@"+index.Digest)
+
+	plan, err := PlanCleanup(ctx, generic, "team/myapp", RetentionPolicy{KeepLast: 1, Untagged: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted := deletedDigests(plan)
+	if len(deleted) != 4 || deleted[0] != v2Digest || deleted[1] != v1Digest {
+		t.Errorf("deleting %v, want %s and %s with the signature and SBOM index of %[3]s", deleted, v2Digest, v1Digest)
+	}
+	// The index, its two platform images, its signature and its SBOM index
+	if plan.Kept != 5 {
+		t.Errorf("kept %d images, want 5", plan.Kept)
+	}
+
+	if err := ApplyCleanup(ctx, generic, plan); err != nil {
+		t.Fatal(err)
+	}
+	tags, err := generic.ListTags(ctx, "team/myapp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		referrersTag(index.Digest),
+		signatureTag(index.Digest),
+		"v3",
+		"v3-linux-amd64",
+		"v3-linux-arm64",
+	}
+	slices.Sort(tags)
+	if !slices.Equal(tags, want) {
+		t.Errorf("tags after cleanup = %v, want %v", tags, want)
+	}
+}
+
+// referrersRegistry lists untagged manifests next to the tags, like the ECR
+// API does, for a registry that stores referrers without a fallback tag.
+type referrersRegistry struct {
+	*GenericRegistry
+	untagged []RemoteImage
+}
+
+func (r *referrersRegistry) ListImages(ctx context.Context, repository string) ([]RemoteImage, error) {
+	images, err := r.GenericRegistry.ListImages(ctx, repository)
+	return append(images, r.untagged...), err
+}
+
+func TestCleanupKeepsReferrersOfKeptImages(t *testing.T) {
+	clearCredentials(t)
+	reg := newTestRegistry(t, "", "", "")
+	generic := NewGenericRegistry(reg.host, "", "", "")
+	ctx := context.Background()
+	repo := reg.host + "/team/myapp"
+	now := time.Now()
+
+	untagged := &referrersRegistry{GenericRegistry: generic}
+	sbom := func(subject string) string {
+		result, err := generic.PushReferrer(ctx, repo+"@"+subject, "application/spdx+json", []byte(`{"spdxVersion":"SPDX-2.3"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := remote.Delete(mustReference(t, repo+":"+referrersTag(subject))); err != nil {
+			t.Fatal(err)
+		}
+		untagged.untagged = append(untagged.untagged, RemoteImage{Digest: result.Digest, MediaType: "application/vnd.oci.image.manifest.v1+json"})
+		return result.Digest
+	}
+
+	oldDigest := reg.writeCreated(t, repo+":v1", now.Add(-30*24*time.Hour))
+	oldSBOM := sbom(oldDigest)
+	newDigest := reg.writeCreated(t, repo+":v2", now.Add(-24*time.Hour))
+	newSBOM := sbom(newDigest)
+	stray := reg.writeCreated(t, repo+":stray", now.Add(-10*24*time.Hour))
+	if err := remote.Delete(mustReference(t, repo+":stray")); err != nil {
+		t.Fatal(err)
+	}
+	untagged.untagged = append(untagged.untagged, RemoteImage{Digest: stray, MediaType: "application/vnd.oci.image.manifest.v1+json"})
+
+	plan, err := PlanCleanup(ctx, untagged, "team/myapp", RetentionPolicy{KeepLast: 1, Untagged: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleted := deletedDigests(plan)
+	want := []string{oldDigest, stray, oldSBOM}
+	if !slices.Equal(deleted, want) {
+		t.Errorf("deleting %v, want %v", deleted, want)
+	}
+	if plan.Kept != 2 {
+		t.Errorf("kept %d images, want 2", plan.Kept)
+	}
+
+	if err := ApplyCleanup(ctx, untagged, plan); err != nil {
+		t.Fatal(err)
+	}
+	if !reg.exists(t, repo+"@"+newSBOM) {
+		t.Error("the SBOM of the kept image was deleted")
+	}
+	for _, digest := range []string{oldSBOM, stray} {
+		if reg.exists(t, repo+"@"+digest) {
+			t.Errorf("%s was not deleted", digest)
+		}
+	}
+}
+
+func TestCleanupRegistryDeletingByDigest(t *testing.T) {
+	clearCredentials(t)
+	reg := newTestRegistry(t, "", "", "")
+	reg.digestDeletesOnly = true
+	generic := NewGenericRegistry(reg.host, "", "", "")
+	ctx := context.Background()
+	repo := reg.host + "/team/myapp"
+	now := time.Now()
+
+	// Deleting the first tag of the old image removes the manifest and its
+	// other tag with it
+	oldDigest := reg.writeCreated(t, repo+":v1", now.Add(-30*24*time.Hour))
+	desc, err := remote.Get(mustReference(t, repo+":v1"), reg.auth())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Tag(mustReference(t, repo+":v1").Context().Tag("stable"), desc, reg.auth()); err != nil {
+		t.Fatal(err)
+	}
+	reg.writeCreated(t, repo+":v2", now.Add(-24*time.Hour))
+
+	plan, err := PlanCleanup(ctx, generic, "team/myapp", RetentionPolicy{KeepLast: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted := deletedDigests(plan); !slices.Equal(deleted, []string{oldDigest}) || len(plan.Delete[0].Tags) != 2 {
+		t.Fatalf("deleting %+v, want %s with its two tags", plan.Delete, oldDigest)
+	}
+	if err := ApplyCleanup(ctx, generic, plan); err != nil {
+		t.Fatal(err)
+	}
+	if plan.Deleted != 1 {
+		t.Errorf("deleted %d images, want 1", plan.Deleted)
+	}
+	tags, err := generic.ListTags(ctx, "team/myapp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(tags, []string{"v2"}) {
+		t.Errorf("tags after cleanup = %v, want [v2]", tags)
+	}
+}
+
+func TestManifestOwnersFromArtifactTags(t *testing.T) {
+	image := "sha256:" + strings.Repeat("1", 64)
+	images := []RemoteImage{
+		{Digest: image, Tags: []string{"v1"}},
+		{Digest: "sha256:" + strings.Repeat("2", 64), Tags: []string{referrersTag(image) + ".sig", referrersTag(image) + ".att"}},
+		{Digest: "sha256:" + strings.Repeat("3", 64), Tags: []string{referrersTag(image) + ".sig", "latest"}},
+	}
+	owners, err := manifestOwners(context.Background(), &HubRegistry{}, "team/myapp", images)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := owners[images[1].Digest]; !slices.Equal(got, []string{image}) {
+		t.Errorf("owners of the signature = %v, want [%s]", got, image)
+	}
+	for _, img := range []RemoteImage{images[0], images[2]} {
+		if got, ok := owners[img.Digest]; ok {
+			t.Errorf("%s with a regular tag belongs to %v", img.Digest, got)
+		}
+	}
+}
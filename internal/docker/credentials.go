@@ -0,0 +1,111 @@
+package docker
+
+import (
+	"os"
+	"strings"
+
+	"github.com/docker/cli/cli/config"
+	"github.com/docker/cli/cli/config/configfile"
+	"github.com/docker/cli/cli/config/credentials"
+	clitypes "github.com/docker/cli/cli/config/types"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/pterm/pterm"
+)
+
+// hubServerAddress is the key Docker uses for Docker Hub in config.json and credential helpers.
+const hubServerAddress = "https://index.docker.io/v1/"
+
+// credentialEnv names the environment variables checked for a registry's credentials.
+type credentialEnv struct {
+	Username string
+	Password string
+	Token    string
+}
+
+// resolveCredentials finds the credentials for host, trying in order the values
+// given on the command line, the environment variables in env, the auths stored
+// by 'docker login' in config.json and the docker-credential-* helper config.json
+// configures for the host. It returns empty credentials when none are found.
+func resolveCredentials(host string, explicit registry.AuthConfig, env credentialEnv) registry.AuthConfig {
+	if explicit.Username != "" || explicit.Password != "" || explicit.RegistryToken != "" {
+		explicit.ServerAddress = host
+		pterm.Info.Printf("Using credentials for %s from command line flags\n", host)
+		return explicit
+	}
+
+	fromEnv := registry.AuthConfig{
+		Username:      os.Getenv(env.Username),
+		Password:      os.Getenv(env.Password),
+		ServerAddress: host,
+	}
+	if env.Token != "" {
+		fromEnv.RegistryToken = os.Getenv(env.Token)
+	}
+	if fromEnv.Password != "" || fromEnv.RegistryToken != "" {
+		pterm.Info.Printf("Using credentials for %s from environment variables\n", host)
+		return fromEnv
+	}
+
+	cfg, err := config.Load(config.Dir())
+	if err != nil {
+		pterm.Warning.Printf("Could not read Docker config in %s: %v\n", config.Dir(), err)
+		return registry.AuthConfig{ServerAddress: host}
+	}
+	serverAddress := credentialServerAddress(host)
+
+	if auth, ok := storedAuth(cfg, serverAddress); ok {
+		pterm.Info.Printf("Using credentials for %s from %s\n", host, cfg.Filename)
+		return toAuthConfig(auth, host)
+	}
+
+	helper := cfg.CredentialHelpers[serverAddress]
+	if helper == "" {
+		helper = cfg.CredentialsStore
+	}
+	if helper != "" {
+		auth, err := credentials.NewNativeStore(cfg, helper).Get(serverAddress)
+		if err != nil {
+			pterm.Warning.Printf("docker-credential-%s could not provide credentials for %s: %v\n", helper, host, err)
+		} else if auth.Password != "" || auth.IdentityToken != "" {
+			pterm.Info.Printf("Using credentials for %s from docker-credential-%s\n", host, helper)
+			return toAuthConfig(auth, host)
+		}
+	}
+
+	return registry.AuthConfig{ServerAddress: host}
+}
+
+// storedAuth returns the config.json auths entry for serverAddress, accepting
+// keys written with or without a scheme as 'docker login' has done over time.
+func storedAuth(cfg *configfile.ConfigFile, serverAddress string) (clitypes.AuthConfig, bool) {
+	for key, auth := range cfg.AuthConfigs {
+		if credentialServerAddress(key) != serverAddress {
+			continue
+		}
+		if auth.Password != "" || auth.IdentityToken != "" {
+			return auth, true
+		}
+	}
+	return clitypes.AuthConfig{}, false
+}
+
+// credentialServerAddress normalizes a registry host to the key Docker stores its credentials under.
+func credentialServerAddress(host string) string {
+	host = strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+	host = strings.SplitN(host, "/", 2)[0]
+	switch host {
+	case "docker.io", "index.docker.io", "registry-1.docker.io":
+		return hubServerAddress
+	}
+	return host
+}
+
+func toAuthConfig(auth clitypes.AuthConfig, host string) registry.AuthConfig {
+	return registry.AuthConfig{
+		Username:      auth.Username,
+		Password:      auth.Password,
+		IdentityToken: auth.IdentityToken,
+		RegistryToken: auth.RegistryToken,
+		ServerAddress: host,
+	}
+}
@@ -1,90 +1,49 @@
 package docker
 
 import (
-	"archive/tar"
-	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
 	"os"
-	"os/exec"
-	"path/filepath"
 	"strings"
 
-	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
-	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerregistry/armcontainerregistry"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/distribution/reference"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/registry"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/go-units"
 	"github.com/docker/docker/api/types"
 	"github.com/fatih/color"
+	"github.com/clouddrove/smurf/internal/dryrun"
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/pterm/pterm"
-	"golang.org/x/oauth2/google"
 )
 
 // BuildOptions struct to hold options for Docker build
 type BuildOptions struct {
+	// ContextDir is the build context; it defaults to the directory of the Dockerfile.
+	ContextDir string
+	// DockerfilePath is the Dockerfile, relative to the working directory, or "-" for stdin.
 	DockerfilePath string
 	NoCache        bool
 	BuildArgs      map[string]string
 	Target         string
 	Platform       string
-}
-
-// createTarArchive creates a tar archive of the entire build context directory.
-func createTarArchive(contextDir string) (io.Reader, error) {
-	buf := new(bytes.Buffer)
-	tw := tar.NewWriter(buf)
-	defer tw.Close()
-
-	err := filepath.Walk(contextDir, func(file string, fi os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		if fi.IsDir() || strings.HasPrefix(fi.Name(), ".") {
-			return nil
-		}
-
-		header, err := tar.FileInfoHeader(fi, file)
-		if err != nil {
-			return err
-		}
-
-		header.Name = filepath.ToSlash(file)
-		if err := tw.WriteHeader(header); err != nil {
-			return err
-		}
-
-		data, err := os.Open(file)
-		if err != nil {
-			return err
-		}
-		defer data.Close()
-
-		if _, err := io.Copy(tw, data); err != nil {
-			return err
-		}
-
-		return nil
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	if err := tw.Close(); err != nil {
-		return nil, err
-	}
-
-	return buf, nil
+	// BuildKit builds with 'docker buildx build'; Secrets, SSH, CacheFrom and CacheTo imply it.
+	BuildKit bool
+	// Secrets are exposed to RUN --mount=type=secret, as id=NAME,src=FILE or id=NAME,env=VAR.
+	Secrets []string
+	// SSH forwards SSH agents or keys to RUN --mount=type=ssh, as default or ID=PATH.
+	SSH []string
+	// CacheFrom and CacheTo import and export the build cache, as type=registry,ref=REF or type=local,src|dest=DIR.
+	CacheFrom []string
+	CacheTo   []string
+	// AdditionalTags are further tags of the image applied by the same build.
+	AdditionalTags []string
+	// Labels are added to the standard OCI labels every build sets, overriding them.
+	Labels map[string]string
 }
 
 func convertToInterfaceMap(args map[string]string) map[string]*string {
@@ -96,28 +55,45 @@ func convertToInterfaceMap(args map[string]string) map[string]*string {
 	return result
 }
 
-// Build builds a Docker image from a specified Dockerfile.
-func Build(imageName, tag string, opts BuildOptions) error {
-	ctx := context.Background()
+// Build builds a Docker image from a specified Dockerfile. A comma separated
+// Platform builds one image per platform, see buildPlatforms.
+func Build(ctx context.Context, imageName, tag string, opts BuildOptions) error {
+	if platforms := Platforms(opts.Platform); len(platforms) > 1 {
+		return buildPlatforms(ctx, imageName, tag, opts, platforms)
+	}
 
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
 		return fmt.Errorf("failed to create Docker client: %w", err)
 	}
-	fmt.Println("Docker client created successfully")
-
-	contextDir := filepath.Dir(opts.DockerfilePath)
-	fmt.Println("Context Directory: ", contextDir)
+	fmt.Fprintln(output.Writer(), "Docker client created successfully")
 
-	tarStream, err := createTarArchive(contextDir)
+	buildCtx, dockerfile, err := resolveBuildContext(opts.ContextDir, opts.DockerfilePath)
 	if err != nil {
-		return fmt.Errorf("failed to create tar archive from context directory: %w", err)
+		return err
+	}
+	contextDir := buildCtx.dir
+	dockerfileSource := opts.DockerfilePath
+	if buildCtx.dockerfileName == "" {
+		dockerfileSource = dockerfile
+	}
+	fmt.Fprintln(output.Writer(), "Context Directory: ", contextDir)
+	fmt.Fprintf(output.Writer(), "Build context: %d files, %s\n", buildCtx.Files, units.HumanSize(float64(buildCtx.Size)))
+
+	tags := []string{fmt.Sprintf("%s:%s", imageName, tag)}
+	for _, extra := range opts.AdditionalTags {
+		tags = append(tags, fmt.Sprintf("%s:%s", imageName, extra))
+	}
+
+	labels := imageLabels(ctx, contextDir, imageName, tag, opts.Labels)
+	if revision := labels[LabelRevision]; revision != "" {
+		fmt.Fprintf(output.Writer(), "Labelling image with revision %s\n", revision)
 	}
-	fmt.Println("Tar archive created successfully")
 
 	options := types.ImageBuildOptions{
-		Tags:        []string{fmt.Sprintf("%s:%s", imageName, tag)},
-		Dockerfile:  filepath.Base(opts.DockerfilePath),
+		Tags:        tags,
+		Labels:      labels,
+		Dockerfile:  dockerfile,
 		NoCache:     opts.NoCache,
 		BuildArgs:   convertToInterfaceMap(opts.BuildArgs),
 		Target:      opts.Target,
@@ -127,25 +103,46 @@ func Build(imageName, tag string, opts BuildOptions) error {
 		Platform:    opts.Platform,
 	}
 
+	if opts.usesBuildKit() {
+		if dryrun.Enabled() {
+			if _, err := buildKitArgs(tags, dockerfile, contextDir, opts); err != nil {
+				return err
+			}
+			dryrun.Record("docker", "build", strings.Join(tags, ", "), fmt.Sprintf("BuildKit, Dockerfile %s, context %s", dockerfileSource, contextDir))
+			return nil
+		}
+		return buildWithBuildKit(ctx, tags, buildCtx, dockerfile, opts)
+	}
+
+	if dryrun.Enabled() {
+		dryrun.Record("docker", "build", strings.Join(tags, ", "), fmt.Sprintf("Dockerfile %s, context %s", dockerfileSource, contextDir))
+		return nil
+	}
 
 	spinner, _ := pterm.DefaultSpinner.Start("Building Docker image...")
+	tarStream := buildCtx.Stream()
+	defer tarStream.Close()
 	buildResponse, err := cli.ImageBuild(ctx, tarStream, options)
 	if err != nil {
 		spinner.Fail("Failed to start the build process")
-		return fmt.Errorf("failed to start image build (context: %s, Dockerfile: %s): %w", contextDir, opts.DockerfilePath, err)
+		return fmt.Errorf("failed to start image build (context: %s, Dockerfile: %s): %w", contextDir, dockerfileSource, err)
 	}
 
-	io.Copy(os.Stdout, buildResponse.Body)
 	defer buildResponse.Body.Close()
 
-	err = jsonmessage.DisplayJSONMessagesStream(buildResponse.Body, os.Stdout, os.Stderr.Fd(), true, nil)
+	out := output.Writer()
+	err = jsonmessage.DisplayJSONMessagesStream(buildResponse.Body, out, out.Fd(), true, nil)
 	if err != nil {
+		if ctx.Err() != nil {
+			spinner.Warning("Build cancelled, the daemon stops the build and discards its intermediate containers")
+			return fmt.Errorf("build cancelled: %w", ctx.Err())
+		}
 		spinner.Fail("Failed during the build process")
 		return fmt.Errorf("error during build process: %w", err)
 	}
 
 	spinner.Success("Docker image built successfully")
-	color.New(color.FgGreen).Printf("Successfully built %s:%s\n", imageName, tag)
+	color.New(color.FgGreen).Printf("Successfully built %s\n", strings.Join(tags, ", "))
 
 	return nil
 }
@@ -157,14 +154,18 @@ type TagOptions struct {
 }
 
 // TagImage tags a local Docker image for use in a remote repository.
-func TagImage(opts TagOptions) error {
-	ctx := context.Background()
+func TagImage(ctx context.Context, opts TagOptions) error {
 	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
 	if err != nil {
 		color.New(color.FgRed).Printf("Error creating Docker client: %v\n", err)
 		return err
 	}
 
+	if dryrun.Enabled() {
+		dryrun.Record("docker", "tag", opts.Target, "from "+opts.Source)
+		return nil
+	}
+
 	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Tagging image %s as %s...", opts.Source, opts.Target))
 	if err := cli.ImageTag(ctx, opts.Source, opts.Target); err != nil {
 		spinner.Fail(fmt.Sprintf("Failed to tag image: %v", err))
@@ -177,142 +178,77 @@ func TagImage(opts TagOptions) error {
 	return nil
 }
 
-// PushOptions struct to hold options for pushing a Docker image
-type PushOptions struct {
-	ImageName string
+// PushResult describes an image pushed to a registry
+type PushResult struct {
+	Image  string `json:"image"`
+	Digest string `json:"digest,omitempty"`
+	// Reference pins the pushed image by digest, as REPOSITORY@DIGEST.
+	Reference string `json:"reference,omitempty"`
 }
 
-// PushImage pushes a Docker image to a Docker registry.
-func PushImage(opts PushOptions) error {
-	ctx := context.Background()
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		pterm.Error.Println("Error creating Docker client:", err)
-		return err
+// newPushResult describes the image pushed as ref, with the digest the registry reported.
+func newPushResult(ref, digest string) *PushResult {
+	result := &PushResult{Image: ref, Digest: digest}
+	if digest != "" {
+		repository, _ := SplitReference(ref)
+		result.Reference = repository + "@" + digest
 	}
+	return result
+}
 
-	authConfig := registry.AuthConfig{
-		Username: os.Getenv("DOCKER_USERNAME"),
-		Password: os.Getenv("DOCKER_PASSWORD"),
+// reportDigest prints the digest reference of a pushed image so later steps can pin it.
+func reportDigest(result *PushResult) {
+	if dryrun.Enabled() {
+		return
 	}
-	encodedJSON, err := json.Marshal(authConfig)
-	if err != nil {
-		pterm.Error.Println("Error encoding auth config:", err)
-		return err
+	if result.Reference == "" {
+		pterm.Warning.Printf("The registry did not report a digest for %s\n", result.Image)
+		return
 	}
-	authStr := base64.URLEncoding.EncodeToString(encodedJSON)
+	pterm.Info.Println("Digest:", result.Reference)
+}
 
-	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Pushing image %s...", opts.ImageName))
-	options := image.PushOptions{
-		RegistryAuth: authStr,
+// WriteDigestFile writes the digest of a pushed image to path, for pipelines
+// that deploy by digest instead of by tag.
+func WriteDigestFile(path string, result *PushResult) error {
+	if dryrun.Enabled() {
+		dryrun.Record("docker", "write", path, "digest of "+result.Image)
+		return nil
 	}
-
-	responseBody, err := cli.ImagePush(ctx, opts.ImageName, options)
-	if err != nil {
-		spinner.Fail("Failed to push the image: " + err.Error())
-		return err
+	if result.Digest == "" {
+		return fmt.Errorf("cannot write %s: no digest was reported for %s", path, result.Image)
 	}
-	defer responseBody.Close()
-
-	return handleDockerResponse(responseBody, spinner, opts)
-}
-
-func handleDockerResponse(responseBody io.ReadCloser, spinner *pterm.SpinnerPrinter, opts PushOptions) error {
-	decoder := json.NewDecoder(responseBody)
-	var lastProgress int
-	for {
-		var msg jsonmessage.JSONMessage
-		if err := decoder.Decode(&msg); err == io.EOF {
-			break
-		} else if err != nil {
-			pterm.Error.Println("Error decoding JSON:", err)
-			return err
-		}
-
-		if msg.Error != nil {
-			pterm.Error.Println("Error from Docker:", msg.Error.Message)
-			return fmt.Errorf(msg.Error.Message)
-		}
-
-		if msg.Progress != nil && msg.Progress.Total > 0 {
-			current := int(msg.Progress.Current * 100 / msg.Progress.Total)
-			if current > lastProgress {
-				progressMessage := fmt.Sprintf("Pushing image %s... %d%%", opts.ImageName, current)
-				spinner.UpdateText(progressMessage)
-				fmt.Printf("\r%s", pterm.Green(progressMessage))
-				lastProgress = current
-			}
-		}
-
-		if msg.Stream != "" {
-			fmt.Print(pterm.Blue(msg.Stream))
-		}
+	if err := os.WriteFile(path, []byte(result.Digest+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to write digest file: %w", err)
 	}
-
-	spinner.Success("Image push complete.")
-	pterm.Success.Println("Successfully pushed image:", opts.ImageName)
+	pterm.Success.Println("Digest written to", path)
 	return nil
 }
 
-// Scout scans a Docker image for known vulnerabilities using 'docker scout cves'.
-func Scout(dockerTag, sarifFile string) error {
-	ctx := context.Background()
-
-	args := []string{"scout", "cves", dockerTag}
-
-	if sarifFile != "" {
-		args = append(args, "--output", sarifFile)
+// pushDigest extracts the manifest digest from the aux message the daemon sends once a push completes.
+func pushDigest(aux *json.RawMessage) string {
+	if aux == nil {
+		return ""
 	}
-
-	cmd := exec.CommandContext(ctx, "docker", args...)
-
-	var stdoutBuf, stderrBuf bytes.Buffer
-	cmd.Stdout = &stdoutBuf
-	cmd.Stderr = &stderrBuf
-
-	spinner, _ := pterm.DefaultSpinner.Start("Running 'docker scout cves'")
-	defer spinner.Stop()
-
-	err := cmd.Run()
-
-	spinner.Stop()
-
-	outStr := stdoutBuf.String()
-	errStr := stderrBuf.String()
-
-	if err != nil {
-		pterm.Error.Println("Error running 'docker scout cves':", err)
-		if errStr != "" {
-			pterm.Error.Println(errStr)
-		}
-		return fmt.Errorf("failed to run 'docker scout cves': %w", err)
-	}
-
-	if outStr != "" {
-		pterm.Info.Println("Docker Scout CVEs output:")
-		fmt.Println(color.YellowString(outStr))
-	}
-
-	if sarifFile != "" {
-		if _, err := os.Stat(sarifFile); err == nil {
-			pterm.Success.Println("SARIF report saved to:", sarifFile)
-		} else {
-			pterm.Warning.Println("Expected SARIF report not found at:", sarifFile)
-		}
+	var result types.PushResult
+	if err := json.Unmarshal(*aux, &result); err != nil {
+		return ""
 	}
-
-	pterm.Success.Println("Scan completed successfully.")
-	return nil
+	return result.Digest
 }
 
 // RemoveImage removes a Docker image based on the provided flags.
-func RemoveImage(imageTag string) error {
-	ctx := context.Background()
+func RemoveImage(ctx context.Context, imageTag string) error {
 	cli, err := client.NewClientWithOpts(client.WithAPIVersionNegotiation())
 	if err != nil {
 		return fmt.Errorf("failed to create Docker client: %w", err)
 	}
 
+	if dryrun.Enabled() {
+		dryrun.Record("docker", "delete", imageTag, "local image")
+		return nil
+	}
+
 	pterm.Info.Println("Removing local Docker image:", imageTag)
 	spinner, _ := pterm.DefaultSpinner.Start("Removing image...")
 
@@ -326,351 +262,22 @@ func RemoveImage(imageTag string) error {
 	return nil
 }
 
-func PushImageToECR(imageName, region, repositoryName string) error {
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(region),
-	})
-	if err != nil {
-		pterm.Error.Println(fmt.Errorf("failed to create AWS session: %w", err))
-		return err
-	}
-
-	ecrClient := ecr.New(sess)
-
-	describeRepositoriesInput := &ecr.DescribeRepositoriesInput{
-		RepositoryNames: []*string{
-			aws.String(repositoryName),
-		},
-	}
-	_, err = ecrClient.DescribeRepositories(describeRepositoriesInput)
-	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == ecr.ErrCodeRepositoryNotFoundException {
-			createRepositoryInput := &ecr.CreateRepositoryInput{
-				RepositoryName: aws.String(repositoryName),
-			}
-			_, err = ecrClient.CreateRepository(createRepositoryInput)
-			if err != nil {
-				pterm.Error.Println(fmt.Errorf("failed to create ECR repository: %w", err))
-				return err
-			}
-			pterm.Info.Println("Created ECR repository:", repositoryName)
-		} else {
-			pterm.Error.Println(fmt.Errorf("failed to describe ECR repositories: %w", err))
-			return err
-		}
-	}
-
-	authTokenOutput, err := ecrClient.GetAuthorizationToken(&ecr.GetAuthorizationTokenInput{})
-	if err != nil {
-		pterm.Error.Println(fmt.Errorf("failed to get ECR authorization token: %w", err))
-		return err
-	}
-
-	if len(authTokenOutput.AuthorizationData) == 0 {
-		pterm.Error.Println("No authorization data received from ECR")
-		return fmt.Errorf("no authorization data received from ECR")
-	}
-
-	authData := authTokenOutput.AuthorizationData[0]
-	authToken, err := base64.StdEncoding.DecodeString(*authData.AuthorizationToken)
-	if err != nil {
-		pterm.Error.Println(fmt.Errorf("failed to decode authorization token: %w", err))
-		return err
-	}
-
-	credentials := strings.SplitN(string(authToken), ":", 2)
-	if len(credentials) != 2 {
-		pterm.Error.Println("Invalid authorization token format")
-		return fmt.Errorf("invalid authorization token format")
-	}
-
-	ecrURL := strings.TrimPrefix(*authData.ProxyEndpoint, "https://")
-
-	pterm.Info.Println("Initializing Docker client...")
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		pterm.Error.Println(fmt.Errorf("failed to create Docker client: %w", err))
-		return err
-	}
-
-	authConfig := registry.AuthConfig{
-		Username:      credentials[0],
-		Password:      credentials[1],
-		ServerAddress: *authData.ProxyEndpoint,
-	}
-
-	pterm.Info.Println("Authenticating Docker client to ECR...")
-
-	encodedJSON, err := json.Marshal(authConfig)
-	if err != nil {
-		pterm.Error.Println(fmt.Errorf("failed to encode auth config: %w", err))
-		return err
-	}
-	authStr := base64.URLEncoding.EncodeToString(encodedJSON)
-
-	ecrImage := fmt.Sprintf("%s/%s", ecrURL, repositoryName)
-	pterm.Info.Println("Tagging image for ECR...")
-	if err := cli.ImageTag(context.Background(), imageName, ecrImage); err != nil {
-		pterm.Error.Println(fmt.Errorf("failed to tag image: %w", err))
-		return err
-	}
-
-	pterm.Info.Println("Pushing image to ECR...")
-	pushResponse, err := cli.ImagePush(context.Background(), ecrImage, image.PushOptions{
-		RegistryAuth: authStr,
-	})
-	if err != nil {
-		pterm.Error.Println(fmt.Errorf("failed to push image to ECR: %w", err))
-		return err
-	}
-	defer pushResponse.Close()
-
-	decoder := json.NewDecoder(pushResponse)
-	for {
-		var message map[string]interface{}
-		if err := decoder.Decode(&message); err != nil {
-			if err == io.EOF {
-				break
-			}
-			pterm.Error.Println(fmt.Errorf("error decoding JSON message from push: %w", err))
-			return err
-		}
-
-		if status, ok := message["status"].(string); ok {
-			if status != "Waiting" {
-				pterm.Info.Println(status)
-			}
-		}
-		if errorDetail, ok := message["errorDetail"].(map[string]interface{}); ok {
-			pterm.Error.Println(fmt.Errorf("error pushing image: %v", errorDetail["message"]))
-			return fmt.Errorf("error pushing image: %v", errorDetail["message"])
-		}
-	}
-
-	fmt.Println()
-	pterm.Success.Println("Image successfully pushed to ECR:", ecrImage)
-	return nil
-}
-
-func PushImageToACR(subscriptionID, resourceGroupName, registryName, imageName string) error {
-	ctx := context.Background()
-
-	spinner, _ := pterm.DefaultSpinner.Start("Authenticating with Azure...")
-	cred, err := azidentity.NewDefaultAzureCredential(nil)
+// SplitReference separates an image reference into its repository and tag.
+// The tag is empty when the reference has none.
+func SplitReference(ref string) (string, string) {
+	named, err := reference.ParseNormalizedNamed(ref)
 	if err != nil {
-		spinner.Fail("Failed to authenticate with Azure")
-		color.New(color.FgRed).Printf("Error: %v\n", err)
-		return err
-	}
-	spinner.Success("Authenticated with Azure")
-
-	spinner, _ = pterm.DefaultSpinner.Start("Creating registry client...")
-	registryClient, err := armcontainerregistry.NewRegistriesClient(subscriptionID, cred, nil)
-	if err != nil {
-		spinner.Fail("Failed to create registry client")
-		color.New(color.FgRed).Printf("Error: %v\n", err)
-		return err
-	}
-	spinner.Success("Registry client created")
-
-	spinner, _ = pterm.DefaultSpinner.Start("Retrieving registry details...")
-	registryResp, err := registryClient.Get(ctx, resourceGroupName, registryName, nil)
-	if err != nil {
-		spinner.Fail("Failed to retrieve registry details")
-		color.New(color.FgRed).Printf("Error: %v\n", err)
-		return err
-	}
-	loginServer := *registryResp.Properties.LoginServer
-	spinner.Success("Registry details retrieved")
-
-	spinner, _ = pterm.DefaultSpinner.Start("Retrieving registry credentials...")
-	credentialsResp, err := registryClient.ListCredentials(ctx, resourceGroupName, registryName, nil)
-	if err != nil {
-		spinner.Fail("Failed to retrieve registry credentials")
-		color.New(color.FgRed).Printf("Error: %v\n", err)
-		return err
-	}
-	if credentialsResp.Username == nil || len(credentialsResp.Passwords) == 0 || credentialsResp.Passwords[0].Value == nil {
-		spinner.Fail("Registry credentials are not available")
-		color.New(color.FgRed).Println("Error: Registry credentials are not available")
-		return fmt.Errorf("registry credentials are not available")
-	}
-	username := *credentialsResp.Username
-	password := *credentialsResp.Passwords[0].Value
-	spinner.Success("Registry credentials retrieved")
-
-	spinner, _ = pterm.DefaultSpinner.Start("Creating Docker client...")
-	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		spinner.Fail("Failed to create Docker client")
-		color.New(color.FgRed).Printf("Error: %v\n", err)
-		return err
-	}
-	spinner.Success("Docker client created")
-
-	spinner, _ = pterm.DefaultSpinner.Start("Tagging the image...")
-	taggedImage := fmt.Sprintf("%s/%s", loginServer, imageName)
-	err = dockerClient.ImageTag(ctx, imageName, taggedImage)
-	if err != nil {
-		spinner.Fail("Failed to tag the image")
-		color.New(color.FgRed).Printf("Error: %v\n", err)
-		return err
-	}
-	spinner.Success("Image tagged")
-
-	spinner, _ = pterm.DefaultSpinner.Start("Pushing the image to ACR...")
-	authConfig := registry.AuthConfig{
-		Username:      username,
-		Password:      password,
-		ServerAddress: loginServer,
-	}
-	encodedAuth, err := encodeAuthToBase64(authConfig)
-	if err != nil {
-		spinner.Fail("Failed to encode authentication credentials")
-		color.New(color.FgRed).Printf("Error: %v\n", err)
-		return err
-	}
-
-	pushOptions := image.PushOptions{
-		RegistryAuth: encodedAuth,
-	}
-
-	pushResponse, err := dockerClient.ImagePush(ctx, taggedImage, pushOptions)
-	if err != nil {
-		spinner.Fail("Failed to push the image")
-		color.New(color.FgRed).Printf("Error: %v\n", err)
-		return err
-	}
-	defer pushResponse.Close()
-
-	dec := json.NewDecoder(pushResponse)
-	for {
-		var event jsonmessage.JSONMessage
-		if err := dec.Decode(&event); err != nil {
-			if err == io.EOF {
-				break
-			}
-			spinner.Fail("Failed to read push response")
-			color.New(color.FgRed).Printf("Error: %v\n", err)
-			return err
-		}
-		if event.Error != nil {
-			spinner.Fail("Failed to push the image")
-			color.New(color.FgRed).Printf("Error: %v\n", event.Error)
-			return event.Error
+		if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+			return ref[:i], ref[i+1:]
 		}
-		if event.Status != "" {
-			spinner.UpdateText(event.Status)
-		}
-	}
-	spinner.Success("Image pushed to ACR")
-
-	color.New(color.FgGreen).Printf("Successfully pushed image '%s' to ACR '%s'\n", imageName, registryName)
-	return nil
-}
-
-func PushImageToGCR(projectID, imageName string) error {
-	ctx := context.Background()
-
-	spinner, _ := pterm.DefaultSpinner.Start("Authenticating with Google Cloud...")
-	creds, err := google.FindDefaultCredentials(ctx, "https://www.googleapis.com/auth/cloud-platform")
-	if err != nil {
-		spinner.Fail("Failed to authenticate with Google Cloud")
-		color.New(color.FgRed).Printf("Error: %v\n", err)
-		return err
-	}
-	spinner.Success("Authenticated with Google Cloud")
-
-	spinner, _ = pterm.DefaultSpinner.Start("Obtaining access token...")
-	tokenSource := creds.TokenSource
-	token, err := tokenSource.Token()
-	if err != nil {
-		spinner.Fail("Failed to obtain access token")
-		color.New(color.FgRed).Printf("Error: %v\n", err)
-		return err
-	}
-	spinner.Success("Access token obtained")
-
-	spinner, _ = pterm.DefaultSpinner.Start("Creating Docker client...")
-	dockerClient, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		spinner.Fail("Failed to create Docker client")
-		color.New(color.FgRed).Printf("Error: %v\n", err)
-		return err
-	}
-	spinner.Success("Docker client created")
-
-	spinner, _ = pterm.DefaultSpinner.Start("Tagging the image...")
-	var registryHost string
-	if strings.Contains(imageName, "gcr.io") {
-		registryHost = "gcr.io"
-	} else {
-		registryHost = fmt.Sprintf("gcr.io/%s", projectID)
+		return ref, ""
 	}
-	taggedImage := fmt.Sprintf("%s/%s", registryHost, imageName)
-	err = dockerClient.ImageTag(ctx, imageName, taggedImage)
-	if err != nil {
-		spinner.Fail("Failed to tag the image")
-		color.New(color.FgRed).Printf("Error: %v\n", err)
-		return err
-	}
-	spinner.Success("Image tagged")
 
-	spinner, _ = pterm.DefaultSpinner.Start("Pushing the image to GCR...")
-	authConfig := registry.AuthConfig{
-		Username:      "oauth2accesstoken",
-		Password:      token.AccessToken,
-		ServerAddress: "https://gcr.io",
+	repository := reference.FamiliarName(named)
+	if tagged, ok := named.(reference.Tagged); ok {
+		return repository, tagged.Tag()
 	}
-	encodedAuth, err := encodeAuthToBase64(authConfig)
-	if err != nil {
-		spinner.Fail("Failed to encode authentication credentials")
-		color.New(color.FgRed).Printf("Error: %v\n", err)
-		return err
-	}
-
-	pushOptions := image.PushOptions{
-		RegistryAuth: encodedAuth,
-	}
-
-	pushResponse, err := dockerClient.ImagePush(ctx, taggedImage, pushOptions)
-	if err != nil {
-		spinner.Fail("Failed to push the image")
-		color.New(color.FgRed).Printf("Error: %v\n", err)
-		return err
-	}
-	defer pushResponse.Close()
-
-	dec := json.NewDecoder(pushResponse)
-	progressBar, _ := pterm.DefaultProgressbar.WithTotal(100).WithTitle("Pushing to GCR").Start()
-	for {
-		var event jsonmessage.JSONMessage
-		if err := dec.Decode(&event); err != nil {
-			if err == io.EOF {
-				break
-			}
-			spinner.Fail("Failed to read push response")
-			color.New(color.FgRed).Printf("Error: %v\n", err)
-			return err
-		}
-		if event.Error != nil {
-			spinner.Fail("Failed to push the image")
-			color.New(color.FgRed).Printf("Error: %v\n", event.Error)
-			return event.Error
-		}
-		if event.Progress != nil && event.Progress.Total > 0 {
-			progress := int(float64(event.Progress.Current) / float64(event.Progress.Total) * 100)
-			if progress > 100 {
-				progress = 100
-			}
-			progressBar.Add(progress - progressBar.Current)
-		}
-	}
-	progressBar.Stop()
-	spinner.Success("Image pushed to GCR")
-
-	color.New(color.FgGreen).Printf("Successfully pushed image '%s' to GCR\n", taggedImage)
-	return nil
+	return repository, ""
 }
 
 func encodeAuthToBase64(authConfig registry.AuthConfig) (string, error) {
@@ -0,0 +1,233 @@
+package docker
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/clouddrove/smurf/internal/dryrun"
+	"github.com/pterm/pterm"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// DefaultKeyFile is the private key used for signing when none is given; its
+// public key is written next to it with a .pub extension.
+const DefaultKeyFile = "cosign.key"
+
+// PEM block types of cosign key pairs. Keys are written with the current
+// type; the older one is still accepted when loading.
+const (
+	privateKeyPEMType       = "ENCRYPTED SIGSTORE PRIVATE KEY"
+	legacyPrivateKeyPEMType = "ENCRYPTED COSIGN PRIVATE KEY"
+	publicKeyPEMType        = "PUBLIC KEY"
+)
+
+// scrypt parameters used by cosign to derive the key encryption key.
+const (
+	scryptN = 32768
+	scryptR = 8
+	scryptP = 1
+)
+
+// encryptedKey is the JSON envelope cosign stores encrypted private keys in: the
+// PKCS #8 key sealed with NaCl secretbox under a key derived with scrypt.
+type encryptedKey struct {
+	KDF struct {
+		Name   string `json:"name"`
+		Params struct {
+			N int `json:"N"`
+			R int `json:"r"`
+			P int `json:"p"`
+		} `json:"params"`
+		Salt []byte `json:"salt"`
+	} `json:"kdf"`
+	Cipher struct {
+		Name  string `json:"name"`
+		Nonce []byte `json:"nonce"`
+	} `json:"cipher"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// PublicKeyFile returns the public key file that belongs to a private key file.
+func PublicKeyFile(privateKeyFile string) string {
+	return strings.TrimSuffix(privateKeyFile, ".key") + ".pub"
+}
+
+// GenerateKeyPair creates an ECDSA P-256 key pair in the cosign format, with the
+// private key at path encrypted with the password from COSIGN_PASSWORD or a
+// prompt, and the public key next to it. Existing files are never overwritten.
+func GenerateKeyPair(path string) (string, error) {
+	publicPath := PublicKeyFile(path)
+	for _, p := range []string{path, publicPath} {
+		if _, err := os.Stat(p); err == nil {
+			return "", fmt.Errorf("%s already exists; remove it or choose another --key", p)
+		}
+	}
+
+	if dryrun.Enabled() {
+		dryrun.Record("docker", "write", path, "cosign key pair with "+publicPath)
+		return publicPath, nil
+	}
+
+	password, err := keyPassword(true)
+	if err != nil {
+		return "", err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	var envelope encryptedKey
+	envelope.KDF.Name = "scrypt"
+	envelope.KDF.Params.N, envelope.KDF.Params.R, envelope.KDF.Params.P = scryptN, scryptR, scryptP
+	envelope.KDF.Salt = make([]byte, 32)
+	envelope.Cipher.Name = "nacl/secretbox"
+	envelope.Cipher.Nonce = make([]byte, 24)
+	if _, err := rand.Read(envelope.KDF.Salt); err != nil {
+		return "", err
+	}
+	if _, err := rand.Read(envelope.Cipher.Nonce); err != nil {
+		return "", err
+	}
+	secret, err := scrypt.Key(password, envelope.KDF.Salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return "", err
+	}
+	var boxKey [32]byte
+	var nonce [24]byte
+	copy(boxKey[:], secret)
+	copy(nonce[:], envelope.Cipher.Nonce)
+	envelope.Ciphertext = secretbox.Seal(nil, der, &nonce, &boxKey)
+
+	sealed, err := json.Marshal(envelope)
+	if err != nil {
+		return "", err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: privateKeyPEMType, Bytes: sealed}), 0600); err != nil {
+		return "", fmt.Errorf("failed to write private key: %w", err)
+	}
+	if err := os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: publicKeyPEMType, Bytes: publicDER}), 0644); err != nil {
+		return "", fmt.Errorf("failed to write public key: %w", err)
+	}
+	return publicPath, nil
+}
+
+// LoadSigningKey decrypts the cosign private key at path with the password
+// from COSIGN_PASSWORD or a prompt.
+func LoadSigningKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || (block.Type != privateKeyPEMType && block.Type != legacyPrivateKeyPEMType) {
+		return nil, fmt.Errorf("%s is not an encrypted cosign private key", path)
+	}
+	var envelope encryptedKey
+	if err := json.Unmarshal(block.Bytes, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+	}
+	if envelope.KDF.Name != "scrypt" || envelope.Cipher.Name != "nacl/secretbox" || len(envelope.Cipher.Nonce) != 24 {
+		return nil, fmt.Errorf("%s is encrypted with an unsupported scheme (%s, %s)", path, envelope.KDF.Name, envelope.Cipher.Name)
+	}
+
+	password, err := keyPassword(false)
+	if err != nil {
+		return nil, err
+	}
+	p := envelope.KDF.Params
+	secret, err := scrypt.Key(password, envelope.KDF.Salt, p.N, p.R, p.P, 32)
+	if err != nil {
+		return nil, err
+	}
+	var boxKey [32]byte
+	var nonce [24]byte
+	copy(boxKey[:], secret)
+	copy(nonce[:], envelope.Cipher.Nonce)
+	der, ok := secretbox.Open(nil, envelope.Ciphertext, &nonce, &boxKey)
+	if !ok {
+		return nil, fmt.Errorf("failed to decrypt %s: wrong password", path)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ECDSA key", path)
+	}
+	return key, nil
+}
+
+// LoadPublicKey reads a PEM encoded ECDSA public key, such as cosign.pub.
+func LoadPublicKey(path string) (*ecdsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != publicKeyPEMType {
+		return nil, fmt.Errorf("%s is not a PEM encoded public key", path)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+	}
+	key, ok := parsed.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ECDSA public key", path)
+	}
+	return key, nil
+}
+
+// keyPassword returns COSIGN_PASSWORD when it is set, even to an empty value,
+// and otherwise prompts on the terminal, twice when confirm is set.
+func keyPassword(confirm bool) ([]byte, error) {
+	if password, ok := os.LookupEnv("COSIGN_PASSWORD"); ok {
+		return []byte(password), nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, errors.New("set COSIGN_PASSWORD to the password of the private key")
+	}
+
+	fmt.Fprint(os.Stderr, "Enter password for private key: ")
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	if confirm {
+		fmt.Fprint(os.Stderr, "Enter password for private key again: ")
+		again, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, err
+		}
+		if string(again) != string(password) {
+			return nil, errors.New("passwords do not match")
+		}
+		if len(password) == 0 {
+			pterm.Warning.Println("The private key is not protected by a password")
+		}
+	}
+	return password, nil
+}
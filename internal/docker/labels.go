@@ -0,0 +1,130 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/git"
+	"github.com/docker/docker/client"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// Standard annotation keys from the OCI image spec, used as image labels.
+const (
+	LabelCreated  = "org.opencontainers.image.created"
+	LabelRevision = "org.opencontainers.image.revision"
+	LabelSource   = "org.opencontainers.image.source"
+	LabelVersion  = "org.opencontainers.image.version"
+	LabelTitle    = "org.opencontainers.image.title"
+)
+
+// imageLabels returns the OCI labels of an image built from dir: when it was
+// created, its title and version from the image name and tag, and the commit
+// and repository it was built from when dir is in a git checkout. Labels in
+// extra are added on top and override these.
+func imageLabels(ctx context.Context, dir, imageName, version string, extra map[string]string) map[string]string {
+	repository, _ := SplitReference(imageName)
+	labels := map[string]string{
+		LabelCreated: time.Now().UTC().Format(time.RFC3339),
+		LabelTitle:   path.Base(repository),
+		LabelVersion: version,
+	}
+	if info, err := git.Describe(ctx, dir); err == nil {
+		labels[LabelRevision] = info.SHA
+	}
+	if source := git.SourceURL(ctx, dir); source != "" {
+		labels[LabelSource] = source
+	}
+	for key, value := range extra {
+		labels[key] = value
+	}
+	return labels
+}
+
+// ImageInfo describes an image and its labels.
+type ImageInfo struct {
+	Image    string            `json:"image"`
+	Digest   string            `json:"digest,omitempty"`
+	Platform string            `json:"platform,omitempty"`
+	Created  string            `json:"created,omitempty"`
+	Labels   map[string]string `json:"labels"`
+}
+
+// InspectImage reads the labels of a local image.
+func InspectImage(ctx context.Context, ref string) (*ImageInfo, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer cli.Close()
+
+	inspect, _, err := cli.ImageInspectWithRaw(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect image %s: %w", ref, err)
+	}
+
+	info := &ImageInfo{
+		Image:    ref,
+		Platform: inspect.Os + "/" + inspect.Architecture,
+		Created:  inspect.Created,
+		Labels:   map[string]string{},
+	}
+	if len(inspect.RepoDigests) > 0 {
+		_, info.Digest, _ = strings.Cut(inspect.RepoDigests[0], "@")
+	}
+	if inspect.Config != nil && inspect.Config.Labels != nil {
+		info.Labels = inspect.Config.Labels
+	}
+	return info, nil
+}
+
+// InspectRemoteImage reads the labels of an image in a registry, using the
+// credentials from the Docker config. For a multi-platform image the labels
+// of platform are read, or of linux on the host architecture when it is empty.
+func InspectRemoteImage(ctx context.Context, ref, platform string) (*ImageInfo, error) {
+	imageRef, err := name.ParseReference(ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reference '%s': %w", ref, err)
+	}
+	p := &v1.Platform{OS: "linux", Architecture: runtime.GOARCH}
+	if platform != "" {
+		if p, err = v1.ParsePlatform(platform); err != nil {
+			return nil, fmt.Errorf("invalid platform '%s': %w", platform, err)
+		}
+	}
+
+	reg := &baseRegistry{host: imageRef.Context().RegistryStr()}
+	reg.auth = resolveCredentials(reg.host, reg.auth, credentialEnv{})
+	img, err := remote.Image(imageRef, append(reg.remoteOptions(ctx), remote.WithPlatform(*p))...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", ref, err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return nil, err
+	}
+	config, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the configuration of %s: %w", ref, err)
+	}
+
+	info := &ImageInfo{
+		Image:    ref,
+		Digest:   digest.String(),
+		Platform: config.Platform().String(),
+		Labels:   config.Config.Labels,
+	}
+	if !config.Created.IsZero() {
+		info.Created = config.Created.UTC().Format(time.RFC3339)
+	}
+	if info.Labels == nil {
+		info.Labels = map[string]string{}
+	}
+	return info, nil
+}
@@ -0,0 +1,267 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/clouddrove/smurf/internal/dryrun"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pterm/pterm"
+)
+
+// PlatformImage is a single-platform image that is part of a multi-platform image.
+type PlatformImage struct {
+	Platform string
+	Ref      string
+}
+
+// Platforms splits a comma separated --platform value such as linux/amd64,linux/arm64.
+func Platforms(platform string) []string {
+	var platforms []string
+	for _, p := range strings.Split(platform, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			platforms = append(platforms, p)
+		}
+	}
+	return platforms
+}
+
+// PlatformReference returns the reference a single platform of the multi-platform
+// image ref is built and pushed under, such as myapp:v1-linux-arm64.
+func PlatformReference(ref, platform string) string {
+	repository, tag := SplitReference(ref)
+	if tag == "" {
+		tag = "latest"
+	}
+	return fmt.Sprintf("%s:%s-%s", repository, tag, strings.ReplaceAll(platform, "/", "-"))
+}
+
+// buildPlatforms builds every platform as its own image, since the local image
+// store holds one platform per tag, and tags the image matching the host, or
+// else the first one, as imageName:tag so it can be run and scanned locally.
+func buildPlatforms(ctx context.Context, imageName, tag string, opts BuildOptions, platforms []string) error {
+	for _, platform := range platforms {
+		if _, err := v1.ParsePlatform(platform); err != nil {
+			return fmt.Errorf("invalid platform '%s': %w", platform, err)
+		}
+	}
+
+	if opts.DockerfilePath == "-" {
+		// Every platform build reads the Dockerfile, so stdin is saved once
+		dockerfile, err := os.CreateTemp("", "Dockerfile.*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(dockerfile.Name())
+		if _, err := io.Copy(dockerfile, os.Stdin); err != nil {
+			dockerfile.Close()
+			return fmt.Errorf("failed to read Dockerfile from stdin: %w", err)
+		}
+		dockerfile.Close()
+		opts.DockerfilePath = dockerfile.Name()
+		if opts.ContextDir == "" {
+			opts.ContextDir = "."
+		}
+	}
+
+	// Labels are set once so every platform carries the same version and creation time
+	contextDir := opts.ContextDir
+	if contextDir == "" {
+		contextDir = filepath.Dir(opts.DockerfilePath)
+	}
+	opts.Labels = imageLabels(ctx, contextDir, imageName, tag, opts.Labels)
+
+	image := fmt.Sprintf("%s:%s", imageName, tag)
+	local := platforms[0]
+	for _, platform := range platforms {
+		pterm.Info.Printf("Building %s for %s...\n", image, platform)
+		platformOpts := opts
+		platformOpts.Platform = platform
+		platformOpts.AdditionalTags = nil
+		for _, extra := range opts.AdditionalTags {
+			_, extraTag := SplitReference(PlatformReference(imageName+":"+extra, platform))
+			platformOpts.AdditionalTags = append(platformOpts.AdditionalTags, extraTag)
+		}
+		platformImage := PlatformReference(image, platform)
+		platformName, platformTag := SplitReference(platformImage)
+		if err := Build(ctx, platformName, platformTag, platformOpts); err != nil {
+			return fmt.Errorf("build for %s failed: %w", platform, err)
+		}
+		if p, _ := v1.ParsePlatform(platform); p.OS == "linux" && p.Architecture == runtime.GOARCH {
+			local = platform
+		}
+	}
+
+	for _, t := range append([]string{tag}, opts.AdditionalTags...) {
+		target := fmt.Sprintf("%s:%s", imageName, t)
+		if err := TagImage(ctx, TagOptions{Source: PlatformReference(target, local), Target: target}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PushPlatforms pushes the single-platform images built for source by a
+// multi-platform build, then combines them into an image index tagged with
+// the tag of source. The daemon can only push tagged images, so each platform
+// goes out under a temporary TAG-OS-ARCH tag; the index refers to the images by
+// digest, and the temporary tags are removed once it is pushed. A single
+// platform is pushed as a plain image with PushTo.
+func PushPlatforms(ctx context.Context, reg Registry, source, repository string, platforms []string) (*PushResult, error) {
+	if len(platforms) < 2 {
+		return PushTo(ctx, reg, source, repository)
+	}
+
+	repository, tag, err := preparePush(ctx, reg, source, repository)
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]PlatformImage, 0, len(platforms))
+	var helperTags []string
+	for _, platform := range platforms {
+		target := PlatformReference(reg.Reference(repository, tag), platform)
+		pushed, err := pushTagged(ctx, reg, PlatformReference(source, platform), target)
+		if err != nil {
+			return nil, fmt.Errorf("push for %s failed: %w", platform, err)
+		}
+		image := PlatformImage{Platform: platform, Ref: target}
+		if pushed.Digest != "" {
+			image.Ref = pushed.Reference
+			_, helperTag := SplitReference(target)
+			helperTags = append(helperTags, helperTag)
+		}
+		images = append(images, image)
+	}
+
+	target := reg.Reference(repository, tag)
+	result, err := reg.PushIndex(ctx, target, images)
+	if err != nil {
+		return nil, err
+	}
+	if !dryrun.Enabled() {
+		for _, helperTag := range helperTags {
+			if err := reg.Untag(ctx, repository, helperTag); err != nil {
+				pterm.Warning.Printf("Could not remove the temporary tag %s, the image index does not depend on it: %v\n", helperTag, err)
+			}
+		}
+		pterm.Success.Printf("Successfully pushed %d-platform image to %s: %s\n", len(images), reg.Name(), target)
+	}
+	reportDigest(result)
+	return result, nil
+}
+
+// PushIndex writes an image index at ref that lists the pushed images, each
+// with its platform. The images must be in the same repository as ref.
+func (b *baseRegistry) PushIndex(ctx context.Context, ref string, images []PlatformImage) (*PushResult, error) {
+	if dryrun.Enabled() {
+		platforms := make([]string, len(images))
+		for i, img := range images {
+			platforms[i] = img.Platform
+		}
+		dryrun.Record("docker", "push", ref, "image index for "+strings.Join(platforms, ", "))
+		return &PushResult{Image: ref}, nil
+	}
+
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Creating image index %s...", ref))
+	var index v1.ImageIndex = empty.Index
+	var mediaType types.MediaType
+	for _, img := range images {
+		addendum, err := b.platformManifest(ctx, img)
+		if err != nil {
+			spinner.Fail(err.Error())
+			return nil, err
+		}
+		if mediaType == "" {
+			// A Docker manifest list keeps registries that predate OCI indexes happy
+			mediaType = types.OCIImageIndex
+			if addendum.MediaType == types.DockerManifestSchema2 {
+				mediaType = types.DockerManifestList
+			}
+		}
+		index = mutate.AppendManifests(index, addendum)
+	}
+	index = mutate.IndexMediaType(index, mediaType)
+
+	indexRef, err := name.ParseReference(ref, b.nameOptions()...)
+	if err != nil {
+		spinner.Fail("Invalid reference " + ref)
+		return nil, fmt.Errorf("invalid reference '%s': %w", ref, err)
+	}
+	if err := remote.WriteIndex(indexRef, index, b.remoteOptions(ctx)...); err != nil {
+		spinner.Fail("Failed to push image index")
+		return nil, fmt.Errorf("failed to push image index %s: %w", ref, err)
+	}
+	digest, err := index.Digest()
+	if err != nil {
+		return nil, err
+	}
+
+	spinner.Success(fmt.Sprintf("Image index pushed: %s", ref))
+	return newPushResult(ref, digest.String()), nil
+}
+
+// platformManifest resolves the manifest pushed for one platform. A push from
+// the containerd image store may wrap it in an index of its own, in which case
+// the manifest for the platform is taken from that index.
+func (b *baseRegistry) platformManifest(ctx context.Context, img PlatformImage) (mutate.IndexAddendum, error) {
+	platform, err := v1.ParsePlatform(img.Platform)
+	if err != nil {
+		return mutate.IndexAddendum{}, fmt.Errorf("invalid platform '%s': %w", img.Platform, err)
+	}
+	ref, err := name.ParseReference(img.Ref, b.nameOptions()...)
+	if err != nil {
+		return mutate.IndexAddendum{}, fmt.Errorf("invalid reference '%s': %w", img.Ref, err)
+	}
+	desc, err := remote.Get(ref, b.remoteOptions(ctx)...)
+	if err != nil {
+		return mutate.IndexAddendum{}, fmt.Errorf("failed to fetch %s: %w", img.Ref, err)
+	}
+
+	var image v1.Image
+	if desc.MediaType.IsIndex() {
+		child, err := desc.ImageIndex()
+		if err != nil {
+			return mutate.IndexAddendum{}, err
+		}
+		manifest, err := child.IndexManifest()
+		if err != nil {
+			return mutate.IndexAddendum{}, err
+		}
+		for _, m := range manifest.Manifests {
+			if m.MediaType.IsImage() && m.Platform != nil && m.Platform.Satisfies(*platform) {
+				if image, err = child.Image(m.Digest); err != nil {
+					return mutate.IndexAddendum{}, err
+				}
+				break
+			}
+		}
+		if image == nil {
+			return mutate.IndexAddendum{}, fmt.Errorf("%s has no image for %s", img.Ref, img.Platform)
+		}
+	} else if image, err = desc.Image(); err != nil {
+		return mutate.IndexAddendum{}, err
+	}
+
+	mediaType, err := image.MediaType()
+	if err != nil {
+		return mutate.IndexAddendum{}, err
+	}
+	return mutate.IndexAddendum{
+		Add: image,
+		Descriptor: v1.Descriptor{
+			MediaType: mediaType,
+			Platform:  platform,
+		},
+	}, nil
+}
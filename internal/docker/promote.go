@@ -0,0 +1,189 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/clouddrove/smurf/internal/dryrun"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pterm/pterm"
+)
+
+// ecrHost matches the registry host of an AWS account, capturing the account
+// ID and the region.
+var ecrHost = regexp.MustCompile(`^(\d{12})\.dkr\.ecr(?:-fips)?\.([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?$`)
+
+// Promotion describes an image copied from one registry to another.
+type Promotion struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Digest      string `json:"digest"`
+	// Reference pins the copy by digest, as REPOSITORY@DIGEST.
+	Reference string `json:"reference,omitempty"`
+	// Platforms lists the platforms of a multi-platform image.
+	Platforms []string `json:"platforms,omitempty"`
+	// UpToDate is set when the destination already pointed to the digest.
+	UpToDate bool `json:"upToDate,omitempty"`
+}
+
+// PromoteImage copies the image at src to dest directly between the registries,
+// without going through the Docker daemon. Manifests are copied unchanged, so
+// the digest and the platforms of a multi-platform image are preserved, and
+// blobs the destination already has are not uploaded again. A destination
+// without a tag or digest takes the one of src.
+//
+// Each registry is authenticated like the matching push command, going by its
+// host: ECR, ACR, GCR and Artifact Registry, and Docker Hub, with any other host
+// treated as a generic registry. opts holds the Azure settings of an ACR
+// registry and whether plain HTTP is allowed.
+func PromoteImage(ctx context.Context, src, dest string, opts RegistryOptions) (*Promotion, error) {
+	var nameOpts []name.Option
+	if opts.Insecure {
+		nameOpts = append(nameOpts, name.Insecure)
+	}
+	srcRef, err := name.ParseReference(src, nameOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source '%s': %w", src, err)
+	}
+	if !hasTagOrDigest(dest) {
+		switch ref := srcRef.(type) {
+		case name.Tag:
+			dest += ":" + ref.TagStr()
+		case name.Digest:
+			dest += "@" + ref.DigestStr()
+		}
+	}
+	destRef, err := name.ParseReference(dest, nameOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination '%s': %w", dest, err)
+	}
+
+	srcReg, err := authenticatedRegistry(ctx, srcRef.Context(), opts)
+	if err != nil {
+		return nil, err
+	}
+	destReg, err := authenticatedRegistry(ctx, destRef.Context(), opts)
+	if err != nil {
+		return nil, err
+	}
+	srcOpts := srcReg.(remoteClient).remoteOptions(ctx)
+	destOpts := destReg.(remoteClient).remoteOptions(ctx)
+
+	desc, err := remote.Get(srcRef, srcOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", src, err)
+	}
+	promotion := &Promotion{Source: src, Destination: dest, Digest: desc.Digest.String()}
+	promotion.Reference = newPushResult(dest, promotion.Digest).Reference
+	if desc.MediaType.IsIndex() {
+		index, err := desc.ImageIndex()
+		if err != nil {
+			return nil, err
+		}
+		manifest, err := index.IndexManifest()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range manifest.Manifests {
+			if m.Platform != nil && m.Platform.OS != "unknown" {
+				promotion.Platforms = append(promotion.Platforms, m.Platform.String())
+			}
+		}
+	}
+
+	if current, err := remote.Head(destRef, destOpts...); err == nil && current.Digest == desc.Digest {
+		promotion.UpToDate = true
+		pterm.Info.Printf("%s is already at %s\n", dest, promotion.Digest)
+		return promotion, nil
+	}
+
+	if dryrun.Enabled() {
+		dryrun.Record("docker", "copy", dest, fmt.Sprintf("from %s (%s)", src, promotion.Digest))
+		return promotion, nil
+	}
+
+	if err := destReg.EnsureRepository(ctx, repositoryPath(destReg, destRef.Context())); err != nil {
+		return nil, fmt.Errorf("failed to prepare repository %s: %w", destRef.Context(), err)
+	}
+
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Copying %s to %s...", src, dest))
+	if desc.MediaType.IsIndex() {
+		index, err := desc.ImageIndex()
+		if err == nil {
+			err = remote.WriteIndex(destRef, index, destOpts...)
+		}
+		if err != nil {
+			spinner.Fail("Failed to copy the image")
+			return nil, fmt.Errorf("failed to copy %s to %s: %w", src, dest, err)
+		}
+	} else {
+		image, err := desc.Image()
+		if err == nil {
+			err = remote.Write(destRef, image, destOpts...)
+		}
+		if err != nil {
+			spinner.Fail("Failed to copy the image")
+			return nil, fmt.Errorf("failed to copy %s to %s: %w", src, dest, err)
+		}
+	}
+	spinner.Success(fmt.Sprintf("Promoted %s to %s", src, dest))
+	reportDigest(&PushResult{Image: dest, Digest: promotion.Digest, Reference: promotion.Reference})
+	return promotion, nil
+}
+
+// remoteClient is implemented by every registry through baseRegistry.
+type remoteClient interface {
+	remoteOptions(ctx context.Context) []remote.Option
+	nameOptions() []name.Option
+}
+
+// registryForRepository returns the registry implementation for the host of
+// repo, with the Azure settings from opts for an ACR registry.
+func registryForRepository(repo name.Repository, opts RegistryOptions) Registry {
+	host := repo.RegistryStr()
+	switch {
+	case host == name.DefaultRegistry:
+		return NewHubRegistry("", "")
+	case ecrHost.MatchString(host):
+		match := ecrHost.FindStringSubmatch(host)
+		reg := NewECRRegistry(match[2])
+		reg.registryID = match[1]
+		return reg
+	case strings.HasSuffix(host, ".azurecr.io"):
+		return NewACRRegistry(opts.SubscriptionID, opts.ResourceGroup, strings.TrimSuffix(host, ".azurecr.io"))
+	case host == "gcr.io" || strings.HasSuffix(host, ".gcr.io") || strings.HasSuffix(host, "-docker.pkg.dev"):
+		project, _, _ := strings.Cut(repo.RepositoryStr(), "/")
+		return NewGCRRegistry(project)
+	default:
+		return NewGenericRegistry(host, "", "", "")
+	}
+}
+
+// authenticatedRegistry returns the registry of repo after authenticating with it.
+func authenticatedRegistry(ctx context.Context, repo name.Repository, opts RegistryOptions) (Registry, error) {
+	reg := registryForRepository(repo, opts)
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Authenticating with %s...", repo.RegistryStr()))
+	if err := reg.Authenticate(ctx); err != nil {
+		spinner.Fail(fmt.Sprintf("Failed to authenticate with %s", repo.RegistryStr()))
+		return nil, fmt.Errorf("failed to authenticate with %s: %w", repo.RegistryStr(), err)
+	}
+	spinner.Success(fmt.Sprintf("Authenticated with %s", repo.RegistryStr()))
+	return reg, nil
+}
+
+// repositoryPath returns the repository of repo as the registry's own methods
+// expect it, which for GCR is without the project.
+func repositoryPath(reg Registry, repo name.Repository) string {
+	if gcr, ok := reg.(*GCRRegistry); ok {
+		return strings.TrimPrefix(repo.RepositoryStr(), gcr.projectID+"/")
+	}
+	return repo.RepositoryStr()
+}
+
+// hasTagOrDigest reports whether ref names a tag or a digest rather than only a repository.
+func hasTagOrDigest(ref string) bool {
+	return strings.Contains(ref, "@") || strings.LastIndex(ref, ":") > strings.LastIndex(ref, "/")
+}
@@ -0,0 +1,134 @@
+package docker
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+func TestPromoteImage(t *testing.T) {
+	clearCredentials(t)
+	src := newTestRegistry(t, "", "", "")
+	dest := newTestRegistry(t, "", "", "")
+	ctx := context.Background()
+
+	base, err := random.Image(1024, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(mustReference(t, src.host+"/team/myapp:v1"), base, src.auth()); err != nil {
+		t.Fatal(err)
+	}
+	layer, err := random.Layer(1024, "application/vnd.oci.image.layer.v1.tar+gzip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := mutate.AppendLayers(base, layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(mustReference(t, src.host+"/team/myapp:v2"), next, src.auth()); err != nil {
+		t.Fatal(err)
+	}
+
+	index := v1.ImageIndex(empty.Index)
+	for _, platform := range []v1.Platform{{OS: "linux", Architecture: "amd64"}, {OS: "linux", Architecture: "arm64", Variant: "v8"}} {
+		img, err := random.Image(512, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		index = mutate.AppendManifests(index, mutate.IndexAddendum{Add: img, Descriptor: v1.Descriptor{Platform: &platform}})
+	}
+	if err := remote.WriteIndex(mustReference(t, src.host+"/team/myapp:multi"), index, src.auth()); err != nil {
+		t.Fatal(err)
+	}
+	indexDigest, err := index.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		src, dest       string
+		wantDestination string
+		wantPlatforms   []string
+		wantUpToDate    bool
+		// wantUploads is the number of blobs the destination receives.
+		wantUploads int32
+	}{
+		{
+			name:            "tag carried over",
+			src:             src.host + "/team/myapp:v1",
+			dest:            dest.host + "/prod/myapp",
+			wantDestination: dest.host + "/prod/myapp:v1",
+			wantUploads:     2,
+		},
+		{
+			name:            "already promoted",
+			src:             src.host + "/team/myapp:v1",
+			dest:            dest.host + "/prod/myapp:v1",
+			wantDestination: dest.host + "/prod/myapp:v1",
+			wantUpToDate:    true,
+		},
+		{
+			name:            "shared layer skipped",
+			src:             src.host + "/team/myapp:v2",
+			dest:            dest.host + "/prod/myapp:release",
+			wantDestination: dest.host + "/prod/myapp:release",
+			// The new layer and the config
+			wantUploads: 2,
+		},
+		{
+			name:            "multi-platform index by digest",
+			src:             src.host + "/team/myapp@" + indexDigest.String(),
+			dest:            dest.host + "/prod/myapp",
+			wantDestination: dest.host + "/prod/myapp@" + indexDigest.String(),
+			wantPlatforms:   []string{"linux/amd64", "linux/arm64/v8"},
+			// A layer and a config for each platform
+			wantUploads: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := src.head(t, tt.src)
+			dest.uploads.Store(0)
+			promotion, err := PromoteImage(ctx, tt.src, tt.dest, RegistryOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if promotion.Destination != tt.wantDestination || promotion.Digest != want.String() || promotion.UpToDate != tt.wantUpToDate {
+				t.Errorf("promotion = %+v, want %s at %s, up to date %t", promotion, tt.wantDestination, want, tt.wantUpToDate)
+			}
+			if !slices.Equal(promotion.Platforms, tt.wantPlatforms) {
+				t.Errorf("platforms = %v, want %v", promotion.Platforms, tt.wantPlatforms)
+			}
+			if got := dest.head(t, tt.wantDestination); got != want {
+				t.Errorf("%s is at %s, want %s", tt.wantDestination, got, want)
+			}
+			if got := dest.uploads.Load(); got != tt.wantUploads {
+				t.Errorf("uploaded %d blobs, want %d", got, tt.wantUploads)
+			}
+		})
+	}
+
+	// Every platform image of the index was copied along with it
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, child := range manifest.Manifests {
+		img, err := remote.Image(mustReference(t, dest.host+"/prod/myapp@"+child.Digest.String()), dest.auth())
+		if err != nil {
+			t.Fatalf("platform image %s was not copied: %v", child.Digest, err)
+		}
+		if _, err := img.RawConfigFile(); err != nil {
+			t.Errorf("config of %s: %v", child.Digest, err)
+		}
+	}
+}
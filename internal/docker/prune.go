@@ -0,0 +1,351 @@
+package docker
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/dryrun"
+	"github.com/clouddrove/smurf/internal/output"
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/go-units"
+	"github.com/pterm/pterm"
+)
+
+// PrunePolicy selects the local images and build cache a prune removes. A
+// tagged image in a repository matching Repositories is removed only when it is
+// not among the KeepLast most recent ones of its repository and is older than
+// OlderThan; rules that are not set do not keep anything, and no Repositories
+// means every repository. Dangling images are removed when Dangling is set and
+// they are older than OlderThan. The build cache is pruned down to CacheBudget
+// bytes, unless it is negative.
+type PrunePolicy struct {
+	Repositories []string
+	KeepLast     int
+	OlderThan    time.Duration
+	Dangling     bool
+	CacheBudget  int64
+}
+
+// PruneItem is a local image a prune removes, by tag or, for a dangling image
+// without one, by ID.
+type PruneItem struct {
+	Image   string    `json:"image,omitempty"`
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	Size    int64     `json:"size"`
+	Reason  string    `json:"reason"`
+}
+
+// PrunePlan lists the local images and build cache a prune policy removes.
+type PrunePlan struct {
+	Kept   int         `json:"kept"`
+	Remove []PruneItem `json:"remove"`
+	// InUse lists the images the policy selects that running containers use.
+	// They are never removed.
+	InUse []string `json:"inUse,omitempty"`
+	// CacheSize is the size of the build cache and CacheBudget the size it is
+	// pruned down to, or -1 when it is left alone.
+	CacheSize   int64 `json:"cacheSize"`
+	CacheBudget int64 `json:"cacheBudget"`
+	// Removed counts the images removed once the plan is applied, and
+	// Reclaimed the bytes freed on disk by them and the build cache.
+	Removed   int   `json:"removed"`
+	Reclaimed int64 `json:"reclaimed"`
+}
+
+// ParseSize parses a size such as 10GB or 512MB, in multiples of 1024.
+func ParseSize(size string) (int64, error) {
+	n, err := units.RAMInBytes(size)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size '%s' (expected e.g. 10GB or 512MB)", size)
+	}
+	return n, nil
+}
+
+// PlanPrune works out which local images and how much build cache policy
+// removes. Nothing is removed until the plan is applied.
+func PlanPrune(ctx context.Context, policy PrunePolicy) (*PrunePlan, error) {
+	removeTagged := len(policy.Repositories) > 0 || policy.KeepLast > 0 || policy.OlderThan > 0
+	if !removeTagged && !policy.Dangling && policy.CacheBudget < 0 {
+		return nil, errors.New("no prune rule given: set a repository pattern, a number of images to keep, a minimum age, dangling images or a cache budget")
+	}
+	for _, pattern := range policy.Repositories {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid repository pattern '%s': %w", pattern, err)
+		}
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer cli.Close()
+
+	running, err := cli.ContainerList(ctx, container.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list running containers: %w", err)
+	}
+	var images, dangling []image.Summary
+	if removeTagged {
+		if images, err = cli.ImageList(ctx, image.ListOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to list local images: %w", err)
+		}
+	}
+	if policy.Dangling {
+		dangling, err = cli.ImageList(ctx, image.ListOptions{Filters: filters.NewArgs(filters.Arg("dangling", "true"))})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list dangling images: %w", err)
+		}
+	}
+	plan := planPrune(policy, time.Now(), running, images, dangling)
+
+	if policy.CacheBudget >= 0 {
+		usage, err := cli.DiskUsage(ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.BuildCacheObject}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the build cache size: %w", err)
+		}
+		for _, record := range usage.BuildCache {
+			if !record.Shared {
+				plan.CacheSize += record.Size
+			}
+		}
+	}
+	return plan, nil
+}
+
+// planPrune selects the images policy removes at now from the tagged images and
+// the dangling images of the daemon, keeping those the running containers use.
+// The tags of an image in a repository are kept or removed together, so that
+// KeepLast counts images rather than tags.
+func planPrune(policy PrunePolicy, now time.Time, running []types.Container, images, dangling []image.Summary) *PrunePlan {
+	inUse := make(map[string]bool, len(running))
+	for _, c := range running {
+		inUse[c.ImageID] = true
+	}
+
+	plan := &PrunePlan{Remove: []PruneItem{}, CacheBudget: policy.CacheBudget}
+	// selected removes an image by each of refs, or by ID without any
+	selected := func(summary image.Summary, refs []string, reason string) {
+		if inUse[summary.ID] {
+			if len(refs) == 0 {
+				refs = []string{shortDigest(summary.ID)}
+			}
+			plan.InUse = append(plan.InUse, refs...)
+			plan.Kept++
+			return
+		}
+		if len(refs) == 0 {
+			refs = []string{""}
+		}
+		for _, ref := range refs {
+			plan.Remove = append(plan.Remove, PruneItem{
+				Image:   ref,
+				ID:      summary.ID,
+				Created: time.Unix(summary.Created, 0),
+				Size:    summary.Size,
+				Reason:  reason,
+			})
+		}
+	}
+
+	// localImage is an image and its tags in one repository
+	type localImage struct {
+		summary image.Summary
+		refs    []string
+	}
+	repositories := map[string][]*localImage{}
+	for _, summary := range images {
+		for _, tag := range summary.RepoTags {
+			named, err := reference.ParseNormalizedNamed(tag)
+			if err != nil || !matchesRepository(policy.Repositories, named) {
+				continue
+			}
+			repo := reference.FamiliarName(named)
+			i := slices.IndexFunc(repositories[repo], func(img *localImage) bool { return img.summary.ID == summary.ID })
+			if i < 0 {
+				repositories[repo] = append(repositories[repo], &localImage{summary: summary})
+				i = len(repositories[repo]) - 1
+			}
+			img := repositories[repo][i]
+			img.refs = append(img.refs, reference.FamiliarString(named))
+		}
+	}
+
+	var reasons []string
+	if len(policy.Repositories) > 0 {
+		reasons = append(reasons, "matches "+strings.Join(policy.Repositories, ", "))
+	}
+	if policy.KeepLast > 0 {
+		reasons = append(reasons, fmt.Sprintf("not among the last %d", policy.KeepLast))
+	}
+	if policy.OlderThan > 0 {
+		reasons = append(reasons, "older than "+formatAge(policy.OlderThan))
+	}
+	repos := make([]string, 0, len(repositories))
+	for repo := range repositories {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+	for _, repo := range repos {
+		imgs := repositories[repo]
+		sort.SliceStable(imgs, func(i, j int) bool {
+			return imgs[i].summary.Created > imgs[j].summary.Created ||
+				(imgs[i].summary.Created == imgs[j].summary.Created && imgs[i].summary.ID < imgs[j].summary.ID)
+		})
+		for i, img := range imgs {
+			created := time.Unix(img.summary.Created, 0)
+			if (policy.KeepLast > 0 && i < policy.KeepLast) || (policy.OlderThan > 0 && now.Sub(created) <= policy.OlderThan) {
+				plan.Kept++
+				continue
+			}
+			sort.Strings(img.refs)
+			selected(img.summary, img.refs, strings.Join(reasons, ", "))
+		}
+	}
+
+	for _, summary := range dangling {
+		if policy.OlderThan > 0 && now.Sub(time.Unix(summary.Created, 0)) <= policy.OlderThan {
+			plan.Kept++
+			continue
+		}
+		selected(summary, nil, "dangling")
+	}
+	return plan
+}
+
+// ApplyPrune removes the images of plan and prunes the build cache down to its
+// budget. Images that stopped containers still use are skipped with a warning,
+// and other failures are reported at the end.
+func ApplyPrune(ctx context.Context, plan *PrunePlan) error {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer cli.Close()
+
+	if dryrun.Enabled() {
+		for _, item := range plan.Remove {
+			dryrun.Record("docker", "delete", cmp.Or(item.Image, shortDigest(item.ID)), "local image, "+item.Reason)
+		}
+		if plan.CacheBudget >= 0 && plan.CacheSize > plan.CacheBudget {
+			dryrun.Record("docker", "prune", "build cache", fmt.Sprintf("from %s down to %s",
+				units.BytesSize(float64(plan.CacheSize)), units.BytesSize(float64(plan.CacheBudget))))
+		}
+		return nil
+	}
+
+	failed := 0
+	if len(plan.Remove) > 0 {
+		// Shared layers make the size of each image an overestimate, so the
+		// space freed is measured on the daemon instead
+		before, err := cli.DiskUsage(ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.ImageObject}})
+		if err != nil {
+			return fmt.Errorf("failed to read the image disk usage: %w", err)
+		}
+		spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Removing %d images...", len(plan.Remove)))
+		for _, item := range plan.Remove {
+			_, err := cli.ImageRemove(ctx, cmp.Or(item.Image, item.ID), image.RemoveOptions{PruneChildren: true})
+			switch {
+			case err == nil:
+				plan.Removed++
+			case ctx.Err() != nil:
+				spinner.Fail("Prune cancelled")
+				return fmt.Errorf("prune cancelled after removing %d images: %w", plan.Removed, ctx.Err())
+			case errdefs.IsConflict(err):
+				pterm.Warning.Printf("Kept %s: %v\n", cmp.Or(item.Image, shortDigest(item.ID)), err)
+			case errdefs.IsNotFound(err):
+				// Removing another tag or a parent already removed it
+				plan.Removed++
+			default:
+				pterm.Error.Println(err)
+				failed++
+			}
+		}
+		after, err := cli.DiskUsage(ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.ImageObject}})
+		if err != nil {
+			return fmt.Errorf("failed to read the image disk usage: %w", err)
+		}
+		if freed := before.LayersSize - after.LayersSize; freed > 0 {
+			plan.Reclaimed += freed
+		}
+		spinner.Success(fmt.Sprintf("Removed %d images", plan.Removed))
+	}
+
+	if plan.CacheBudget >= 0 && plan.CacheSize > plan.CacheBudget {
+		spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Pruning the build cache down to %s...", units.BytesSize(float64(plan.CacheBudget))))
+		report, err := cli.BuildCachePrune(ctx, types.BuildCachePruneOptions{All: true, KeepStorage: plan.CacheBudget})
+		if err != nil {
+			spinner.Fail("Failed to prune the build cache")
+			return fmt.Errorf("failed to prune the build cache: %w", err)
+		}
+		plan.Reclaimed += int64(report.SpaceReclaimed)
+		spinner.Success(fmt.Sprintf("Pruned %s of build cache", units.BytesSize(float64(report.SpaceReclaimed))))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to remove %d of %d images", failed, len(plan.Remove))
+	}
+	pterm.Success.Printf("Reclaimed %s\n", units.BytesSize(float64(plan.Reclaimed)))
+	return nil
+}
+
+// PrintPrunePlan previews the images and build cache a prune removes.
+func PrintPrunePlan(plan *PrunePlan) {
+	for _, ref := range plan.InUse {
+		pterm.Info.Printf("Keeping %s, a running container uses it\n", ref)
+	}
+	if len(plan.Remove) == 0 {
+		pterm.Info.Printf("No local images to remove, %d kept\n", plan.Kept)
+	} else {
+		var size int64
+		ids := map[string]bool{}
+		data := pterm.TableData{{"Image", "ID", "Created", "Size", "Reason"}}
+		for _, item := range plan.Remove {
+			data = append(data, []string{cmp.Or(item.Image, "<none>"), shortDigest(item.ID), item.Created.Local().Format(time.DateTime),
+				units.BytesSize(float64(item.Size)), item.Reason})
+			if !ids[item.ID] {
+				ids[item.ID] = true
+				size += item.Size
+			}
+		}
+		pterm.DefaultTable.WithHasHeader().WithWriter(output.Writer()).WithData(data).Render()
+		pterm.Info.Printf("%d images to remove (up to %s), %d kept\n", len(plan.Remove), units.BytesSize(float64(size)), plan.Kept)
+	}
+	if plan.CacheBudget >= 0 {
+		if plan.CacheSize > plan.CacheBudget {
+			pterm.Info.Printf("Build cache of %s to prune down to %s\n",
+				units.BytesSize(float64(plan.CacheSize)), units.BytesSize(float64(plan.CacheBudget)))
+		} else {
+			pterm.Info.Printf("Build cache of %s is within the budget of %s\n",
+				units.BytesSize(float64(plan.CacheSize)), units.BytesSize(float64(plan.CacheBudget)))
+		}
+	}
+}
+
+// matchesRepository reports whether named matches one of patterns, which are
+// matched like the reference filter of 'docker image ls'. No patterns match
+// every image.
+func matchesRepository(patterns []string, named reference.Named) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := reference.FamiliarMatch(pattern, named); ok {
+			return true
+		}
+	}
+	return false
+}
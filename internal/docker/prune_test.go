@@ -0,0 +1,112 @@
+package docker
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+)
+
+func TestPlanPrune(t *testing.T) {
+	now := time.Now()
+	summary := func(id string, age time.Duration, tags ...string) image.Summary {
+		return image.Summary{ID: "sha256:" + id, Created: now.Add(-age).Unix(), Size: 100, RepoTags: tags}
+	}
+	day := 24 * time.Hour
+	images := []image.Summary{
+		summary("aaa", 1*day, "myorg/api:v3", "myorg/api:latest"),
+		summary("bbb", 2*day, "myorg/api:v2", "myorg/api:stable", "myorg/api:2"),
+		summary("ccc", 3*day, "myorg/api:v1"),
+		summary("ddd", 10*day, "myorg/web:v1", "registry.example.com/web:v1"),
+		summary("eee", 20*day, "redis:7"),
+	}
+	dangling := []image.Summary{summary("fff", 1*day), summary("ggg", 30*day)}
+
+	tests := []struct {
+		name        string
+		policy      PrunePolicy
+		running     []string
+		dangling    []image.Summary
+		wantRemoved []string
+		wantInUse   []string
+		wantKept    int
+	}{
+		{
+			name:        "keep last counts images, not tags",
+			policy:      PrunePolicy{Repositories: []string{"myorg/api"}, KeepLast: 2},
+			wantRemoved: []string{"myorg/api:v1"},
+			wantKept:    2,
+		},
+		{
+			name:        "every tag of a removed image",
+			policy:      PrunePolicy{Repositories: []string{"myorg/api"}, KeepLast: 1},
+			wantRemoved: []string{"myorg/api:2", "myorg/api:stable", "myorg/api:v2", "myorg/api:v1"},
+			wantKept:    1,
+		},
+		{
+			name:        "keep last per repository",
+			policy:      PrunePolicy{KeepLast: 1},
+			wantRemoved: []string{"myorg/api:2", "myorg/api:stable", "myorg/api:v2", "myorg/api:v1"},
+			// api, web under two names and redis
+			wantKept: 4,
+		},
+		{
+			name:        "older than",
+			policy:      PrunePolicy{Repositories: []string{"myorg/*"}, OlderThan: 5 * day},
+			wantRemoved: []string{"myorg/web:v1"},
+			wantKept:    3,
+		},
+		{
+			name:        "used by a running container",
+			policy:      PrunePolicy{Repositories: []string{"myorg/api"}, KeepLast: 1},
+			running:     []string{"sha256:bbb"},
+			wantRemoved: []string{"myorg/api:v1"},
+			wantInUse:   []string{"myorg/api:2", "myorg/api:stable", "myorg/api:v2"},
+			wantKept:    2,
+		},
+		{
+			name:        "dangling",
+			policy:      PrunePolicy{Repositories: []string{"nothing"}, Dangling: true, OlderThan: 7 * day},
+			dangling:    dangling,
+			running:     []string{"sha256:fff"},
+			wantRemoved: []string{"sha256:ggg"},
+			wantKept:    1,
+		},
+		{
+			name:      "dangling image in use",
+			policy:    PrunePolicy{Repositories: []string{"nothing"}, Dangling: true},
+			dangling:  dangling[:1],
+			running:   []string{"sha256:fff"},
+			wantInUse: []string{shortDigest("sha256:fff")},
+			wantKept:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var running []types.Container
+			for _, id := range tt.running {
+				running = append(running, types.Container{ImageID: id})
+			}
+			plan := planPrune(tt.policy, now, running, images, tt.dangling)
+			var removed []string
+			for _, item := range plan.Remove {
+				if item.Image == "" {
+					removed = append(removed, item.ID)
+					continue
+				}
+				removed = append(removed, item.Image)
+			}
+			if !slices.Equal(removed, tt.wantRemoved) {
+				t.Errorf("removing %v, want %v", removed, tt.wantRemoved)
+			}
+			if !slices.Equal(plan.InUse, tt.wantInUse) {
+				t.Errorf("in use %v, want %v", plan.InUse, tt.wantInUse)
+			}
+			if plan.Kept != tt.wantKept {
+				t.Errorf("kept %d images, want %d", plan.Kept, tt.wantKept)
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/clouddrove/smurf/internal/dryrun"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/pterm/pterm"
+)
+
+// Registry is a remote container registry images can be published to.
+// Each provider only implements what differs, authentication and repository
+// management; tagging and pushing go through the local Docker daemon.
+type Registry interface {
+	// Name identifies the provider in messages.
+	Name() string
+	// Authenticate obtains credentials and resolves the registry host.
+	Authenticate(ctx context.Context) error
+	// EnsureRepository creates the repository when the provider needs it to exist before a push.
+	EnsureRepository(ctx context.Context, repository string) error
+	// Reference returns the fully qualified reference of repository:tag in this registry.
+	Reference(repository, tag string) string
+	// Tag tags a local image with a reference in this registry.
+	Tag(ctx context.Context, source, target string) error
+	// Push uploads a tagged local image.
+	Push(ctx context.Context, ref string) (*PushResult, error)
+	// Delete removes a tag from a remote repository.
+	Delete(ctx context.Context, repository, tag string) error
+	// Untag removes only a tag, leaving its manifest in place; it fails when the registry can only delete whole manifests.
+	Untag(ctx context.Context, repository, tag string) error
+	// ListTags lists the tags of a remote repository.
+	ListTags(ctx context.Context, repository string) ([]string, error)
+	// ListImages lists the images of a remote repository with their tags and push times.
+	ListImages(ctx context.Context, repository string) ([]RemoteImage, error)
+	// DeleteDigest removes an untagged manifest from a remote repository.
+	DeleteDigest(ctx context.Context, repository, digest string) error
+	// PushIndex combines pushed single-platform images into a multi-platform image at ref.
+	PushIndex(ctx context.Context, ref string, images []PlatformImage) (*PushResult, error)
+	// PushReferrer pushes data as an artifact of artifactType referring to the manifest at subject, a repository@digest reference.
+	PushReferrer(ctx context.Context, subject, artifactType string, data []byte) (*PushResult, error)
+	// PushSignature stores a signature of the manifest at subject, a repository@digest reference, next to it.
+	PushSignature(ctx context.Context, subject string, payload []byte, signature string) (*PushResult, error)
+}
+
+// RegistryOptions holds the provider specific settings used by NewRegistry.
+type RegistryOptions struct {
+	// Region is the AWS region of an ECR registry.
+	Region string
+	// RegistryID is the AWS account of an ECR registry other than the one of
+	// the credentials.
+	RegistryID string
+	// SubscriptionID, ResourceGroup and RegistryName locate an Azure Container Registry.
+	SubscriptionID string
+	ResourceGroup  string
+	RegistryName   string
+	// ProjectID is the GCP project of a GCR registry.
+	ProjectID string
+	// URL is the host of a generic registry.
+	URL string
+	// Username and Password authenticate against Docker Hub and generic registries with basic auth.
+	Username string
+	Password string
+	// Token authenticates against a generic registry with a bearer token instead.
+	Token string
+	// Insecure talks plain HTTP to a generic registry that is not on a local address.
+	Insecure bool
+}
+
+// Registries lists the providers accepted by NewRegistry.
+var Registries = []string{"hub", "ecr", "acr", "gcr", "generic"}
+
+// NewRegistry returns the registry implementation for kind, checking that the
+// options it needs are set.
+func NewRegistry(kind string, opts RegistryOptions) (Registry, error) {
+	switch kind {
+	case "hub":
+		return NewHubRegistry(opts.Username, opts.Password), nil
+	case "ecr":
+		if opts.Region == "" {
+			return nil, fmt.Errorf("registry ecr requires a region")
+		}
+		reg := NewECRRegistry(opts.Region)
+		reg.registryID = opts.RegistryID
+		return reg, nil
+	case "acr":
+		if opts.SubscriptionID == "" || opts.ResourceGroup == "" || opts.RegistryName == "" {
+			return nil, fmt.Errorf("registry acr requires a subscription ID, resource group and registry name")
+		}
+		return NewACRRegistry(opts.SubscriptionID, opts.ResourceGroup, opts.RegistryName), nil
+	case "gcr":
+		if opts.ProjectID == "" {
+			return nil, fmt.Errorf("registry gcr requires a project ID")
+		}
+		return NewGCRRegistry(opts.ProjectID), nil
+	case "generic":
+		if opts.URL == "" {
+			return nil, fmt.Errorf("registry generic requires a registry URL")
+		}
+		if opts.Token != "" && opts.Password != "" {
+			return nil, fmt.Errorf("registry generic accepts either a password or a token, not both")
+		}
+		reg := NewGenericRegistry(opts.URL, opts.Username, opts.Password, opts.Token)
+		if opts.Insecure {
+			reg.insecure = true
+		}
+		return reg, nil
+	default:
+		return nil, fmt.Errorf("unsupported registry '%s' (expected %s)", kind, strings.Join(Registries, ", "))
+	}
+}
+
+// PushTo authenticates with reg, makes sure the repository exists, tags the local
+// source image into the registry and pushes it. An empty repository defaults to
+// the repository of the source image.
+func PushTo(ctx context.Context, reg Registry, source, repository string) (*PushResult, error) {
+	repository, tag, err := preparePush(ctx, reg, source, repository)
+	if err != nil {
+		return nil, err
+	}
+
+	target := reg.Reference(repository, tag)
+	result, err := pushTagged(ctx, reg, source, target)
+	if err != nil {
+		return nil, err
+	}
+	if !dryrun.Enabled() {
+		pterm.Success.Printf("Successfully pushed image to %s: %s\n", reg.Name(), target)
+	}
+	reportDigest(result)
+	return result, nil
+}
+
+// preparePush authenticates with reg and makes sure the repository exists,
+// returning the repository and tag source is pushed as.
+func preparePush(ctx context.Context, reg Registry, source, repository string) (string, string, error) {
+	sourceRepository, tag := SplitReference(source)
+	if tag == "" {
+		tag = "latest"
+	}
+	if repository == "" {
+		repository = sourceRepository
+	}
+
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Authenticating with %s...", reg.Name()))
+	if err := reg.Authenticate(ctx); err != nil {
+		spinner.Fail(fmt.Sprintf("Failed to authenticate with %s", reg.Name()))
+		return "", "", fmt.Errorf("failed to authenticate with %s: %w", reg.Name(), err)
+	}
+	spinner.Success(fmt.Sprintf("Authenticated with %s", reg.Name()))
+
+	if err := reg.EnsureRepository(ctx, repository); err != nil {
+		pterm.Error.Printf("Failed to prepare repository %s: %v\n", repository, err)
+		return "", "", err
+	}
+	return repository, tag, nil
+}
+
+// pushTagged tags the local source image as target, unless they are the same, and pushes it.
+func pushTagged(ctx context.Context, reg Registry, source, target string) (*PushResult, error) {
+	if target != source {
+		if err := reg.Tag(ctx, source, target); err != nil {
+			return nil, err
+		}
+	}
+	return reg.Push(ctx, target)
+}
+
+// baseRegistry implements the parts of Registry shared by all providers:
+// tagging and pushing through the Docker daemon, and tag listing and deletion
+// through the registry HTTP API.
+type baseRegistry struct {
+	host     string
+	auth     registry.AuthConfig
+	insecure bool
+}
+
+func (b *baseRegistry) EnsureRepository(ctx context.Context, repository string) error {
+	// Most registries create repositories on first push
+	return nil
+}
+
+func (b *baseRegistry) Reference(repository, tag string) string {
+	return fmt.Sprintf("%s/%s:%s", b.host, repository, tag)
+}
+
+func (b *baseRegistry) Tag(ctx context.Context, source, target string) error {
+	return TagImage(ctx, TagOptions{Source: source, Target: target})
+}
+
+func (b *baseRegistry) Push(ctx context.Context, ref string) (*PushResult, error) {
+	if dryrun.Enabled() {
+		dryrun.Record("docker", "push", ref, "")
+		return &PushResult{Image: ref}, nil
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer cli.Close()
+
+	encodedAuth, err := encodeAuthToBase64(b.auth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode registry credentials: %w", err)
+	}
+
+	pushResponse, err := cli.ImagePush(ctx, ref, image.PushOptions{RegistryAuth: encodedAuth})
+	if err != nil {
+		pterm.Error.Println("Failed to push the image:", err)
+		return nil, err
+	}
+	defer pushResponse.Close()
+
+	return streamPush(ctx, pushResponse, ref)
+}
+
+func (b *baseRegistry) ListTags(ctx context.Context, repository string) ([]string, error) {
+	repo, err := name.NewRepository(fmt.Sprintf("%s/%s", b.host, repository), b.nameOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository '%s': %w", repository, err)
+	}
+	tags, err := remote.List(repo, b.remoteOptions(ctx)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags of %s: %w", repo, err)
+	}
+	return tags, nil
+}
+
+func (b *baseRegistry) Delete(ctx context.Context, repository, tag string) error {
+	ref := b.Reference(repository, tag)
+	if dryrun.Enabled() {
+		dryrun.Record("docker", "delete", ref, "remote tag")
+		return nil
+	}
+
+	tagRef, err := name.NewTag(ref, b.nameOptions()...)
+	if err != nil {
+		return fmt.Errorf("invalid reference '%s': %w", ref, err)
+	}
+	// Registries implementing OCI distribution 1.1 delete a single tag. Older ones
+	// only delete by digest, which also removes every other tag of that manifest.
+	if err := b.Untag(ctx, repository, tag); err != nil {
+		desc, headErr := remote.Head(tagRef, b.remoteOptions(ctx)...)
+		if headErr != nil {
+			return fmt.Errorf("failed to resolve %s: %w", ref, headErr)
+		}
+		pterm.Warning.Printf("%s does not delete tags, deleting manifest %s and all of its tags\n", b.host, desc.Digest)
+		if err := remote.Delete(tagRef.Context().Digest(desc.Digest.String()), b.remoteOptions(ctx)...); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", ref, err)
+		}
+	}
+	pterm.Success.Println("Deleted remote image:", ref)
+	return nil
+}
+
+// Untag deletes the tag through the registry API, which registries implementing
+// OCI distribution 1.1 support.
+func (b *baseRegistry) Untag(ctx context.Context, repository, tag string) error {
+	ref := b.Reference(repository, tag)
+	tagRef, err := name.NewTag(ref, b.nameOptions()...)
+	if err != nil {
+		return fmt.Errorf("invalid reference '%s': %w", ref, err)
+	}
+	if err := remote.Delete(tagRef, b.remoteOptions(ctx)...); err != nil {
+		return fmt.Errorf("failed to delete tag %s: %w", ref, err)
+	}
+	return nil
+}
+
+// remoteOptions authenticates registry API calls with the credentials used for pushing.
+func (b *baseRegistry) remoteOptions(ctx context.Context) []remote.Option {
+	return []remote.Option{remote.WithContext(ctx), remote.WithAuth(b.authenticator())}
+}
+
+// authenticator returns the credentials used for pushing, or anonymous access without any.
+func (b *baseRegistry) authenticator() authn.Authenticator {
+	if b.auth.Username == "" && b.auth.Password == "" && b.auth.IdentityToken == "" && b.auth.RegistryToken == "" {
+		return authn.Anonymous
+	}
+	return authn.FromConfig(authn.AuthConfig{
+		Username:      b.auth.Username,
+		Password:      b.auth.Password,
+		IdentityToken: b.auth.IdentityToken,
+		RegistryToken: b.auth.RegistryToken,
+	})
+}
+
+// nameOptions lets references to an insecure registry use plain HTTP; local
+// addresses such as localhost:5000 always do.
+func (b *baseRegistry) nameOptions() []name.Option {
+	if b.insecure {
+		return []name.Option{name.Insecure}
+	}
+	return nil
+}
+
+// streamPush reports the progress of a push from the daemon's JSON message
+// stream and captures the digest of the pushed manifest.
+func streamPush(ctx context.Context, body io.Reader, ref string) (*PushResult, error) {
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Pushing image %s...", ref))
+	result := &PushResult{Image: ref}
+
+	decoder := json.NewDecoder(body)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); err == io.EOF {
+			break
+		} else if err != nil {
+			if ctx.Err() != nil {
+				spinner.Warning("Push cancelled, layers already uploaded stay in the registry")
+				return nil, fmt.Errorf("push cancelled: %w", ctx.Err())
+			}
+			spinner.Fail("Failed to read push response")
+			return nil, fmt.Errorf("failed to read push response: %w", err)
+		}
+
+		if msg.Error != nil {
+			spinner.Fail("Failed to push the image: " + msg.Error.Message)
+			return nil, fmt.Errorf("%s", msg.Error.Message)
+		}
+
+		if digest := pushDigest(msg.Aux); digest != "" {
+			result.Digest = digest
+		}
+
+		if msg.Progress != nil && msg.Progress.Total > 0 {
+			percent := int(msg.Progress.Current * 100 / msg.Progress.Total)
+			spinner.UpdateText(fmt.Sprintf("Pushing image %s... layer %s %d%%", ref, msg.ID, percent))
+		} else if msg.Status != "" && msg.Status != "Waiting" {
+			spinner.UpdateText(fmt.Sprintf("Pushing image %s... %s %s", ref, msg.ID, msg.Status))
+		}
+	}
+
+	spinner.Success(fmt.Sprintf("Image push complete: %s", ref))
+	return newPushResult(ref, result.Digest), nil
+}
@@ -0,0 +1,76 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/containerregistry/armcontainerregistry"
+	"github.com/docker/docker/api/types/registry"
+)
+
+// ACRRegistry publishes images to an Azure Container Registry using its admin credentials.
+type ACRRegistry struct {
+	baseRegistry
+	subscriptionID string
+	resourceGroup  string
+	registryName   string
+}
+
+// NewACRRegistry returns the Azure Container Registry registryName in the given subscription and resource group.
+func NewACRRegistry(subscriptionID, resourceGroup, registryName string) *ACRRegistry {
+	return &ACRRegistry{subscriptionID: subscriptionID, resourceGroup: resourceGroup, registryName: registryName}
+}
+
+func (r *ACRRegistry) Name() string {
+	return "ACR"
+}
+
+func (r *ACRRegistry) Authenticate(ctx context.Context) error {
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		r.host = r.registryName + ".azurecr.io"
+		return r.dockerConfigAuth(fmt.Errorf("failed to authenticate with Azure: %w", err))
+	}
+
+	registryClient, err := armcontainerregistry.NewRegistriesClient(r.subscriptionID, cred, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create registry client: %w", err)
+	}
+
+	registryResp, err := registryClient.Get(ctx, r.resourceGroup, r.registryName, nil)
+	if err != nil {
+		r.host = r.registryName + ".azurecr.io"
+		return r.dockerConfigAuth(fmt.Errorf("failed to retrieve registry details: %w", err))
+	}
+	if registryResp.Properties == nil || registryResp.Properties.LoginServer == nil {
+		return fmt.Errorf("registry %s has no login server", r.registryName)
+	}
+	r.host = *registryResp.Properties.LoginServer
+
+	credentialsResp, err := registryClient.ListCredentials(ctx, r.resourceGroup, r.registryName, nil)
+	if err != nil {
+		return r.dockerConfigAuth(fmt.Errorf("failed to retrieve registry credentials: %w", err))
+	}
+	if credentialsResp.Username == nil || len(credentialsResp.Passwords) == 0 || credentialsResp.Passwords[0].Value == nil {
+		return r.dockerConfigAuth(fmt.Errorf("registry credentials are not available, enable the admin user of %s", r.registryName))
+	}
+
+	r.auth = registry.AuthConfig{
+		Username:      *credentialsResp.Username,
+		Password:      *credentialsResp.Passwords[0].Value,
+		ServerAddress: r.host,
+	}
+	return nil
+}
+
+// dockerConfigAuth falls back to credentials from the Docker config, such as
+// those of 'az acr login', when the admin credentials cannot be read, returning
+// cause if there are none.
+func (r *ACRRegistry) dockerConfigAuth(cause error) error {
+	r.auth = resolveCredentials(r.host, registry.AuthConfig{}, credentialEnv{})
+	if r.auth.Password == "" && r.auth.IdentityToken == "" {
+		return cause
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package docker
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/clouddrove/smurf/internal/dryrun"
+	"github.com/docker/cli/cli/config"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/pterm/pterm"
+)
+
+// ECRRegistry publishes images to AWS Elastic Container Registry.
+type ECRRegistry struct {
+	baseRegistry
+	region string
+	// registryID is the account of a registry other than the default one.
+	registryID string
+	client     *ecr.ECR
+	// dockerConfig is set when the credentials came from the Docker config
+	// because the AWS SDK had none, which leaves the ECR API unusable.
+	dockerConfig bool
+	// callerAccount is the account of the AWS credentials when registryID is
+	// the registry of another account, in which they cannot create repositories.
+	callerAccount string
+}
+
+// NewECRRegistry returns the ECR registry of the default account in region.
+func NewECRRegistry(region string) *ECRRegistry {
+	return &ECRRegistry{region: region}
+}
+
+func (r *ECRRegistry) Name() string {
+	return "ECR"
+}
+
+func (r *ECRRegistry) Authenticate(ctx context.Context) error {
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(r.region),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create AWS session: %w", err)
+	}
+	r.client = ecr.New(sess)
+
+	authTokenOutput, err := r.client.GetAuthorizationTokenWithContext(ctx, &ecr.GetAuthorizationTokenInput{})
+	if err != nil {
+		return r.dockerConfigAuth(fmt.Errorf("failed to get ECR authorization token: %w", err))
+	}
+	if len(authTokenOutput.AuthorizationData) == 0 {
+		return fmt.Errorf("no authorization data received from ECR")
+	}
+
+	authData := authTokenOutput.AuthorizationData[0]
+	authToken, err := base64.StdEncoding.DecodeString(aws.StringValue(authData.AuthorizationToken))
+	if err != nil {
+		return fmt.Errorf("failed to decode authorization token: %w", err)
+	}
+	credentials := strings.SplitN(string(authToken), ":", 2)
+	if len(credentials) != 2 {
+		return fmt.Errorf("invalid authorization token format")
+	}
+
+	r.host = strings.TrimPrefix(aws.StringValue(authData.ProxyEndpoint), "https://")
+	r.auth = registry.AuthConfig{
+		Username:      credentials[0],
+		Password:      credentials[1],
+		ServerAddress: aws.StringValue(authData.ProxyEndpoint),
+	}
+	// The token of the caller's registry also signs in to the registries of
+	// other accounts whose repository policies grant it access
+	if match := ecrHost.FindStringSubmatch(r.host); match != nil && r.registryID != "" && match[1] != r.registryID {
+		r.callerAccount = match[1]
+		r.host = r.registryIDHost()
+		r.auth.ServerAddress = "https://" + r.host
+	}
+	return nil
+}
+
+// registryIDHost returns the host of the registry of registryID.
+func (r *ECRRegistry) registryIDHost() string {
+	return fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com", r.registryID, r.region)
+}
+
+// dockerConfigAuth falls back to credentials from the Docker config, such as
+// those of docker-credential-ecr-login or 'aws ecr get-login-password', when the
+// AWS SDK has no credentials, returning cause if there are none. The registry
+// host is the one of registryID, or else the only ECR host of the region the
+// Docker config has credentials or a helper for.
+func (r *ECRRegistry) dockerConfigAuth(cause error) error {
+	host := r.configuredHost()
+	if host == "" {
+		return cause
+	}
+	r.host = host
+	r.auth = resolveCredentials(host, registry.AuthConfig{}, credentialEnv{})
+	if r.auth.Password == "" && r.auth.IdentityToken == "" {
+		return cause
+	}
+	r.dockerConfig = true
+	return nil
+}
+
+// configuredHost returns the ECR host the Docker config fallback authenticates
+// against, or an empty string when it cannot tell which one it is.
+func (r *ECRRegistry) configuredHost() string {
+	if r.registryID != "" {
+		return r.registryIDHost()
+	}
+	cfg, err := config.Load(config.Dir())
+	if err != nil {
+		return ""
+	}
+	hosts := map[string]bool{}
+	keys := make([]string, 0, len(cfg.AuthConfigs)+len(cfg.CredentialHelpers))
+	for key := range cfg.AuthConfigs {
+		keys = append(keys, key)
+	}
+	for key := range cfg.CredentialHelpers {
+		keys = append(keys, key)
+	}
+	for _, key := range keys {
+		host := credentialServerAddress(key)
+		if match := ecrHost.FindStringSubmatch(host); match != nil && match[2] == r.region {
+			hosts[host] = true
+		}
+	}
+	if len(hosts) != 1 {
+		return ""
+	}
+	for host := range hosts {
+		return host
+	}
+	return ""
+}
+
+// EnsureRepository creates the ECR repository, which unlike most registries must exist before a push.
+func (r *ECRRegistry) EnsureRepository(ctx context.Context, repository string) error {
+	if r.dockerConfig {
+		pterm.Warning.Printf("No AWS credentials to check repository %s with, it must already exist\n", repository)
+		return nil
+	}
+	if r.callerAccount != "" {
+		pterm.Warning.Printf("Repository %s is in account %s, where account %s cannot create repositories; it must already exist\n", repository, r.registryID, r.callerAccount)
+		return nil
+	}
+	var registryID *string
+	if r.registryID != "" {
+		registryID = aws.String(r.registryID)
+	}
+	_, err := r.client.DescribeRepositoriesWithContext(ctx, &ecr.DescribeRepositoriesInput{
+		RegistryId:      registryID,
+		RepositoryNames: []*string{aws.String(repository)},
+	})
+	if err == nil {
+		return nil
+	}
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != ecr.ErrCodeRepositoryNotFoundException {
+		return fmt.Errorf("failed to describe ECR repositories: %w", err)
+	}
+
+	if dryrun.Enabled() {
+		dryrun.Record("docker", "create ECR repository", repository, r.region)
+		return nil
+	}
+	if _, err := r.client.CreateRepositoryWithContext(ctx, &ecr.CreateRepositoryInput{
+		RegistryId:     registryID,
+		RepositoryName: aws.String(repository),
+	}); err != nil {
+		return fmt.Errorf("failed to create ECR repository: %w", err)
+	}
+	pterm.Info.Println("Created ECR repository:", repository)
+	return nil
+}
+
+// Delete removes a tag through the ECR API, which does not support deletes over the registry API.
+func (r *ECRRegistry) Delete(ctx context.Context, repository, tag string) error {
+	ref := r.Reference(repository, tag)
+	if dryrun.Enabled() {
+		dryrun.Record("docker", "delete", ref, "remote tag")
+		return nil
+	}
+
+	if err := r.Untag(ctx, repository, tag); err != nil {
+		return err
+	}
+	pterm.Success.Println("Deleted remote image:", ref)
+	return nil
+}
+
+// Untag removes a tag through the ECR API, which deletes an image along with
+// its last tag.
+func (r *ECRRegistry) Untag(ctx context.Context, repository, tag string) error {
+	ref := r.Reference(repository, tag)
+	input := &ecr.BatchDeleteImageInput{
+		RepositoryName: aws.String(repository),
+		ImageIds:       []*ecr.ImageIdentifier{{ImageTag: aws.String(tag)}},
+	}
+	if r.registryID != "" {
+		input.RegistryId = aws.String(r.registryID)
+	}
+	output, err := r.client.BatchDeleteImageWithContext(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", ref, err)
+	}
+	if len(output.Failures) > 0 {
+		return fmt.Errorf("failed to delete %s: %s", ref, aws.StringValue(output.Failures[0].FailureReason))
+	}
+	return nil
+}
+
+// ListImages lists the images of an ECR repository through the ECR API, which
+// records when each image was pushed and includes untagged images.
+func (r *ECRRegistry) ListImages(ctx context.Context, repository string) ([]RemoteImage, error) {
+	input := &ecr.DescribeImagesInput{RepositoryName: aws.String(repository)}
+	if r.registryID != "" {
+		input.RegistryId = aws.String(r.registryID)
+	}
+	var images []RemoteImage
+	err := r.client.DescribeImagesPagesWithContext(ctx, input, func(page *ecr.DescribeImagesOutput, last bool) bool {
+		for _, detail := range page.ImageDetails {
+			img := RemoteImage{
+				Digest:    aws.StringValue(detail.ImageDigest),
+				Tags:      aws.StringValueSlice(detail.ImageTags),
+				MediaType: aws.StringValue(detail.ImageManifestMediaType),
+				Pushed:    aws.TimeValue(detail.ImagePushedAt),
+			}
+			sort.Strings(img.Tags)
+			images = append(images, img)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images of %s: %w", repository, err)
+	}
+	return images, nil
+}
+
+// DeleteDigest removes an untagged image through the ECR API.
+func (r *ECRRegistry) DeleteDigest(ctx context.Context, repository, digest string) error {
+	ref := fmt.Sprintf("%s/%s@%s", r.host, repository, digest)
+	if dryrun.Enabled() {
+		dryrun.Record("docker", "delete", ref, "untagged manifest")
+		return nil
+	}
+
+	input := &ecr.BatchDeleteImageInput{
+		RepositoryName: aws.String(repository),
+		ImageIds:       []*ecr.ImageIdentifier{{ImageDigest: aws.String(digest)}},
+	}
+	if r.registryID != "" {
+		input.RegistryId = aws.String(r.registryID)
+	}
+	output, err := r.client.BatchDeleteImageWithContext(ctx, input)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", ref, err)
+	}
+	if len(output.Failures) > 0 {
+		return fmt.Errorf("failed to delete %s: %s", ref, aws.StringValue(output.Failures[0].FailureReason))
+	}
+	pterm.Success.Println("Deleted remote manifest:", ref)
+	return nil
+}
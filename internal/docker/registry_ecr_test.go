@@ -0,0 +1,84 @@
+package docker
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/registry"
+)
+
+// withoutAWSCredentials leaves the AWS SDK without any credentials to find.
+func withoutAWSCredentials(t *testing.T) {
+	t.Helper()
+	missing := filepath.Join(t.TempDir(), "missing")
+	for _, env := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE", "AWS_ROLE_ARN", "AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "AWS_CONTAINER_CREDENTIALS_FULL_URI"} {
+		t.Setenv(env, "")
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", missing)
+	t.Setenv("AWS_CONFIG_FILE", missing)
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+}
+
+func TestECRRegistryDockerConfigFallback(t *testing.T) {
+	const host = "123456789012.dkr.ecr.eu-west-1.amazonaws.com"
+	stored := base64.StdEncoding.EncodeToString([]byte("AWS:token"))
+
+	tests := []struct {
+		name       string
+		registryID string
+		config     string
+		want       registry.AuthConfig
+		wantErr    bool
+	}{
+		{
+			name:   "stored login",
+			config: fmt.Sprintf(`{"auths":{"https://%s":{"auth":%q},"210987654321.dkr.ecr.us-east-1.amazonaws.com":{"auth":%[2]q}}}`, host, stored),
+			want:   registry.AuthConfig{Username: "AWS", Password: "token", ServerAddress: host},
+		},
+		{
+			name:       "registry ID",
+			registryID: "123456789012",
+			config:     fmt.Sprintf(`{"auths":{%q:{"auth":%q}}}`, host, stored),
+			want:       registry.AuthConfig{Username: "AWS", Password: "token", ServerAddress: host},
+		},
+		{
+			name:    "several registries in the region",
+			config:  fmt.Sprintf(`{"auths":{%q:{"auth":%q},"210987654321.dkr.ecr.eu-west-1.amazonaws.com":{"auth":%[2]q}}}`, host, stored),
+			wantErr: true,
+		},
+		{
+			name:    "no credentials",
+			config:  `{}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearCredentials(t)
+			withoutAWSCredentials(t)
+			writeDockerConfig(t, tt.config)
+
+			reg := NewECRRegistry("eu-west-1")
+			reg.registryID = tt.registryID
+			err := reg.Authenticate(context.Background())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("authenticated as %+v", reg.auth)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reg.host != host || reg.auth != tt.want {
+				t.Errorf("host %s, auth %+v, want %s and %+v", reg.host, reg.auth, host, tt.want)
+			}
+			if err := reg.EnsureRepository(context.Background(), "myapp"); err != nil {
+				t.Errorf("EnsureRepository without AWS credentials: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/docker/docker/api/types/registry"
+	"github.com/google/go-containerregistry/pkg/name"
+	gcrgoogle "github.com/google/go-containerregistry/pkg/v1/google"
+	"golang.org/x/oauth2/google"
+)
+
+// GCRRegistry publishes images to Google Container Registry using application default credentials.
+type GCRRegistry struct {
+	baseRegistry
+	projectID string
+}
+
+// NewGCRRegistry returns the GCR registry of projectID.
+func NewGCRRegistry(projectID string) *GCRRegistry {
+	return &GCRRegistry{projectID: projectID}
+}
+
+func (r *GCRRegistry) Name() string {
+	return "GCR"
+}
+
+func (r *GCRRegistry) Authenticate(ctx context.Context) error {
+	r.host = "gcr.io"
+	creds, err := google.FindDefaultCredentials(ctx, "https://www.googleapis.com/auth/cloud-platform")
+	if err != nil {
+		return r.dockerConfigAuth(fmt.Errorf("failed to find Google Cloud credentials: %w", err))
+	}
+	token, err := creds.TokenSource.Token()
+	if err != nil {
+		return r.dockerConfigAuth(fmt.Errorf("failed to obtain access token: %w", err))
+	}
+
+	r.auth = registry.AuthConfig{
+		Username:      "oauth2accesstoken",
+		Password:      token.AccessToken,
+		ServerAddress: "https://gcr.io",
+	}
+	return nil
+}
+
+// dockerConfigAuth falls back to credentials from the Docker config, such as
+// those of 'gcloud auth configure-docker', when application default credentials
+// are not available, returning cause if there are none.
+func (r *GCRRegistry) dockerConfigAuth(cause error) error {
+	r.auth = resolveCredentials(r.host, registry.AuthConfig{}, credentialEnv{})
+	if r.auth.Password == "" && r.auth.IdentityToken == "" {
+		return cause
+	}
+	return nil
+}
+
+// Reference places repositories under the project, as gcr.io/PROJECT/REPOSITORY:TAG.
+func (r *GCRRegistry) Reference(repository, tag string) string {
+	return fmt.Sprintf("%s/%s/%s:%s", r.host, r.projectID, repository, tag)
+}
+
+func (r *GCRRegistry) ListTags(ctx context.Context, repository string) ([]string, error) {
+	return r.baseRegistry.ListTags(ctx, r.projectID+"/"+repository)
+}
+
+func (r *GCRRegistry) Delete(ctx context.Context, repository, tag string) error {
+	return r.baseRegistry.Delete(ctx, r.projectID+"/"+repository, tag)
+}
+
+func (r *GCRRegistry) Untag(ctx context.Context, repository, tag string) error {
+	return r.baseRegistry.Untag(ctx, r.projectID+"/"+repository, tag)
+}
+
+func (r *GCRRegistry) DeleteDigest(ctx context.Context, repository, digest string) error {
+	return r.baseRegistry.DeleteDigest(ctx, r.projectID+"/"+repository, digest)
+}
+
+// ListImages lists the images of a repository with the GCR extension of the tag
+// list, which records when each manifest was uploaded and includes untagged ones.
+func (r *GCRRegistry) ListImages(ctx context.Context, repository string) ([]RemoteImage, error) {
+	repo, err := name.NewRepository(fmt.Sprintf("%s/%s/%s", r.host, r.projectID, repository))
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository '%s': %w", repository, err)
+	}
+	list, err := gcrgoogle.List(repo, gcrgoogle.WithAuth(r.authenticator()), gcrgoogle.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images of %s: %w", repo, err)
+	}
+
+	images := make([]RemoteImage, 0, len(list.Manifests))
+	for digest, info := range list.Manifests {
+		img := RemoteImage{Digest: digest, Tags: info.Tags, MediaType: info.MediaType, Pushed: info.Uploaded}
+		sort.Strings(img.Tags)
+		images = append(images, img)
+	}
+	return images, nil
+}
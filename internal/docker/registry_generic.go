@@ -0,0 +1,48 @@
+package docker
+
+import (
+	"context"
+	"strings"
+
+	"github.com/docker/docker/api/types/registry"
+)
+
+// GenericRegistry publishes images to any registry implementing the OCI
+// distribution API, such as GHCR, Harbor, Quay or a self-hosted registry:2.
+// It authenticates with basic auth, with a bearer token, or anonymously.
+type GenericRegistry struct {
+	baseRegistry
+	username string
+	password string
+	token    string
+}
+
+// NewGenericRegistry returns the registry at url. Empty credentials fall back to
+// REGISTRY_USERNAME, REGISTRY_PASSWORD and REGISTRY_TOKEN, then to the Docker
+// config and its credential helpers.
+func NewGenericRegistry(url, username, password, token string) *GenericRegistry {
+	host := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(url, "https://"), "http://"), "/")
+	return &GenericRegistry{
+		baseRegistry: baseRegistry{host: host, insecure: strings.HasPrefix(url, "http://")},
+		username:     username,
+		password:     password,
+		token:        token,
+	}
+}
+
+func (r *GenericRegistry) Name() string {
+	return r.host
+}
+
+func (r *GenericRegistry) Authenticate(ctx context.Context) error {
+	r.auth = resolveCredentials(r.host, registry.AuthConfig{
+		Username:      r.username,
+		Password:      r.password,
+		RegistryToken: r.token,
+	}, credentialEnv{
+		Username: "REGISTRY_USERNAME",
+		Password: "REGISTRY_PASSWORD",
+		Token:    "REGISTRY_TOKEN",
+	})
+	return nil
+}
@@ -0,0 +1,476 @@
+package docker
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/docker/docker/api/types/registry"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	ggcrregistry "github.com/google/go-containerregistry/pkg/registry"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// dockerConfigDir is the Docker config directory of the tests. The Docker CLI
+// reads DOCKER_CONFIG only once, so it is set for the whole package.
+var dockerConfigDir string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "smurf-docker-config")
+	if err != nil {
+		panic(err)
+	}
+	dockerConfigDir = dir
+	os.Setenv("DOCKER_CONFIG", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// testRegistry is an in-memory stand-in for a registry:2 that accepts basic
+// auth with username and password, or a bearer token. Without a username it
+// accepts anonymous requests.
+type testRegistry struct {
+	host     string
+	username string
+	password string
+	token    string
+	// uploads counts the blob uploads started.
+	uploads atomic.Int32
+	// digestDeletesOnly makes the registry refuse to delete tags, like
+	// registries older than OCI distribution 1.1.
+	digestDeletesOnly bool
+}
+
+func newTestRegistry(t *testing.T, username, password, token string) *testRegistry {
+	t.Helper()
+	reg := &testRegistry{username: username, password: password, token: token}
+	handler := ggcrregistry.New()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reg.username != "" && !reg.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/blobs/uploads/") {
+			reg.uploads.Add(1)
+		}
+		if reg.digestDeletesOnly && r.Method == http.MethodDelete && strings.Contains(r.URL.Path, "/manifests/") {
+			deleteByDigest(w, r, handler)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	reg.host = strings.TrimPrefix(server.URL, "http://")
+	return reg
+}
+
+// deleteByDigest deletes a manifest along with every tag pointing to it, and
+// refuses to delete a tag on its own.
+func deleteByDigest(w http.ResponseWriter, r *http.Request, handler http.Handler) {
+	repo, ref, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v2/"), "/manifests/")
+	if !strings.HasPrefix(ref, "sha256:") {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+	var list struct {
+		Tags []string `json:"tags"`
+	}
+	json.Unmarshal(serve(http.MethodGet, "/v2/"+repo+"/tags/list").Body.Bytes(), &list)
+	for _, tag := range list.Tags {
+		if serve(http.MethodHead, "/v2/"+repo+"/manifests/"+tag).Header().Get("Docker-Content-Digest") == ref {
+			serve(http.MethodDelete, "/v2/"+repo+"/manifests/"+tag)
+		}
+	}
+	handler.ServeHTTP(w, r)
+}
+
+func (reg *testRegistry) authorized(r *http.Request) bool {
+	if user, pass, ok := r.BasicAuth(); ok {
+		return user == reg.username && pass == reg.password
+	}
+	return reg.token != "" && r.Header.Get("Authorization") == "Bearer "+reg.token
+}
+
+// auth returns the options to reach the registry as its own user.
+func (reg *testRegistry) auth() remote.Option {
+	if reg.username == "" {
+		return remote.WithAuth(authn.Anonymous)
+	}
+	return remote.WithAuth(&authn.Basic{Username: reg.username, Password: reg.password})
+}
+
+// write pushes a random image to the registry as ref and returns its digest.
+func (reg *testRegistry) write(t *testing.T, ref string) v1.Hash {
+	t.Helper()
+	img, err := random.Image(256, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := name.NewTag(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := remote.Write(tag, img, reg.auth()); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return digest
+}
+
+// head returns the digest ref points to in the registry.
+func (reg *testRegistry) head(t *testing.T, ref string) v1.Hash {
+	t.Helper()
+	parsed, err := name.ParseReference(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := remote.Head(parsed, reg.auth())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return desc.Digest
+}
+
+// clearCredentials leaves the test without credentials from the environment or
+// the Docker config.
+func clearCredentials(t *testing.T) {
+	t.Helper()
+	for _, env := range []string{"REGISTRY_USERNAME", "REGISTRY_PASSWORD", "REGISTRY_TOKEN"} {
+		t.Setenv(env, "")
+	}
+	writeDockerConfig(t, `{}`)
+}
+
+func writeDockerConfig(t *testing.T, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dockerConfigDir, "config.json"), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGenericRegistryAuthenticate(t *testing.T) {
+	const host = "registry.example.com"
+	stored := base64.StdEncoding.EncodeToString([]byte("configuser:configpass"))
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		token    string
+		env      map[string]string
+		config   string
+		want     registry.AuthConfig
+	}{
+		{
+			name:     "flags",
+			username: "flaguser",
+			password: "flagpass",
+			env:      map[string]string{"REGISTRY_USERNAME": "envuser", "REGISTRY_PASSWORD": "envpass"},
+			config:   fmt.Sprintf(`{"auths":{%q:{"auth":%q}}}`, host, stored),
+			want:     registry.AuthConfig{Username: "flaguser", Password: "flagpass", ServerAddress: host},
+		},
+		{
+			name:   "environment",
+			env:    map[string]string{"REGISTRY_USERNAME": "envuser", "REGISTRY_PASSWORD": "envpass"},
+			config: fmt.Sprintf(`{"auths":{%q:{"auth":%q}}}`, host, stored),
+			want:   registry.AuthConfig{Username: "envuser", Password: "envpass", ServerAddress: host},
+		},
+		{
+			name: "environment token",
+			env:  map[string]string{"REGISTRY_TOKEN": "envtoken"},
+			want: registry.AuthConfig{RegistryToken: "envtoken", ServerAddress: host},
+		},
+		{
+			name:   "docker config",
+			config: fmt.Sprintf(`{"auths":{"https://%s":{"auth":%q}}}`, host, stored),
+			want:   registry.AuthConfig{Username: "configuser", Password: "configpass", ServerAddress: host},
+		},
+		{
+			name:   "anonymous",
+			config: fmt.Sprintf(`{"auths":{"other.example.com":{"auth":%q}}}`, stored),
+			want:   registry.AuthConfig{ServerAddress: host},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearCredentials(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			if tt.config != "" {
+				writeDockerConfig(t, tt.config)
+			}
+
+			reg := NewGenericRegistry("https://"+host+"/", tt.username, tt.password, tt.token)
+			if err := reg.Authenticate(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if reg.auth != tt.want {
+				t.Errorf("auth = %+v, want %+v", reg.auth, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewGenericRegistryInsecure(t *testing.T) {
+	if reg := NewGenericRegistry("http://registry.local:5000/", "", "", ""); reg.host != "registry.local:5000" || !reg.insecure {
+		t.Errorf("http URL: host %q, insecure %v", reg.host, reg.insecure)
+	}
+	if reg := NewGenericRegistry("https://registry.local", "", "", ""); reg.host != "registry.local" || reg.insecure {
+		t.Errorf("https URL: host %q, insecure %v", reg.host, reg.insecure)
+	}
+}
+
+// fakeDaemon serves the parts of the Docker Engine API a push uses. A push
+// writes a random image to the target registry with the credentials the
+// client sent, and streams the progress and digest the way the daemon does.
+type fakeDaemon struct {
+	tagged   []string
+	pushAuth registry.AuthConfig
+}
+
+func newFakeDaemon(t *testing.T) *fakeDaemon {
+	t.Helper()
+	daemon := &fakeDaemon{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if strings.HasPrefix(path, "/v1.") {
+			path = path[strings.Index(path[1:], "/")+1:]
+		}
+		w.Header().Set("Api-Version", "1.45")
+		switch {
+		case path == "/_ping":
+			fmt.Fprint(w, "OK")
+		case r.Method == http.MethodPost && strings.HasSuffix(path, "/tag"):
+			daemon.tagged = append(daemon.tagged, r.URL.Query().Get("repo")+":"+r.URL.Query().Get("tag"))
+			w.WriteHeader(http.StatusCreated)
+		case r.Method == http.MethodPost && strings.HasSuffix(path, "/push"):
+			daemon.push(t, w, r, strings.TrimSuffix(strings.TrimPrefix(path, "/images/"), "/push"))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("DOCKER_HOST", "tcp://"+strings.TrimPrefix(server.URL, "http://"))
+	return daemon
+}
+
+func (d *fakeDaemon) push(t *testing.T, w http.ResponseWriter, r *http.Request, repository string) {
+	encoded := r.Header.Get("X-Registry-Auth")
+	data, err := base64.URLEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Errorf("invalid X-Registry-Auth %q: %v", encoded, err)
+	}
+	json.Unmarshal(data, &d.pushAuth)
+
+	ref := repository + ":" + r.URL.Query().Get("tag")
+	img, _ := random.Image(256, 1)
+	tag, _ := name.NewTag(ref)
+	opts := []remote.Option{remote.WithAuth(authn.FromConfig(authn.AuthConfig{
+		Username: d.pushAuth.Username,
+		Password: d.pushAuth.Password,
+	}))}
+	encoder := json.NewEncoder(w)
+	if err := remote.Write(tag, img, opts...); err != nil {
+		encoder.Encode(map[string]any{"errorDetail": map[string]string{"message": err.Error()}, "error": err.Error()})
+		return
+	}
+	digest, _ := img.Digest()
+	encoder.Encode(map[string]any{"status": "Pushed", "id": "layer"})
+	encoder.Encode(map[string]any{"status": fmt.Sprintf("%s: digest: %s size: 428", r.URL.Query().Get("tag"), digest)})
+	encoder.Encode(map[string]any{"progressDetail": map[string]any{}, "aux": map[string]any{"Tag": r.URL.Query().Get("tag"), "Digest": digest.String(), "Size": 428}})
+}
+
+func TestGenericRegistryPush(t *testing.T) {
+	clearCredentials(t)
+	reg := newTestRegistry(t, "pusher", "s3cret", "")
+	daemon := newFakeDaemon(t)
+	t.Setenv("REGISTRY_USERNAME", "pusher")
+	t.Setenv("REGISTRY_PASSWORD", "s3cret")
+
+	generic := NewGenericRegistry("http://"+reg.host, "", "", "")
+	result, err := PushTo(context.Background(), generic, "myapp:v1", "team/myapp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := reg.host + "/team/myapp:v1"
+	if len(daemon.tagged) != 1 || daemon.tagged[0] != target {
+		t.Errorf("tagged %v, want [%s]", daemon.tagged, target)
+	}
+	if daemon.pushAuth.Username != "pusher" || daemon.pushAuth.Password != "s3cret" {
+		t.Errorf("push sent credentials %+v", daemon.pushAuth)
+	}
+	digest := reg.head(t, target)
+	want := PushResult{Image: target, Digest: digest.String(), Reference: reg.host + "/team/myapp@" + digest.String()}
+	if *result != want {
+		t.Errorf("result = %+v, want %+v", *result, want)
+	}
+}
+
+func TestGenericRegistryPushUnauthorized(t *testing.T) {
+	clearCredentials(t)
+	reg := newTestRegistry(t, "pusher", "s3cret", "")
+	newFakeDaemon(t)
+
+	generic := NewGenericRegistry("http://"+reg.host, "pusher", "wrong", "")
+	if _, err := PushTo(context.Background(), generic, "myapp:v1", "team/myapp"); err == nil {
+		t.Fatal("push with a wrong password succeeded")
+	}
+}
+
+func TestGenericRegistryPushIndex(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		token    string
+	}{
+		{name: "basic auth", username: "pusher", password: "s3cret"},
+		{name: "bearer token", username: "pusher", password: "s3cret", token: "t0ken"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearCredentials(t)
+			reg := newTestRegistry(t, tt.username, tt.password, tt.token)
+			ref := reg.host + "/team/myapp:v1"
+			images := []PlatformImage{
+				{Platform: "linux/amd64", Ref: PlatformReference(ref, "linux/amd64")},
+				{Platform: "linux/arm64", Ref: PlatformReference(ref, "linux/arm64")},
+			}
+			for _, img := range images {
+				reg.write(t, img.Ref)
+			}
+
+			generic := NewGenericRegistry(reg.host, tt.username, tt.password, "")
+			if tt.token != "" {
+				generic = NewGenericRegistry(reg.host, "", "", tt.token)
+			}
+			if err := generic.Authenticate(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			result, err := generic.PushIndex(context.Background(), ref, images)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if digest := reg.head(t, ref); result.Digest != digest.String() {
+				t.Errorf("digest = %s, registry has %s", result.Digest, digest)
+			}
+
+			index, err := remote.Index(mustReference(t, ref), reg.auth())
+			if err != nil {
+				t.Fatal(err)
+			}
+			manifest, err := index.IndexManifest()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var platforms []string
+			for _, m := range manifest.Manifests {
+				platforms = append(platforms, m.Platform.String())
+			}
+			if strings.Join(platforms, ",") != "linux/amd64,linux/arm64" {
+				t.Errorf("platforms = %v", platforms)
+			}
+		})
+	}
+}
+
+func TestPushPlatformsRemovesTemporaryTags(t *testing.T) {
+	clearCredentials(t)
+	reg := newTestRegistry(t, "pusher", "s3cret", "")
+	daemon := newFakeDaemon(t)
+
+	generic := NewGenericRegistry("http://"+reg.host, "pusher", "s3cret", "")
+	result, err := PushPlatforms(context.Background(), generic, "myapp:v1", "team/myapp", []string{"linux/amd64", "linux/arm64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(daemon.tagged) != 2 {
+		t.Errorf("tagged %v, want one tag per platform", daemon.tagged)
+	}
+
+	tags, err := generic.ListTags(context.Background(), "team/myapp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0] != "v1" {
+		t.Errorf("tags after the push = %v, want [v1]", tags)
+	}
+
+	target := reg.host + "/team/myapp:v1"
+	if digest := reg.head(t, target); result.Digest != digest.String() {
+		t.Errorf("digest = %s, registry has %s", result.Digest, digest)
+	}
+	index, err := remote.Index(mustReference(t, target), reg.auth())
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := index.IndexManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Manifests) != 2 {
+		t.Fatalf("index lists %d images, want 2", len(manifest.Manifests))
+	}
+	for _, m := range manifest.Manifests {
+		reg.head(t, reg.host+"/team/myapp@"+m.Digest.String())
+	}
+}
+
+func TestGenericRegistryListTagsUnauthorized(t *testing.T) {
+	clearCredentials(t)
+	reg := newTestRegistry(t, "pusher", "s3cret", "")
+	reg.write(t, reg.host+"/team/myapp:v1")
+
+	generic := NewGenericRegistry(reg.host, "", "", "")
+	if err := generic.Authenticate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := generic.ListTags(context.Background(), "team/myapp"); err == nil {
+		t.Fatal("anonymous tag listing succeeded")
+	}
+	t.Setenv("REGISTRY_USERNAME", "pusher")
+	t.Setenv("REGISTRY_PASSWORD", "s3cret")
+	if err := generic.Authenticate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	tags, err := generic.ListTags(context.Background(), "team/myapp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0] != "v1" {
+		t.Errorf("tags = %v", tags)
+	}
+}
+
+func mustReference(t *testing.T, ref string) name.Reference {
+	t.Helper()
+	parsed, err := name.ParseReference(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
@@ -0,0 +1,206 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/dryrun"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/pterm/pterm"
+)
+
+// hubAPI is the Docker Hub API, used for the operations the registry API does not offer.
+const hubAPI = "https://hub.docker.com/v2"
+
+// HubRegistry publishes images to Docker Hub.
+type HubRegistry struct {
+	baseRegistry
+	username string
+	password string
+}
+
+// NewHubRegistry returns Docker Hub, authenticated with the given credentials or,
+// when they are empty, DOCKER_USERNAME and DOCKER_PASSWORD, then the Docker
+// config and its credential helpers.
+func NewHubRegistry(username, password string) *HubRegistry {
+	return &HubRegistry{username: username, password: password}
+}
+
+func (r *HubRegistry) Name() string {
+	return "Docker Hub"
+}
+
+func (r *HubRegistry) Authenticate(ctx context.Context) error {
+	r.host = "docker.io"
+	r.auth = resolveCredentials(r.host, registry.AuthConfig{
+		Username: r.username,
+		Password: r.password,
+	}, credentialEnv{
+		Username: "DOCKER_USERNAME",
+		Password: "DOCKER_PASSWORD",
+	})
+	r.username, r.password = r.auth.Username, r.auth.Password
+	return nil
+}
+
+// Reference leaves Docker Hub references in their short form, as REPOSITORY:TAG.
+func (r *HubRegistry) Reference(repository, tag string) string {
+	return fmt.Sprintf("%s:%s", repository, tag)
+}
+
+// Delete removes a tag through the Docker Hub API, which does not support deletes over the registry API.
+func (r *HubRegistry) Delete(ctx context.Context, repository, tag string) error {
+	ref := r.Reference(repository, tag)
+	if dryrun.Enabled() {
+		dryrun.Record("docker", "delete", ref, "remote tag")
+		return nil
+	}
+
+	if err := r.Untag(ctx, repository, tag); err != nil {
+		return err
+	}
+	pterm.Success.Println("Deleted remote image:", ref)
+	return nil
+}
+
+// Untag removes a tag through the Docker Hub API, which only deletes the tag.
+func (r *HubRegistry) Untag(ctx context.Context, repository, tag string) error {
+	ref := r.Reference(repository, tag)
+	token, err := r.hubToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, fmt.Sprintf("%s/repositories/%s/tags/%s/", hubAPI, repository, tag), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "JWT "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", ref, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to delete %s: Docker Hub returned %s", ref, resp.Status)
+	}
+	return nil
+}
+
+// ListImages lists the tags of a repository through the Docker Hub API, which
+// records when each tag was last pushed. Docker Hub does not list untagged images.
+func (r *HubRegistry) ListImages(ctx context.Context, repository string) ([]RemoteImage, error) {
+	token := ""
+	if r.username != "" && r.password != "" {
+		var err error
+		if token, err = r.hubToken(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	byDigest := map[string]*RemoteImage{}
+	var order []string
+	next := fmt.Sprintf("%s/repositories/%s/tags?page_size=100", hubAPI, repository)
+	for next != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, next, nil)
+		if err != nil {
+			return nil, err
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "JWT "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tags of %s: %w", repository, err)
+		}
+		var page struct {
+			Next    string `json:"next"`
+			Results []struct {
+				Name          string    `json:"name"`
+				Digest        string    `json:"digest"`
+				MediaType     string    `json:"media_type"`
+				LastUpdated   time.Time `json:"last_updated"`
+				TagLastPushed time.Time `json:"tag_last_pushed"`
+			} `json:"results"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to list tags of %s: Docker Hub returned %s", repository, resp.Status)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode Docker Hub tags of %s: %w", repository, err)
+		}
+
+		for _, tag := range page.Results {
+			pushed := tag.TagLastPushed
+			if pushed.IsZero() {
+				pushed = tag.LastUpdated
+			}
+			key := tag.Digest
+			if key == "" {
+				key = "tag:" + tag.Name
+			}
+			if img, ok := byDigest[key]; ok {
+				img.Tags = append(img.Tags, tag.Name)
+				if pushed.After(img.Pushed) {
+					img.Pushed = pushed
+				}
+				continue
+			}
+			byDigest[key] = &RemoteImage{Digest: tag.Digest, Tags: []string{tag.Name}, MediaType: tag.MediaType, Pushed: pushed}
+			order = append(order, key)
+		}
+		next = page.Next
+	}
+
+	images := make([]RemoteImage, 0, len(order))
+	for _, key := range order {
+		images = append(images, *byDigest[key])
+	}
+	return images, nil
+}
+
+// DeleteDigest fails, as Docker Hub neither lists nor deletes untagged images.
+func (r *HubRegistry) DeleteDigest(ctx context.Context, repository, digest string) error {
+	return fmt.Errorf("Docker Hub does not delete untagged images")
+}
+
+// hubToken logs in to the Docker Hub API.
+func (r *HubRegistry) hubToken(ctx context.Context) (string, error) {
+	if r.username == "" || r.password == "" {
+		return "", fmt.Errorf("Docker Hub credentials are required, set DOCKER_USERNAME and DOCKER_PASSWORD")
+	}
+
+	body, err := json.Marshal(map[string]string{"username": r.username, "password": r.password})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hubAPI+"/users/login", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to log in to Docker Hub: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to log in to Docker Hub: %s", resp.Status)
+	}
+
+	var login struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&login); err != nil {
+		return "", fmt.Errorf("failed to decode Docker Hub login response: %w", err)
+	}
+	return login.Token, nil
+}
@@ -0,0 +1,221 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// sarifSchema is the JSON schema of the SARIF 2.1.0 reports written by writeSarif.
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+type sarifLog struct {
+	Schema  string     `json:"$schema,omitempty"`
+	Version string     `json:"version,omitempty"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool struct {
+		Driver struct {
+			Name           string      `json:"name"`
+			InformationURI string      `json:"informationUri,omitempty"`
+			Rules          []sarifRule `json:"rules"`
+		} `json:"driver"`
+	} `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifText struct {
+	Text string `json:"text"`
+}
+
+type sarifRule struct {
+	ID               string    `json:"id"`
+	ShortDescription sarifText `json:"shortDescription"`
+	HelpURI          string    `json:"helpUri,omitempty"`
+	Properties       struct {
+		Tags []string `json:"tags,omitempty"`
+		// SecuritySeverity is the CVSS score GitHub code scanning ranks alerts by.
+		SecuritySeverity string   `json:"security-severity,omitempty"`
+		CVSSSeverity     string   `json:"cvssV3_severity,omitempty"`
+		FixedVersion     string   `json:"fixed_version,omitempty"`
+		Purls            []string `json:"purls,omitempty"`
+	} `json:"properties"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifText       `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+	} `json:"physicalLocation"`
+}
+
+var (
+	messagePackage = regexp.MustCompile(`(?m)^\s*(?:Package|PkgName)\s*:\s*(\S+)`)
+	messageVersion = regexp.MustCompile(`(?m)^\s*Installed Version\s*:\s*(\S+)`)
+	messageFixed   = regexp.MustCompile(`(?m)^\s*Fixed [Vv]ersion\s*:[ \t]*(.*?)\s*$`)
+)
+
+// parseSarif reads the findings of a SARIF report. The severity comes from the
+// rule's severity name or tags, then its CVSS score and last the result level.
+func parseSarif(data []byte) ([]Finding, error) {
+	var log sarifLog
+	if err := json.Unmarshal(data, &log); err != nil {
+		return nil, fmt.Errorf("failed to parse the SARIF report: %w", err)
+	}
+
+	var findings []Finding
+	seen := map[string]bool{}
+	for _, run := range log.Runs {
+		rules := map[string]sarifRule{}
+		for _, rule := range run.Tool.Driver.Rules {
+			rules[rule.ID] = rule
+		}
+		for _, result := range run.Results {
+			rule := rules[result.RuleID]
+			f := Finding{ID: result.RuleID, Title: rule.ShortDescription.Text, FixedVersion: fixedVersion(rule.Properties.FixedVersion)}
+			if f.Title == f.ID {
+				f.Title = ""
+			}
+
+			pkg := ""
+			if m := messagePackage.FindStringSubmatch(result.Message.Text); m != nil {
+				pkg = m[1]
+			} else if len(rule.Properties.Purls) > 0 {
+				pkg = rule.Properties.Purls[0]
+			}
+			f.Package, f.Version = parsePurl(pkg)
+			if m := messageVersion.FindStringSubmatch(result.Message.Text); m != nil {
+				f.Version = m[1]
+			}
+			if m := messageFixed.FindStringSubmatch(result.Message.Text); m != nil && f.FixedVersion == "" {
+				f.FixedVersion = fixedVersion(m[1])
+			}
+
+			f.Severity = normalizeSeverity(rule.Properties.CVSSSeverity)
+			for _, tag := range rule.Properties.Tags {
+				if f.Severity != "unknown" {
+					break
+				}
+				f.Severity = normalizeSeverity(tag)
+			}
+			if score, err := strconv.ParseFloat(rule.Properties.SecuritySeverity, 64); f.Severity == "unknown" && err == nil {
+				f.Severity = scoreSeverity(score)
+			}
+			if f.Severity == "unknown" {
+				switch result.Level {
+				case "error":
+					f.Severity = "high"
+				case "warning":
+					f.Severity = "medium"
+				case "note":
+					f.Severity = "low"
+				}
+			}
+
+			key := f.ID + " " + f.Package + " " + f.Version
+			if !seen[key] {
+				seen[key] = true
+				findings = append(findings, f)
+			}
+		}
+	}
+
+	return findings, nil
+}
+
+// severityScores are the CVSS scores written for each severity, the lower bound of its range.
+var severityScores = map[string]string{"critical": "9.0", "high": "7.0", "medium": "4.0", "low": "0.1"}
+
+// writeSarif writes findings of image as a SARIF report, with one rule per
+// vulnerability and one result per affected package, the same for every scanner.
+func writeSarif(path, scanner, image string, findings []Finding) error {
+	run := sarifRun{Results: []sarifResult{}}
+	run.Tool.Driver.Name = scanner
+	run.Tool.Driver.InformationURI = "https://github.com/clouddrove/smurf"
+	run.Tool.Driver.Rules = []sarifRule{}
+
+	repository, _ := SplitReference(image)
+	rules := map[string]bool{}
+	for _, f := range findings {
+		if !rules[f.ID] {
+			rules[f.ID] = true
+			rule := sarifRule{ID: f.ID, ShortDescription: sarifText{Text: f.ID}}
+			if f.Title != "" {
+				rule.ShortDescription.Text = f.Title
+			}
+			if strings.HasPrefix(f.ID, "CVE-") {
+				rule.HelpURI = "https://nvd.nist.gov/vuln/detail/" + f.ID
+			} else if strings.HasPrefix(f.ID, "GHSA-") {
+				rule.HelpURI = "https://github.com/advisories/" + f.ID
+			}
+			rule.Properties.Tags = []string{"security", f.Severity}
+			rule.Properties.SecuritySeverity = severityScores[f.Severity]
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+		}
+
+		message := fmt.Sprintf("Package: %s\nInstalled Version: %s\n", f.Package, f.Version)
+		if f.FixedVersion != "" {
+			message += fmt.Sprintf("Fixed Version: %s\n", f.FixedVersion)
+		} else {
+			message += "Fixed Version: not fixed\n"
+		}
+		result := sarifResult{RuleID: f.ID, Level: "note", Message: sarifText{Text: message}}
+		switch f.Severity {
+		case "critical", "high":
+			result.Level = "error"
+		case "medium":
+			result.Level = "warning"
+		}
+		var location sarifLocation
+		location.PhysicalLocation.ArtifactLocation.URI = repository
+		result.Locations = []sarifLocation{location}
+		run.Results = append(run.Results, result)
+	}
+
+	data, err := json.MarshalIndent(sarifLog{Schema: sarifSchema, Version: "2.1.0", Runs: []sarifRun{run}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write SARIF report: %w", err)
+	}
+	return nil
+}
+
+// fixedVersion drops the placeholders scanners use when there is no fix yet.
+func fixedVersion(version string) string {
+	if strings.HasPrefix(strings.ToLower(version), "not fixed") {
+		return ""
+	}
+	return version
+}
+
+// parsePurl splits a package URL such as pkg:deb/debian/openssl@3.0.11-1?arch=amd64
+// into its name and version. Other strings are returned as the name.
+func parsePurl(purl string) (string, string) {
+	if !strings.HasPrefix(purl, "pkg:") {
+		return purl, ""
+	}
+	purl = strings.SplitN(purl, "?", 2)[0]
+	purl = strings.SplitN(purl, "#", 2)[0]
+	path, version, _ := strings.Cut(strings.TrimPrefix(purl, "pkg:"), "@")
+	// Drop the package type; a namespace is kept for Go modules and npm scopes
+	_, name, _ := strings.Cut(path, "/")
+	if strings.HasPrefix(path, "deb/") || strings.HasPrefix(path, "apk/") || strings.HasPrefix(path, "rpm/") {
+		name = name[strings.LastIndex(name, "/")+1:]
+	}
+	return name, version
+}
@@ -0,0 +1,407 @@
+package docker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/dryrun"
+	"github.com/docker/docker/client"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pterm/pterm"
+)
+
+// SBOMFormats lists the document formats accepted by CreateSBOM.
+var SBOMFormats = []string{"spdx", "cyclonedx"}
+
+// sbomMediaTypes are the media types of SBOM documents, also used as the
+// artifact type of referrers.
+var sbomMediaTypes = map[string]string{
+	"spdx":      "application/spdx+json",
+	"cyclonedx": "application/vnd.cyclonedx+json",
+}
+
+// SBOM is a software bill of materials generated for an image.
+type SBOM struct {
+	Image  string `json:"image"`
+	Format string `json:"format"`
+	File   string `json:"file"`
+	// Packages is the number of packages in the document.
+	Packages int `json:"packages"`
+	// Referrer is the artifact the SBOM was attached to the pushed image as.
+	Referrer *PushResult `json:"referrer,omitempty"`
+
+	document []byte
+}
+
+// ValidateSBOMFormat checks an SBOM format; an empty format means no SBOM.
+func ValidateSBOMFormat(format string) error {
+	if _, ok := sbomMediaTypes[format]; format != "" && !ok {
+		return fmt.Errorf("unsupported SBOM format '%s' (expected %s)", format, strings.Join(SBOMFormats, ", "))
+	}
+	return nil
+}
+
+// DefaultSBOMFile returns the file an SBOM in format is written to when none is given.
+func DefaultSBOMFile(format string) string {
+	if format == "cyclonedx" {
+		return "sbom.cdx.json"
+	}
+	return "sbom.spdx.json"
+}
+
+// CreateSBOM exports image from the local Docker daemon, inventories the OS
+// packages and language dependencies in its filesystem and writes them to
+// path as an SPDX or CycloneDX JSON document.
+func CreateSBOM(ctx context.Context, image, format, path string) (*SBOM, error) {
+	if format == "" {
+		format = "spdx"
+	}
+	if err := ValidateSBOMFormat(format); err != nil {
+		return nil, err
+	}
+	if path == "" {
+		path = DefaultSBOMFile(format)
+	}
+	sbom := &SBOM{Image: image, Format: format, File: path}
+	if dryrun.Enabled() {
+		dryrun.Record("docker", "generate SBOM for", image, fmt.Sprintf("%s, %s", format, path))
+		return sbom, nil
+	}
+
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Cataloging packages in %s...", image))
+	c, imageID, err := catalogImage(ctx, image)
+	if err != nil {
+		spinner.Fail("Failed to catalog " + image)
+		return nil, err
+	}
+	sort.Slice(c.packages, func(i, j int) bool { return c.packages[i].PURL < c.packages[j].PURL })
+	spinner.Success(fmt.Sprintf("Found %d packages in %s", len(c.packages), image))
+
+	if format == "cyclonedx" {
+		sbom.document, err = encodeCycloneDX(image, imageID, c.packages)
+	} else {
+		sbom.document, err = encodeSPDX(image, imageID, c.packages)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, sbom.document, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write SBOM: %w", err)
+	}
+	sbom.Packages = len(c.packages)
+	pterm.Success.Printf("SBOM saved to: %s\n", path)
+	return sbom, nil
+}
+
+// catalogImage saves image from the daemon and catalogs its flattened filesystem.
+func catalogImage(ctx context.Context, image string) (*catalog, string, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create Docker client: %w", err)
+	}
+	defer cli.Close()
+
+	inspect, _, err := cli.ImageInspectWithRaw(ctx, image)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to inspect image %s: %w", image, err)
+	}
+
+	// tarball reopens the export for every layer, so it is kept in a temporary file
+	tmp, err := os.CreateTemp("", "smurf-sbom-*.tar")
+	if err != nil {
+		return nil, "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	body, err := cli.ImageSave(ctx, []string{inspect.ID})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to export image %s: %w", image, err)
+	}
+	_, err = io.Copy(tmp, body)
+	body.Close()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to export image %s: %w", image, err)
+	}
+
+	img, err := tarball.ImageFromPath(tmp.Name(), nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read the export of %s: %w", image, err)
+	}
+	fs := mutate.Extract(img)
+	defer fs.Close()
+	c, err := catalogFilesystem(fs)
+	if err != nil {
+		return nil, "", err
+	}
+	return c, inspect.ID, nil
+}
+
+// AttachSBOM pushes sbom to reg as an OCI artifact whose subject is the pushed
+// image at subject, a repository@digest reference, so it is listed by the
+// registry's referrers API or the fallback tag for registries without one.
+func AttachSBOM(ctx context.Context, reg Registry, subject string, sbom *SBOM) error {
+	if dryrun.Enabled() {
+		dryrun.Record("docker", "attach SBOM to", subject, sbom.File)
+		return nil
+	}
+	result, err := reg.PushReferrer(ctx, subject, sbomMediaTypes[sbom.Format], sbom.document)
+	if err != nil {
+		return err
+	}
+	sbom.Referrer = result
+	pterm.Success.Printf("SBOM attached to %s as %s\n", subject, result.Reference)
+	return nil
+}
+
+// AttachSBOMToImage attaches sbom to the copy of the local image in its
+// registry, with the credentials a generic registry would use. The image must
+// have been pushed, so that the SBOM describes the exact manifest in the registry.
+func AttachSBOMToImage(ctx context.Context, image string, sbom *SBOM) error {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return fmt.Errorf("invalid reference '%s': %w", image, err)
+	}
+	subject := ""
+	if dryrun.Enabled() {
+		subject = ref.Context().Name()
+	} else {
+		cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+		if err != nil {
+			return fmt.Errorf("failed to create Docker client: %w", err)
+		}
+		defer cli.Close()
+		inspect, _, err := cli.ImageInspectWithRaw(ctx, image)
+		if err != nil {
+			return fmt.Errorf("failed to inspect image %s: %w", image, err)
+		}
+		for _, repoDigest := range inspect.RepoDigests {
+			digest, err := name.NewDigest(repoDigest)
+			if err == nil && digest.Context().Name() == ref.Context().Name() {
+				subject = digest.String()
+				break
+			}
+		}
+		if subject == "" {
+			return fmt.Errorf("%s has not been pushed to %s; push it before attaching an SBOM", image, ref.Context().Name())
+		}
+	}
+
+	reg := NewGenericRegistry(ref.Context().RegistryStr(), "", "", "")
+	if err := reg.Authenticate(ctx); err != nil {
+		return err
+	}
+	return AttachSBOM(ctx, reg, subject, sbom)
+}
+
+// PushReferrer pushes data as a single-layer OCI artifact of artifactType that
+// refers to the manifest at subject. The artifact type is carried as the config
+// media type, which registries use when the manifest has no artifactType field.
+func (b *baseRegistry) PushReferrer(ctx context.Context, subject, artifactType string, data []byte) (*PushResult, error) {
+	subjectRef, err := name.NewDigest(subject, b.nameOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subject '%s': %w", subject, err)
+	}
+	desc, err := remote.Head(subjectRef, b.remoteOptions(ctx)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", subject, err)
+	}
+
+	artifact, err := mutate.Append(empty.Image, mutate.Addendum{Layer: static.NewLayer(data, types.MediaType(artifactType))})
+	if err != nil {
+		return nil, err
+	}
+	artifact = mutate.MediaType(artifact, types.OCIManifestSchema1)
+	artifact = mutate.ConfigMediaType(artifact, types.MediaType(artifactType))
+	artifact = mutate.Subject(artifact, v1.Descriptor{MediaType: desc.MediaType, Digest: desc.Digest, Size: desc.Size}).(v1.Image)
+
+	digest, err := artifact.Digest()
+	if err != nil {
+		return nil, err
+	}
+	ref := subjectRef.Context().Digest(digest.String())
+	if err := remote.Write(ref, artifact, b.remoteOptions(ctx)...); err != nil {
+		return nil, fmt.Errorf("failed to push %s referrer for %s: %w", artifactType, subject, err)
+	}
+	return &PushResult{Image: ref.String(), Digest: digest.String(), Reference: ref.String()}, nil
+}
+
+// invalidSPDXID matches the characters an SPDX identifier may not contain.
+var invalidSPDXID = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// spdxLicense matches simple license expressions such as MIT or Apache-2.0 OR MIT;
+// free text licenses are recorded as NOASSERTION.
+var spdxLicense = regexp.MustCompile(`^[A-Za-z0-9.+-]+( (AND|OR|WITH) [A-Za-z0-9.+-]+)*$`)
+
+// encodeSPDX writes packages as an SPDX 2.3 JSON document describing image.
+func encodeSPDX(image, imageID string, packages []Package) ([]byte, error) {
+	type externalRef struct {
+		Category string `json:"referenceCategory"`
+		Type     string `json:"referenceType"`
+		Locator  string `json:"referenceLocator"`
+	}
+	type spdxPackage struct {
+		SPDXID           string        `json:"SPDXID"`
+		Name             string        `json:"name"`
+		VersionInfo      string        `json:"versionInfo,omitempty"`
+		DownloadLocation string        `json:"downloadLocation"`
+		FilesAnalyzed    bool          `json:"filesAnalyzed"`
+		LicenseConcluded string        `json:"licenseConcluded"`
+		LicenseDeclared  string        `json:"licenseDeclared"`
+		CopyrightText    string        `json:"copyrightText"`
+		SourceInfo       string        `json:"sourceInfo,omitempty"`
+		ExternalRefs     []externalRef `json:"externalRefs,omitempty"`
+	}
+	type relationship struct {
+		Element string `json:"spdxElementId"`
+		Type    string `json:"relationshipType"`
+		Related string `json:"relatedSpdxElement"`
+	}
+
+	imageSPDXID := "SPDXRef-Image"
+	doc := struct {
+		SPDXVersion       string `json:"spdxVersion"`
+		DataLicense       string `json:"dataLicense"`
+		SPDXID            string `json:"SPDXID"`
+		Name              string `json:"name"`
+		DocumentNamespace string `json:"documentNamespace"`
+		CreationInfo      struct {
+			Created  string   `json:"created"`
+			Creators []string `json:"creators"`
+		} `json:"creationInfo"`
+		Packages      []spdxPackage  `json:"packages"`
+		Relationships []relationship `json:"relationships"`
+	}{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              image,
+		DocumentNamespace: fmt.Sprintf("https://github.com/clouddrove/smurf/spdx/%s-%s", invalidSPDXID.ReplaceAllString(image, "-"), newUUID()),
+	}
+	doc.CreationInfo.Created = time.Now().UTC().Format(time.RFC3339)
+	doc.CreationInfo.Creators = []string{"Tool: smurf"}
+
+	doc.Packages = append(doc.Packages, spdxPackage{
+		SPDXID:           imageSPDXID,
+		Name:             image,
+		VersionInfo:      imageID,
+		DownloadLocation: "NOASSERTION",
+		LicenseConcluded: "NOASSERTION",
+		LicenseDeclared:  "NOASSERTION",
+		CopyrightText:    "NOASSERTION",
+	})
+	doc.Relationships = append(doc.Relationships, relationship{Element: "SPDXRef-DOCUMENT", Type: "DESCRIBES", Related: imageSPDXID})
+
+	for i, pkg := range packages {
+		license := "NOASSERTION"
+		if spdxLicense.MatchString(pkg.License) {
+			license = pkg.License
+		}
+		id := fmt.Sprintf("SPDXRef-Package-%s-%s-%d", pkg.Type, invalidSPDXID.ReplaceAllString(pkg.Name, "-"), i+1)
+		doc.Packages = append(doc.Packages, spdxPackage{
+			SPDXID:           id,
+			Name:             pkg.Name,
+			VersionInfo:      pkg.Version,
+			DownloadLocation: "NOASSERTION",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  license,
+			CopyrightText:    "NOASSERTION",
+			SourceInfo:       "found in " + pkg.Location,
+			ExternalRefs:     []externalRef{{Category: "PACKAGE-MANAGER", Type: "purl", Locator: pkg.PURL}},
+		})
+		doc.Relationships = append(doc.Relationships, relationship{Element: imageSPDXID, Type: "CONTAINS", Related: id})
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	return append(data, '\n'), err
+}
+
+// encodeCycloneDX writes packages as a CycloneDX 1.5 JSON document describing image.
+func encodeCycloneDX(image, imageID string, packages []Package) ([]byte, error) {
+	type license struct {
+		License struct {
+			Name string `json:"name"`
+		} `json:"license"`
+	}
+	type property struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	type component struct {
+		Type       string     `json:"type"`
+		BOMRef     string     `json:"bom-ref"`
+		Name       string     `json:"name"`
+		Version    string     `json:"version,omitempty"`
+		PURL       string     `json:"purl,omitempty"`
+		Licenses   []license  `json:"licenses,omitempty"`
+		Properties []property `json:"properties,omitempty"`
+	}
+
+	doc := struct {
+		BOMFormat    string `json:"bomFormat"`
+		SpecVersion  string `json:"specVersion"`
+		SerialNumber string `json:"serialNumber"`
+		Version      int    `json:"version"`
+		Metadata     struct {
+			Timestamp string `json:"timestamp"`
+			Tools     struct {
+				Components []component `json:"components"`
+			} `json:"tools"`
+			Component component `json:"component"`
+		} `json:"metadata"`
+		Components []component `json:"components"`
+	}{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + newUUID(),
+		Version:      1,
+		Components:   []component{},
+	}
+	doc.Metadata.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	doc.Metadata.Tools.Components = []component{{Type: "application", BOMRef: "smurf", Name: "smurf"}}
+	doc.Metadata.Component = component{Type: "container", BOMRef: imageID, Name: image, Version: imageID}
+
+	for _, pkg := range packages {
+		c := component{
+			Type:       "library",
+			BOMRef:     pkg.PURL,
+			Name:       pkg.Name,
+			Version:    pkg.Version,
+			PURL:       pkg.PURL,
+			Properties: []property{{Name: "smurf:package:type", Value: pkg.Type}, {Name: "smurf:location", Value: pkg.Location}},
+		}
+		if pkg.License != "" {
+			var l license
+			l.License.Name = pkg.License
+			c.Licenses = []license{l}
+		}
+		doc.Components = append(doc.Components, c)
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	return append(data, '\n'), err
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
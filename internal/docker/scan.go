@@ -0,0 +1,338 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/clouddrove/smurf/internal/dryrun"
+	"github.com/clouddrove/smurf/internal/output"
+	"github.com/pterm/pterm"
+	"sigs.k8s.io/yaml"
+)
+
+// Severities lists vulnerability severities from the most to the least severe.
+var Severities = []string{"critical", "high", "medium", "low", "unknown"}
+
+// FailOnSeverities lists the thresholds accepted by --fail-on.
+var FailOnSeverities = []string{"critical", "high", "medium", "low"}
+
+// DefaultIgnoreFile is read when it exists and no other ignore file is given.
+const DefaultIgnoreFile = ".smurf-ignore.yaml"
+
+// ScanOptions configures a vulnerability scan.
+type ScanOptions struct {
+	// SarifFile is where the SARIF report of the findings is written.
+	SarifFile string
+	// FailOn fails the scan when a finding is at least this severe.
+	FailOn string
+	// IgnoreFile lists accepted vulnerabilities; see IgnoreRule.
+	IgnoreFile string
+	// Scanner is one of Scanners; it defaults to Docker Scout.
+	Scanner string
+}
+
+// Finding is a vulnerability reported for a package in an image.
+type Finding struct {
+	ID           string `json:"id"`
+	Severity     string `json:"severity"`
+	Package      string `json:"package,omitempty"`
+	Version      string `json:"version,omitempty"`
+	FixedVersion string `json:"fixedVersion,omitempty"`
+	Title        string `json:"title,omitempty"`
+}
+
+// ScanReport holds the findings of a scan. Findings matched by an ignore rule
+// are moved to Ignored and left out of Counts.
+type ScanReport struct {
+	Image    string         `json:"image"`
+	Scanner  string         `json:"scanner"`
+	Sarif    string         `json:"sarif,omitempty"`
+	Counts   map[string]int `json:"counts"`
+	Findings []Finding      `json:"findings"`
+	Ignored  []Finding      `json:"ignored,omitempty"`
+}
+
+// IgnoreRule accepts a vulnerability until it expires. An ignore file holds a list of rules:
+//
+//	ignore:
+//	  - id: CVE-2023-44487
+//	    package: golang.org/x/net
+//	    reason: HTTP/2 is not exposed
+//	    expires: 2025-06-30
+type IgnoreRule struct {
+	ID string `json:"id"`
+	// Package limits the rule to one package; it matches every package when empty.
+	Package string `json:"package,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	// Expires is the last day, as YYYY-MM-DD, on which the rule applies.
+	Expires string `json:"expires"`
+}
+
+// AtOrAbove returns the findings that are at least as severe as severity.
+func (r *ScanReport) AtOrAbove(severity string) []Finding {
+	var findings []Finding
+	for _, f := range r.Findings {
+		if severityRank(f.Severity) <= severityRank(severity) {
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// ValidateSeverity checks a --fail-on threshold; an empty threshold never fails.
+func ValidateSeverity(severity string) error {
+	if severity == "" {
+		return nil
+	}
+	for _, s := range FailOnSeverities {
+		if severity == s {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid severity '%s' (expected %s)", severity, strings.Join(FailOnSeverities, ", "))
+}
+
+// Scanner finds known vulnerabilities in an image.
+type Scanner interface {
+	// Name identifies the scanner in reports.
+	Name() string
+	// Scan returns the vulnerabilities found in image.
+	Scan(ctx context.Context, image string) ([]Finding, error)
+}
+
+// Scanners lists the backends accepted by NewScanner.
+var Scanners = []string{"scout", "trivy", "grype"}
+
+// NewScanner returns the scanner backend for kind; an empty kind selects Docker Scout.
+func NewScanner(kind string) (Scanner, error) {
+	switch kind {
+	case "scout", "":
+		return ScoutScanner{}, nil
+	case "trivy":
+		return TrivyScanner{}, nil
+	case "grype":
+		return GrypeScanner{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported scanner '%s' (expected %s)", kind, strings.Join(Scanners, ", "))
+	}
+}
+
+// Scan scans a Docker image for known vulnerabilities with the scanner selected
+// in opts, prints a summary of the findings by severity and fails when a finding
+// that is not ignored reaches opts.FailOn.
+func Scan(ctx context.Context, dockerTag string, opts ScanOptions) (*ScanReport, error) {
+	scanner, err := NewScanner(opts.Scanner)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateSeverity(opts.FailOn); err != nil {
+		return nil, err
+	}
+	rules, err := loadIgnoreRules(opts.IgnoreFile)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ScanReport{Image: dockerTag, Scanner: scanner.Name(), Sarif: opts.SarifFile, Counts: map[string]int{}, Findings: []Finding{}}
+	if dryrun.Enabled() {
+		detail := scanner.Name()
+		if opts.FailOn != "" {
+			detail += ", fail on " + opts.FailOn
+		}
+		dryrun.Record("docker", "scan", dockerTag, detail)
+		return report, nil
+	}
+
+	findings, err := scanner.Scan(ctx, dockerTag)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if ri, rj := severityRank(findings[i].Severity), severityRank(findings[j].Severity); ri != rj {
+			return ri < rj
+		}
+		return findings[i].ID < findings[j].ID
+	})
+
+	report.Findings, report.Ignored = applyIgnoreRules(findings, rules, time.Now())
+	for _, f := range report.Findings {
+		report.Counts[f.Severity]++
+	}
+	if opts.SarifFile != "" {
+		if err := writeSarif(opts.SarifFile, scanner.Name(), dockerTag, report.Findings); err != nil {
+			return nil, err
+		}
+		pterm.Success.Println("SARIF report saved to:", opts.SarifFile)
+	}
+	printScanReport(report)
+
+	if opts.FailOn != "" {
+		if blocking := report.AtOrAbove(opts.FailOn); len(blocking) > 0 {
+			return report, fmt.Errorf("%d findings at or above %s severity (--fail-on %s)", len(blocking), opts.FailOn, opts.FailOn)
+		}
+	}
+	return report, nil
+}
+
+// runScanner runs a scanner binary with extra environment variables and returns
+// its standard output.
+func runScanner(ctx context.Context, env []string, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = append(os.Environ(), env...)
+	var stdoutBuf, stderrBuf bytes.Buffer
+	cmd.Stdout = &stdoutBuf
+	cmd.Stderr = &stderrBuf
+
+	command := name + " " + args[0]
+	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Running '%s'", command))
+	err := cmd.Run()
+	spinner.Stop()
+
+	if err != nil {
+		if ctx.Err() != nil {
+			pterm.Warning.Println("Scan cancelled")
+			return nil, fmt.Errorf("scan cancelled: %w", ctx.Err())
+		}
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("%s is not installed; install it or select another --scanner (%s)", name, strings.Join(Scanners, ", "))
+		}
+		if errStr := strings.TrimSpace(stderrBuf.String()); errStr != "" {
+			pterm.Error.Println(errStr)
+		}
+		return nil, fmt.Errorf("failed to run '%s': %w", command, err)
+	}
+	return stdoutBuf.Bytes(), nil
+}
+
+// printScanReport prints the number of findings per severity, followed by the
+// findings themselves from the most to the least severe.
+func printScanReport(report *ScanReport) {
+	summary := pterm.TableData{{"Severity", "Count"}}
+	for _, severity := range Severities {
+		summary = append(summary, []string{severity, fmt.Sprint(report.Counts[severity])})
+	}
+	pterm.DefaultTable.WithHasHeader().WithWriter(output.Writer()).WithData(summary).Render()
+
+	if len(report.Findings) > 0 {
+		data := pterm.TableData{{"Severity", "ID", "Package", "Version", "Fixed In"}}
+		for _, f := range report.Findings {
+			data = append(data, []string{f.Severity, f.ID, f.Package, f.Version, f.FixedVersion})
+		}
+		fmt.Fprintln(output.Writer())
+		pterm.DefaultTable.WithHasHeader().WithWriter(output.Writer()).WithData(data).Render()
+	}
+	if len(report.Ignored) > 0 {
+		pterm.Info.Printf("%d findings accepted by the ignore file\n", len(report.Ignored))
+	}
+}
+
+func severityRank(severity string) int {
+	for i, s := range Severities {
+		if s == severity {
+			return i
+		}
+	}
+	return len(Severities) - 1
+}
+
+// normalizeSeverity maps the severity names used by scanners onto Severities.
+func normalizeSeverity(severity string) string {
+	switch strings.ToLower(strings.TrimSpace(severity)) {
+	case "critical":
+		return "critical"
+	case "high":
+		return "high"
+	case "medium", "moderate":
+		return "medium"
+	case "low", "negligible":
+		return "low"
+	}
+	return "unknown"
+}
+
+// scoreSeverity maps a CVSS score onto a severity.
+func scoreSeverity(score float64) string {
+	switch {
+	case score >= 9:
+		return "critical"
+	case score >= 7:
+		return "high"
+	case score >= 4:
+		return "medium"
+	case score > 0:
+		return "low"
+	}
+	return "unknown"
+}
+
+// loadIgnoreRules reads an ignore file. A missing DefaultIgnoreFile is not an error.
+func loadIgnoreRules(path string) ([]IgnoreRule, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && path == DefaultIgnoreFile {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read ignore file: %w", err)
+	}
+
+	var file struct {
+		Ignore []IgnoreRule `json:"ignore"`
+	}
+	if err := yaml.UnmarshalStrict(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse ignore file %s: %w", path, err)
+	}
+	for _, rule := range file.Ignore {
+		if rule.ID == "" {
+			return nil, fmt.Errorf("ignore file %s: every rule needs an id", path)
+		}
+		if rule.Expires == "" {
+			return nil, fmt.Errorf("ignore file %s: the rule for %s needs an expires date", path, rule.ID)
+		}
+		if _, err := time.Parse(time.DateOnly, rule.Expires); err != nil {
+			return nil, fmt.Errorf("ignore file %s: the rule for %s has an invalid expires date '%s' (expected YYYY-MM-DD)", path, rule.ID, rule.Expires)
+		}
+	}
+	pterm.Info.Printf("Loaded %d ignore rules from %s\n", len(file.Ignore), path)
+	return file.Ignore, nil
+}
+
+// applyIgnoreRules splits findings into those that count and those accepted by
+// a rule that has not expired at now. Expired rules are reported once.
+func applyIgnoreRules(findings []Finding, rules []IgnoreRule, now time.Time) (kept, ignored []Finding) {
+	kept = []Finding{}
+	var active []IgnoreRule
+	for _, rule := range rules {
+		expires, _ := time.Parse(time.DateOnly, rule.Expires)
+		if now.UTC().Before(expires.AddDate(0, 0, 1)) {
+			active = append(active, rule)
+		} else {
+			pterm.Warning.Printf("The ignore rule for %s expired on %s and no longer applies\n", rule.ID, rule.Expires)
+		}
+	}
+
+	for _, f := range findings {
+		accepted := false
+		for _, rule := range active {
+			if strings.EqualFold(rule.ID, f.ID) && (rule.Package == "" || rule.Package == f.Package) {
+				accepted = true
+				break
+			}
+		}
+		if accepted {
+			ignored = append(ignored, f)
+		} else {
+			kept = append(kept, f)
+		}
+	}
+	return kept, ignored
+}
@@ -0,0 +1,60 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// GrypeScanner scans images with a local grype binary. Automatic database
+// updates are turned off, so the database must be fetched beforehand, for
+// example with 'grype db update'.
+type GrypeScanner struct{}
+
+func (GrypeScanner) Name() string {
+	return "grype"
+}
+
+func (GrypeScanner) Scan(ctx context.Context, image string) ([]Finding, error) {
+	env := []string{"GRYPE_DB_AUTO_UPDATE=false", "GRYPE_CHECK_FOR_APP_UPDATE=false"}
+	out, err := runScanner(ctx, env, "grype", image, "--output", "json", "--quiet")
+	if err != nil {
+		return nil, err
+	}
+	return parseGrype(out)
+}
+
+// parseGrype reads the findings of a grype JSON report.
+func parseGrype(data []byte) ([]Finding, error) {
+	var report struct {
+		Matches []struct {
+			Vulnerability struct {
+				ID       string `json:"id"`
+				Severity string `json:"severity"`
+				Fix      struct {
+					Versions []string `json:"versions"`
+				} `json:"fix"`
+			} `json:"vulnerability"`
+			Artifact struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"artifact"`
+		} `json:"matches"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse the grype report: %w", err)
+	}
+
+	findings := []Finding{}
+	for _, match := range report.Matches {
+		findings = append(findings, Finding{
+			ID:           match.Vulnerability.ID,
+			Severity:     normalizeSeverity(match.Vulnerability.Severity),
+			Package:      match.Artifact.Name,
+			Version:      match.Artifact.Version,
+			FixedVersion: strings.Join(match.Vulnerability.Fix.Versions, ", "),
+		})
+	}
+	return findings, nil
+}
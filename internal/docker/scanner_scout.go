@@ -0,0 +1,33 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// ScoutScanner scans images with the Docker Scout CLI plugin, which needs a
+// Docker Hub login.
+type ScoutScanner struct{}
+
+func (ScoutScanner) Name() string {
+	return "docker scout"
+}
+
+func (ScoutScanner) Scan(ctx context.Context, image string) ([]Finding, error) {
+	tmp, err := os.CreateTemp("", "smurf-scout-*.sarif")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if _, err := runScanner(ctx, nil, "docker", "scout", "cves", image, "--format", "sarif", "--output", tmp.Name()); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Docker Scout report: %w", err)
+	}
+	return parseSarif(data)
+}
@@ -0,0 +1,257 @@
+package docker
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/clouddrove/smurf/internal/dryrun"
+	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pterm/pterm"
+)
+
+// Signatures are stored the way cosign stores them, so that either tool can
+// verify the other's: an image tagged sha256-<digest>.sig next to the signed
+// manifest, with one simple signing payload per layer and the signature in the
+// layer's annotation.
+const (
+	signatureMediaType  = "application/vnd.dev.cosign.simplesigning.v1+json"
+	signatureAnnotation = "dev.cosignproject.cosign/signature"
+	signatureType       = "cosign container image signature"
+	maxSignaturePayload = 1 << 20
+)
+
+// simpleSigning is the payload that is signed: it binds a manifest digest to
+// the repository it was pushed to.
+type simpleSigning struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+	Optional map[string]interface{} `json:"optional"`
+}
+
+// Verification is the result of checking the signatures of an image.
+type Verification struct {
+	Image  string `json:"image"`
+	Digest string `json:"digest"`
+	// Signatures is the number of signatures made with the key.
+	Signatures int `json:"signatures"`
+}
+
+// SignImage signs the manifest at subject, a repository@digest reference, with
+// key and stores the signature in reg next to the image.
+func SignImage(ctx context.Context, reg Registry, subject string, key *ecdsa.PrivateKey) (*PushResult, error) {
+	if dryrun.Enabled() {
+		dryrun.Record("docker", "sign", subject, "")
+		return &PushResult{Image: subject}, nil
+	}
+	ref, err := name.NewDigest(subject)
+	if err != nil {
+		return nil, fmt.Errorf("cannot sign '%s': a repository@digest reference is needed", subject)
+	}
+
+	var payload simpleSigning
+	payload.Critical.Identity.DockerReference = ref.Context().Name()
+	payload.Critical.Image.DockerManifestDigest = ref.DigestStr()
+	payload.Critical.Type = signatureType
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, sum[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign %s: %w", subject, err)
+	}
+
+	result, err := reg.PushSignature(ctx, subject, data, base64.StdEncoding.EncodeToString(signature))
+	if err != nil {
+		return nil, err
+	}
+	pterm.Success.Printf("Signed %s, signature stored as %s\n", subject, result.Image)
+	return result, nil
+}
+
+// SignRemoteImage signs an image in its registry, with the credentials a
+// generic registry would use. A tag is resolved to the digest it points to.
+func SignRemoteImage(ctx context.Context, image string, key *ecdsa.PrivateKey) (*PushResult, error) {
+	reg, ref, err := remoteImageRegistry(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	subject := image
+	if !dryrun.Enabled() {
+		digest, err := reg.resolveDigest(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		subject = digest.String()
+	}
+	return SignImage(ctx, reg, subject, key)
+}
+
+// VerifyImage checks that an image in its registry carries at least one valid
+// signature made with key for the digest the reference resolves to.
+func VerifyImage(ctx context.Context, image string, key *ecdsa.PublicKey) (*Verification, error) {
+	reg, ref, err := remoteImageRegistry(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := reg.resolveDigest(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	verification := &Verification{Image: image, Digest: digest.DigestStr()}
+
+	sigs, err := remote.Image(digest.Context().Tag(signatureTag(digest.DigestStr())), reg.remoteOptions(ctx)...)
+	if err != nil {
+		var terr *transport.Error
+		if errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%s has no signatures", digest)
+		}
+		return nil, fmt.Errorf("failed to fetch the signatures of %s: %w", digest, err)
+	}
+	manifest, err := sigs.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	for _, desc := range manifest.Layers {
+		encoded, ok := desc.Annotations[signatureAnnotation]
+		if !ok || desc.MediaType != signatureMediaType || desc.Size > maxSignaturePayload {
+			continue
+		}
+		layer, err := sigs.LayerByDigest(desc.Digest)
+		if err != nil {
+			return nil, err
+		}
+		data, err := readLayer(layer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signature payload %s: %w", desc.Digest, err)
+		}
+		if verifySignature(key, data, encoded, digest.DigestStr()) {
+			verification.Signatures++
+		}
+	}
+	if verification.Signatures == 0 {
+		return nil, fmt.Errorf("%s has no valid signature for the given key", digest)
+	}
+	pterm.Success.Printf("Verified %d signatures of %s\n", verification.Signatures, digest)
+	return verification, nil
+}
+
+// PushSignature adds a signature layer to the signature image of subject,
+// creating the image when subject has no signatures yet.
+func (b *baseRegistry) PushSignature(ctx context.Context, subject string, payload []byte, signature string) (*PushResult, error) {
+	subjectRef, err := name.NewDigest(subject, b.nameOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subject '%s': %w", subject, err)
+	}
+	tag := subjectRef.Context().Tag(signatureTag(subjectRef.DigestStr()))
+
+	sigs := mutate.ConfigMediaType(mutate.MediaType(empty.Image, types.OCIManifestSchema1), types.OCIConfigJSON)
+	existing, err := remote.Image(tag, b.remoteOptions(ctx)...)
+	var terr *transport.Error
+	switch {
+	case err == nil:
+		sigs = existing
+	case errors.As(err, &terr) && terr.StatusCode == http.StatusNotFound:
+	default:
+		return nil, fmt.Errorf("failed to fetch the signatures of %s: %w", subject, err)
+	}
+
+	sigs, err = mutate.Append(sigs, mutate.Addendum{
+		Layer:       static.NewLayer(payload, signatureMediaType),
+		Annotations: map[string]string{signatureAnnotation: signature},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := remote.Write(tag, sigs, b.remoteOptions(ctx)...); err != nil {
+		return nil, fmt.Errorf("failed to push the signature of %s: %w", subject, err)
+	}
+	digest, err := sigs.Digest()
+	if err != nil {
+		return nil, err
+	}
+	return &PushResult{Image: tag.String(), Digest: digest.String(), Reference: tag.Context().Digest(digest.String()).String()}, nil
+}
+
+// resolveDigest returns the digest reference a tag currently points to.
+func (b *baseRegistry) resolveDigest(ctx context.Context, ref name.Reference) (name.Digest, error) {
+	if digest, ok := ref.(name.Digest); ok {
+		return digest, nil
+	}
+	desc, err := remote.Head(ref, b.remoteOptions(ctx)...)
+	if err != nil {
+		return name.Digest{}, fmt.Errorf("failed to resolve %s: %w", ref, err)
+	}
+	return ref.Context().Digest(desc.Digest.String()), nil
+}
+
+// remoteImageRegistry parses image and returns its registry, authenticated like
+// a generic registry.
+func remoteImageRegistry(ctx context.Context, image string) (*GenericRegistry, name.Reference, error) {
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid reference '%s': %w", image, err)
+	}
+	reg := NewGenericRegistry(ref.Context().RegistryStr(), "", "", "")
+	if err := reg.Authenticate(ctx); err != nil {
+		return nil, nil, err
+	}
+	return reg, ref, nil
+}
+
+// verifySignature checks a base64 encoded signature of payload and that the
+// payload is a cosign payload for digest.
+func verifySignature(key *ecdsa.PublicKey, payload []byte, encoded, digest string) bool {
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return false
+	}
+	sum := sha256.Sum256(payload)
+	if !ecdsa.VerifyASN1(key, sum[:], signature) {
+		return false
+	}
+	var claims simpleSigning
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return false
+	}
+	return claims.Critical.Type == signatureType && claims.Critical.Image.DockerManifestDigest == digest
+}
+
+// readLayer reads the content of a small layer, such as a signature payload.
+func readLayer(layer v1.Layer) ([]byte, error) {
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, maxSignaturePayload))
+}
+
+// signatureTag returns the tag cosign stores the signatures of digest under.
+func signatureTag(digest string) string {
+	return strings.Replace(digest, ":", "-", 1) + ".sig"
+}
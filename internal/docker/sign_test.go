@@ -0,0 +1,211 @@
+package docker
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+)
+
+// testKeyPair generates a cosign key pair in a temporary directory and loads it back.
+func testKeyPair(t *testing.T) (*ecdsa.PrivateKey, *ecdsa.PublicKey) {
+	t.Helper()
+	t.Setenv("COSIGN_PASSWORD", "correct horse")
+	path := filepath.Join(t.TempDir(), DefaultKeyFile)
+	publicPath, err := GenerateKeyPair(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GenerateKeyPair(path); err == nil {
+		t.Error("GenerateKeyPair overwrote an existing key")
+	}
+	private, err := LoadSigningKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := LoadPublicKey(publicPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return private, public
+}
+
+// signatureLayers returns the signature payloads and annotations stored for digest.
+func signatureLayers(t *testing.T, reg *testRegistry, digest name.Digest) ([][]byte, []string) {
+	t.Helper()
+	sigs, err := remote.Image(digest.Context().Tag(signatureTag(digest.DigestStr())), reg.auth())
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := sigs.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payloads [][]byte
+	var signatures []string
+	for _, desc := range manifest.Layers {
+		layer, err := sigs.LayerByDigest(desc.Digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := readLayer(layer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		payloads = append(payloads, data)
+		signatures = append(signatures, desc.Annotations[signatureAnnotation])
+	}
+	return payloads, signatures
+}
+
+// writeSignatures replaces the signatures stored for digest.
+func writeSignatures(t *testing.T, reg *testRegistry, digest name.Digest, payloads [][]byte, signatures []string) {
+	t.Helper()
+	sigs := mutate.ConfigMediaType(mutate.MediaType(empty.Image, types.OCIManifestSchema1), types.OCIConfigJSON)
+	for i, payload := range payloads {
+		var err error
+		sigs, err = mutate.Append(sigs, mutate.Addendum{
+			Layer:       static.NewLayer(payload, signatureMediaType),
+			Annotations: map[string]string{signatureAnnotation: signatures[i]},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := remote.Write(digest.Context().Tag(signatureTag(digest.DigestStr())), sigs, reg.auth()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	clearCredentials(t)
+	reg := newTestRegistry(t, "signer", "s3cret", "")
+	t.Setenv("REGISTRY_USERNAME", "signer")
+	t.Setenv("REGISTRY_PASSWORD", "s3cret")
+	image := reg.host + "/team/myapp:v1"
+	digest := reg.write(t, image)
+	private, public := testKeyPair(t)
+	ctx := context.Background()
+
+	if _, err := VerifyImage(ctx, image, public); err == nil || !strings.Contains(err.Error(), "no signatures") {
+		t.Fatalf("verifying an unsigned image: %v", err)
+	}
+
+	result, err := SignRemoteImage(ctx, image, private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := reg.host + "/team/myapp:" + signatureTag(digest.String()); result.Image != want {
+		t.Errorf("signature stored as %s, want %s", result.Image, want)
+	}
+	verification, err := VerifyImage(ctx, image, public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verification.Digest != digest.String() || verification.Signatures != 1 {
+		t.Errorf("verification = %+v", verification)
+	}
+
+	// A second signature is added next to the first one
+	if _, err := SignRemoteImage(ctx, reg.host+"/team/myapp@"+digest.String(), private); err != nil {
+		t.Fatal(err)
+	}
+	verification, err = VerifyImage(ctx, reg.host+"/team/myapp@"+digest.String(), public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verification.Signatures != 2 {
+		t.Errorf("signatures = %d, want 2", verification.Signatures)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	clearCredentials(t)
+	reg := newTestRegistry(t, "", "", "")
+	private, public := testKeyPair(t)
+	ctx := context.Background()
+
+	signed := func(t *testing.T, repository string) (string, name.Digest) {
+		t.Helper()
+		image := reg.host + "/" + repository + ":v1"
+		digest, err := name.NewDigest(reg.host + "/" + repository + "@" + reg.write(t, image).String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := SignRemoteImage(ctx, image, private); err != nil {
+			t.Fatal(err)
+		}
+		return image, digest
+	}
+
+	t.Run("wrong key", func(t *testing.T) {
+		image, _ := signed(t, "team/wrongkey")
+		other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := VerifyImage(ctx, image, &other.PublicKey); err == nil {
+			t.Fatal("verified with a key that did not sign the image")
+		}
+	})
+
+	t.Run("tampered payload", func(t *testing.T) {
+		image, digest := signed(t, "team/tampered")
+		payloads, signatures := signatureLayers(t, reg, digest)
+		writeSignatures(t, reg, digest, payloads, signatures)
+		if _, err := VerifyImage(ctx, image, public); err != nil {
+			t.Fatalf("rewriting the signatures unchanged broke them: %v", err)
+		}
+
+		var claims simpleSigning
+		if err := json.Unmarshal(payloads[0], &claims); err != nil {
+			t.Fatal(err)
+		}
+		claims.Critical.Identity.DockerReference = "evil.example.com/team/tampered"
+		tampered, err := json.Marshal(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeSignatures(t, reg, digest, [][]byte{tampered}, signatures)
+		if _, err := VerifyImage(ctx, image, public); err == nil {
+			t.Fatal("verified a tampered payload")
+		}
+	})
+
+	t.Run("signature of another image", func(t *testing.T) {
+		_, signedDigest := signed(t, "team/original")
+		image := reg.host + "/team/original:v2"
+		digest, err := name.NewDigest(reg.host + "/team/original@" + reg.write(t, image).String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		payloads, signatures := signatureLayers(t, reg, signedDigest)
+		writeSignatures(t, reg, digest, payloads, signatures)
+		if _, err := VerifyImage(ctx, image, public); err == nil {
+			t.Fatal("verified a signature copied from another image")
+		}
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), DefaultKeyFile)
+		t.Setenv("COSIGN_PASSWORD", "correct horse")
+		if _, err := GenerateKeyPair(path); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("COSIGN_PASSWORD", "wrong")
+		if _, err := LoadSigningKey(path); err == nil {
+			t.Fatal("loaded a private key with the wrong password")
+		}
+	})
+}
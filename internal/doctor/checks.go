@@ -0,0 +1,183 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/clouddrove/smurf/internal/helm"
+	"github.com/docker/docker/client"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"golang.org/x/oauth2/google"
+)
+
+// credentialTimeout bounds checks that may wait on cloud metadata endpoints.
+const credentialTimeout = 15 * time.Second
+
+func checkTerraform(ctx context.Context) (Status, string) {
+	binary, err := exec.LookPath("terraform")
+	if err != nil {
+		return Fail, "terraform not found in PATH, required by 'smurf stf'"
+	}
+
+	tf, err := tfexec.NewTerraform(".", binary)
+	if err != nil {
+		return Fail, fmt.Sprintf("%s is not usable: %v", binary, err)
+	}
+	version, _, err := tf.Version(ctx, true)
+	if err != nil {
+		return Fail, fmt.Sprintf("%s did not report its version: %v", binary, err)
+	}
+	return Pass, fmt.Sprintf("Terraform %s at %s", version, binary)
+}
+
+func checkDocker(ctx context.Context) (Status, string) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return Fail, fmt.Sprintf("invalid Docker client configuration: %v", err)
+	}
+	defer cli.Close()
+
+	version, err := cli.ServerVersion(ctx)
+	if err != nil {
+		return Fail, fmt.Sprintf("daemon at %s is not reachable: %v", cli.DaemonHost(), err)
+	}
+	return Pass, fmt.Sprintf("Docker %s (API %s, client negotiated %s) at %s", version.Version, version.APIVersion, cli.ClientVersion(), cli.DaemonHost())
+}
+
+func checkScout(ctx context.Context) (Status, string) {
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", "scout", "version")
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		return Warn, "'docker scout' is not available, scans need --scanner trivy or grype"
+	}
+
+	for _, line := range strings.Split(out.String(), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "version:") {
+			return Pass, "docker scout " + strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "version:"))
+		}
+	}
+	return Pass, "docker scout is installed"
+}
+
+func checkScanners(ctx context.Context) (Status, string) {
+	var found []string
+	for _, scanner := range [][]string{{"trivy", "--version"}, {"grype", "version"}} {
+		out, err := exec.CommandContext(ctx, scanner[0], scanner[1]).Output()
+		if err != nil {
+			continue
+		}
+		version := ""
+		for _, line := range strings.Split(string(out), "\n") {
+			if key, value, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(key) == "Version" {
+				version = " " + strings.TrimSpace(value)
+				break
+			}
+		}
+		found = append(found, scanner[0]+version)
+	}
+	if len(found) == 0 {
+		return Warn, "neither trivy nor grype is installed, scans need the Docker Scout plugin"
+	}
+	return Pass, strings.Join(found, ", ")
+}
+
+func checkBuildx(ctx context.Context) (Status, string) {
+	out, err := exec.CommandContext(ctx, "docker", "buildx", "version").Output()
+	if err != nil {
+		return Warn, "'docker buildx' is not available, BuildKit builds (--buildkit, --secret, --ssh, --cache-from, --cache-to) will fail"
+	}
+	return Pass, strings.TrimSpace(string(out))
+}
+
+func checkKubernetes(ctx context.Context) (Status, string) {
+	info, err := helm.Cluster()
+	if info.Kubeconfig != "" {
+		if _, statErr := os.Stat(info.Kubeconfig); errors.Is(statErr, os.ErrNotExist) && os.Getenv("KUBECONFIG") == "" {
+			return Warn, fmt.Sprintf("no kubeconfig at %s, required by 'smurf selm'", info.Kubeconfig)
+		}
+	}
+	if err != nil {
+		if info.Context == "" {
+			return Fail, fmt.Sprintf("no usable context in %s: %v", info.Kubeconfig, err)
+		}
+		return Fail, fmt.Sprintf("context '%s' is not reachable: %v", info.Context, err)
+	}
+	return Pass, fmt.Sprintf("context '%s' reachable at %s (Kubernetes %s)", info.Context, info.Server, info.Version)
+}
+
+func checkHelmDriver(ctx context.Context) (Status, string) {
+	driver := os.Getenv("HELM_DRIVER")
+	switch driver {
+	case "":
+		return Pass, "secret (default)"
+	case "secret", "secrets", "configmap", "configmaps", "memory":
+		return Pass, driver
+	case "sql":
+		if os.Getenv("HELM_DRIVER_SQL_CONNECTION_STRING") == "" {
+			return Fail, "HELM_DRIVER=sql requires HELM_DRIVER_SQL_CONNECTION_STRING"
+		}
+		return Pass, driver
+	default:
+		return Fail, fmt.Sprintf("unknown HELM_DRIVER '%s' (expected secret, configmap, memory or sql)", driver)
+	}
+}
+
+func checkAWS(ctx context.Context) (Status, string) {
+	sess, err := session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable})
+	if err != nil {
+		return Warn, fmt.Sprintf("AWS configuration could not be loaded: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, credentialTimeout)
+	defer cancel()
+	creds, err := sess.Config.Credentials.GetWithContext(ctx)
+	if err != nil {
+		return Warn, "no AWS credentials found, required by 'sdkr push aws' and 'provision-ecr'"
+	}
+
+	region := aws.StringValue(sess.Config.Region)
+	if region == "" {
+		region = "none, pass --region"
+	}
+	return Pass, fmt.Sprintf("credentials from %s (default region: %s)", creds.ProviderName, region)
+}
+
+func checkAzure(ctx context.Context) (Status, string) {
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return Warn, fmt.Sprintf("Azure credentials could not be configured: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, credentialTimeout)
+	defer cancel()
+	if _, err := cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{"https://management.azure.com/.default"}}); err != nil {
+		return Warn, "no Azure credentials found, required by 'sdkr push az' and 'provision-acr'"
+	}
+	return Pass, "Azure credentials obtained a management token"
+}
+
+func checkGCP(ctx context.Context) (Status, string) {
+	ctx, cancel := context.WithTimeout(ctx, credentialTimeout)
+	defer cancel()
+
+	creds, err := google.FindDefaultCredentials(ctx, "https://www.googleapis.com/auth/cloud-platform")
+	if err != nil {
+		return Warn, "no Google application default credentials found, required by 'sdkr push gcp' and 'provision-gcr'"
+	}
+	if creds.ProjectID != "" {
+		return Pass, fmt.Sprintf("application default credentials for project %s", creds.ProjectID)
+	}
+	return Pass, "application default credentials found"
+}
@@ -0,0 +1,77 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pterm/pterm"
+)
+
+// Status is the outcome of a single diagnostic check.
+type Status string
+
+const (
+	Pass Status = "pass"
+	Warn Status = "warn"
+	Fail Status = "fail"
+)
+
+// Result is the outcome of a check together with a short explanation.
+type Result struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// Check inspects one part of the environment smurf depends on.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) (Status, string)
+}
+
+// Checks returns the default diagnostics in the order they are reported.
+func Checks() []Check {
+	return []Check{
+		{Name: "Terraform binary", Run: checkTerraform},
+		{Name: "Docker daemon", Run: checkDocker},
+		{Name: "Docker Scout plugin", Run: checkScout},
+		{Name: "Trivy and Grype scanners", Run: checkScanners},
+		{Name: "Docker Buildx plugin", Run: checkBuildx},
+		{Name: "Kubernetes cluster", Run: checkKubernetes},
+		{Name: "Helm storage driver", Run: checkHelmDriver},
+		{Name: "AWS credentials", Run: checkAWS},
+		{Name: "Azure credentials", Run: checkAzure},
+		{Name: "GCP credentials", Run: checkGCP},
+	}
+}
+
+// Run executes the checks one after another, printing each result as it completes.
+func Run(ctx context.Context, checks []Check) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, check := range checks {
+		spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Checking %s...", check.Name))
+		status, detail := check.Run(ctx)
+		message := fmt.Sprintf("%s: %s", check.Name, detail)
+		switch status {
+		case Pass:
+			spinner.Success(message)
+		case Warn:
+			spinner.Warning(message)
+		default:
+			spinner.Fail(message)
+		}
+		results = append(results, Result{Name: check.Name, Status: status, Detail: detail})
+	}
+	return results
+}
+
+// Failures counts the results that did not pass or warn.
+func Failures(results []Result) int {
+	failed := 0
+	for _, result := range results {
+		if result.Status == Fail {
+			failed++
+		}
+	}
+	return failed
+}
@@ -0,0 +1,75 @@
+package dryrun
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/clouddrove/smurf/internal/output"
+	"github.com/pterm/pterm"
+)
+
+// Action is a side effect a command would have performed had --dry-run not been set.
+type Action struct {
+	Subsystem string `json:"subsystem"`
+	Kind      string `json:"action"`
+	Target    string `json:"target"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+var (
+	enabled bool
+	mu      sync.Mutex
+	planned []Action
+)
+
+var prefix = pterm.Info.WithPrefix(pterm.Prefix{Text: "DRY RUN", Style: pterm.NewStyle(pterm.BgMagenta, pterm.FgBlack)})
+
+// Enable switches every subsystem to reporting its side effects instead of performing them.
+func Enable() {
+	enabled = true
+}
+
+// Enabled reports whether --dry-run was requested.
+func Enabled() bool {
+	return enabled
+}
+
+// Record registers a planned action and prints it as it is encountered.
+func Record(subsystem, kind, target, detail string) {
+	action := Action{Subsystem: subsystem, Kind: kind, Target: target, Detail: detail}
+
+	mu.Lock()
+	planned = append(planned, action)
+	mu.Unlock()
+
+	if detail != "" {
+		prefix.Printf("%s: would %s %s (%s)\n", subsystem, kind, target, detail)
+	} else {
+		prefix.Printf("%s: would %s %s\n", subsystem, kind, target)
+	}
+}
+
+// Actions returns the actions recorded so far in the order they were planned.
+func Actions() []Action {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Action(nil), planned...)
+}
+
+// Report prints a summary table of all planned actions to the progress stream.
+func Report() {
+	actions := Actions()
+	if len(actions) == 0 {
+		pterm.Info.Println("Dry run complete: no changes would be made.")
+		return
+	}
+
+	data := pterm.TableData{{"Subsystem", "Action", "Target", "Detail"}}
+	for _, a := range actions {
+		data = append(data, []string{a.Subsystem, a.Kind, a.Target, a.Detail})
+	}
+
+	fmt.Fprintln(output.Writer())
+	pterm.DefaultTable.WithHasHeader().WithWriter(output.Writer()).WithData(data).Render()
+	pterm.Info.Printf("Dry run complete: %d planned action(s), nothing was changed.\n", len(actions))
+}
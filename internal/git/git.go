@@ -0,0 +1,195 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// TagStrategies lists the strategies accepted by ImageTags.
+var TagStrategies = []string{"sha", "branch", "semver"}
+
+// Info describes the commit checked out in a working tree.
+type Info struct {
+	SHA      string `json:"sha"`
+	ShortSHA string `json:"shortSha"`
+	// Branch is empty for a detached HEAD outside of CI.
+	Branch string `json:"branch,omitempty"`
+	// Version is the semantic version of the nearest tag, as 1.4.0 when HEAD is
+	// tagged and as 1.4.0-3-gabc1234 when it is 3 commits past the tag.
+	Version string `json:"version,omitempty"`
+	// Dirty reports uncommitted changes to tracked files.
+	Dirty bool `json:"dirty"`
+}
+
+// branchEnv are CI variables holding the branch when the checkout is a detached HEAD.
+var branchEnv = []string{"GITHUB_HEAD_REF", "GITHUB_REF_NAME", "CI_COMMIT_REF_NAME", "BRANCH_NAME", "BUILD_SOURCEBRANCHNAME"}
+
+var semverTag = regexp.MustCompile(`^v?(\d+\.\d+\.\d+(?:-[0-9A-Za-z.-]+)?)$`)
+
+// Describe reads the commit, branch, nearest version tag and working tree state of the repository at dir.
+func Describe(ctx context.Context, dir string) (*Info, error) {
+	sha, err := run(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a git repository with commits: %w", dir, err)
+	}
+	info := &Info{SHA: sha}
+	if info.ShortSHA, err = run(ctx, dir, "rev-parse", "--short", "HEAD"); err != nil {
+		return nil, err
+	}
+
+	if branch, err := run(ctx, dir, "rev-parse", "--abbrev-ref", "HEAD"); err == nil && branch != "HEAD" {
+		info.Branch = branch
+	} else {
+		for _, name := range branchEnv {
+			if branch := os.Getenv(name); branch != "" {
+				info.Branch = branch
+				break
+			}
+		}
+	}
+
+	if tag, err := run(ctx, dir, "describe", "--tags", "--abbrev=0", "--match", "*[0-9]*.[0-9]*.[0-9]*"); err == nil {
+		if m := semverTag.FindStringSubmatch(tag); m != nil {
+			info.Version = m[1]
+			if count, err := run(ctx, dir, "rev-list", "--count", tag+"..HEAD"); err == nil && count != "0" {
+				info.Version = fmt.Sprintf("%s-%s-g%s", info.Version, count, info.ShortSHA)
+			}
+		}
+	}
+
+	status, err := run(ctx, dir, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return nil, err
+	}
+	info.Dirty = status != ""
+	return info, nil
+}
+
+// sourceEnv are CI variables holding the web URL of the repository being built.
+var sourceEnv = []string{"CI_PROJECT_URL", "BUILD_REPOSITORY_URI"}
+
+// SourceURL returns the web URL of the repository at dir, from its origin remote
+// or CI variables, without any credentials embedded in the remote URL. It
+// returns an empty string when neither is available.
+func SourceURL(ctx context.Context, dir string) string {
+	if remote, err := run(ctx, dir, "remote", "get-url", "origin"); err == nil && remote != "" {
+		return webURL(remote)
+	}
+	if server, repo := os.Getenv("GITHUB_SERVER_URL"), os.Getenv("GITHUB_REPOSITORY"); server != "" && repo != "" {
+		return server + "/" + repo
+	}
+	for _, name := range sourceEnv {
+		if url := os.Getenv(name); url != "" {
+			return webURL(url)
+		}
+	}
+	return ""
+}
+
+var scpRemote = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+
+// webURL turns a remote such as git@github.com:org/repo.git or
+// https://token@github.com/org/repo.git into https://github.com/org/repo.
+func webURL(remote string) string {
+	url := strings.TrimSuffix(remote, ".git")
+	if i := strings.Index(url, "://"); i >= 0 {
+		scheme, rest := url[:i], url[i+3:]
+		authority, path, _ := strings.Cut(rest, "/")
+		if at := strings.LastIndex(authority, "@"); at >= 0 {
+			rest = authority[at+1:] + "/" + path
+		}
+		if scheme == "ssh" || scheme == "git" {
+			scheme = "https"
+			if host, path, ok := strings.Cut(rest, "/"); ok {
+				rest = strings.SplitN(host, ":", 2)[0] + "/" + path
+			}
+		}
+		return scheme + "://" + rest
+	}
+	if m := scpRemote.FindStringSubmatch(url); m != nil {
+		return "https://" + m[1] + "/" + m[2]
+	}
+	return url
+}
+
+// ImageTags derives image tags from info for each strategy, in order: the
+// short commit SHA, the sanitized branch name or the semantic version of the
+// nearest tag. Every tag gets a -dirty suffix when the working tree has
+// uncommitted changes.
+func ImageTags(info *Info, strategies []string) ([]string, error) {
+	var tags []string
+	for _, strategy := range strategies {
+		var tag string
+		switch strategy {
+		case "sha":
+			tag = info.ShortSHA
+		case "branch":
+			if info.Branch == "" {
+				return nil, fmt.Errorf("tag strategy 'branch' needs a branch, but HEAD is detached")
+			}
+			tag = SanitizeTag(info.Branch)
+		case "semver":
+			if info.Version == "" {
+				return nil, fmt.Errorf("tag strategy 'semver' needs a version tag such as v1.2.3, but none is reachable from HEAD")
+			}
+			tag = info.Version
+		default:
+			return nil, fmt.Errorf("unknown tag strategy '%s' (expected %s)", strategy, strings.Join(TagStrategies, ", "))
+		}
+
+		if info.Dirty {
+			tag = truncate(tag, 128-len("-dirty")) + "-dirty"
+		}
+		if !contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+var invalidTagChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// SanitizeTag turns a branch name such as feature/JIRA-12 into a valid image tag, feature-JIRA-12.
+func SanitizeTag(name string) string {
+	tag := invalidTagChars.ReplaceAllString(name, "-")
+	tag = strings.TrimLeft(tag, ".-")
+	if tag == "" {
+		tag = "unknown"
+	}
+	return truncate(tag, 128)
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func run(ctx context.Context, dir string, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -2,12 +2,15 @@ package helm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/clouddrove/smurf/internal/dryrun"
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/fatih/color"
 	"github.com/pterm/pterm"
 	"helm.sh/helm/v3/pkg/action"
@@ -15,9 +18,9 @@ import (
 	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/cli"
 	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
 	"helm.sh/helm/v3/pkg/strvals"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
 
 	v1 "k8s.io/api/core/v1"
@@ -35,16 +38,52 @@ func init() {
 	}
 }
 
-func getKubeClient() (*kubernetes.Clientset, error) {
-	var kubeconfig string
-	if os.Getenv("KUBECONFIG") != "" {
-		kubeconfig = os.Getenv("KUBECONFIG")
-	} else {
-		home := homedir.HomeDir()
-		kubeconfig = filepath.Join(home, ".kube", "config")
+// SetKubeContext selects the kubeconfig context used by all Helm operations.
+func SetKubeContext(name string) {
+	settings.KubeContext = name
+}
+
+// ClusterInfo describes the cluster Helm operations are sent to.
+type ClusterInfo struct {
+	Kubeconfig string
+	Context    string
+	Server     string
+	Version    string
+}
+
+// Cluster resolves the kubeconfig context Helm uses and asks its API server for the version.
+func Cluster() (*ClusterInfo, error) {
+	info := &ClusterInfo{Kubeconfig: settings.KubeConfig}
+
+	raw, err := settings.RESTClientGetter().ToRawKubeConfigLoader().RawConfig()
+	if err != nil {
+		return info, err
+	}
+	info.Context = raw.CurrentContext
+	if settings.KubeContext != "" {
+		info.Context = settings.KubeContext
+	}
+
+	config, err := settings.RESTClientGetter().ToRESTConfig()
+	if err != nil {
+		return info, err
 	}
+	info.Server = config.Host
 
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return info, err
+	}
+	version, err := clientset.Discovery().ServerVersion()
+	if err != nil {
+		return info, err
+	}
+	info.Version = version.GitVersion
+	return info, nil
+}
+
+func getKubeClient() (*kubernetes.Clientset, error) {
+	config, err := settings.RESTClientGetter().ToRESTConfig()
 	if err != nil {
 		return nil, err
 	}
@@ -80,74 +119,91 @@ func CreateChart(chartName, saveDir string) error {
 	return nil
 }
 
-func HelmInstall(releaseName, chartPath, namespace string) error {
-	settings := cli.New()
-	kubeConfigPath := filepath.Join(os.Getenv("HOME"), ".kube", "config")
-	settings.KubeConfig = kubeConfigPath
+// InstallOptions controls how HelmInstall rolls out a new release.
+type InstallOptions struct {
+	// Atomic uninstalls the release again when the installation fails.
+	Atomic  bool
+	Timeout time.Duration
+}
 
+func HelmInstall(ctx context.Context, releaseName, chartPath, namespace string, values map[string]interface{}, opts InstallOptions) (*release.Release, error) {
 	actionConfig := new(action.Configuration)
 	if err := actionConfig.Init(settings.RESTClientGetter(), namespace, os.Getenv("HELM_DRIVER"), func(format string, v ...interface{}) {
-		fmt.Printf(format, v...)
+		fmt.Fprintf(output.Writer(), format, v...)
 	}); err != nil {
 		color.Red("Failed to initialize Helm action configuration: %v\n", err)
-		return err
+		return nil, err
 	}
 
 	client := action.NewInstall(actionConfig)
 	client.ReleaseName = releaseName
 	client.Namespace = namespace
+	client.Atomic = opts.Atomic
+	client.Timeout = opts.Timeout
+	client.DryRun = dryrun.Enabled()
 
 	chart, err := loader.Load(chartPath)
 	if err != nil {
 		color.Red("Failed to load chart: %v\n", err)
-		return err
+		return nil, err
 	}
 
-	release, err := client.Run(chart, nil) 
+	rel, err := client.RunWithContext(ctx, chart, values)
 	if err != nil {
+		if ctx.Err() != nil {
+			pterm.Warning.Println("Installation interrupted, stopped waiting on release hooks")
+		}
 		color.Red("Installation failed: %v\n", err)
-		return err
+		return nil, err
 	}
 
-	color.Green("NAME: %s\n", release.Name)
-	color.Green("LAST DEPLOYED: %s\n", release.Info.LastDeployed)
-	color.Green("NAMESPACE: %s\n", release.Namespace)
-	color.Green("STATUS: %s\n", release.Info.Status)
-	color.Green("REVISION: %d\n", release.Version)
-	color.Green("NOTES:\n%s\n", release.Info.Notes)
+	if client.DryRun {
+		dryrun.Record("helm", "install", releaseName, fmt.Sprintf("new release of chart %s in namespace %s", chart.Metadata.Name, namespace))
+		printManifest(rel)
+		return rel, nil
+	}
+
+	color.Green("NAME: %s\n", rel.Name)
+	color.Green("LAST DEPLOYED: %s\n", rel.Info.LastDeployed)
+	color.Green("NAMESPACE: %s\n", rel.Namespace)
+	color.Green("STATUS: %s\n", rel.Info.Status)
+	color.Green("REVISION: %d\n", rel.Version)
+	color.Green("NOTES:\n%s\n", redact(rel.Info.Notes))
 
 	color.Cyan("Get the application URL by running these commands:\n")
-	color.Cyan("export POD_NAME=$(kubectl get pods --namespace %s -l \"app.kubernetes.io/name=%s,app.kubernetes.io/instance=%s\" -o jsonpath=\"{.items[0].metadata.name}\")\n", namespace, chart.Metadata.Name, release.Name)
+	color.Cyan("export POD_NAME=$(kubectl get pods --namespace %s -l \"app.kubernetes.io/name=%s,app.kubernetes.io/instance=%s\" -o jsonpath=\"{.items[0].metadata.name}\")\n", namespace, chart.Metadata.Name, rel.Name)
 	color.Cyan("export CONTAINER_PORT=$(kubectl get pod --namespace %s $POD_NAME -o jsonpath=\"{.spec.containers[0].ports[0].containerPort}\")\n", namespace)
 	color.Cyan("echo \"Visit http://127.0.0.1:8080 to use your application\"\n")
 	color.Cyan("kubectl --namespace %s port-forward $POD_NAME 8080:$CONTAINER_PORT\n", namespace)
 
-	return nil
+	return rel, nil
 }
 
 
 // ensureNamespace checks and creates the namespace if necessary
-func ensureNamespace(namespace string, create bool) error {
+func ensureNamespace(ctx context.Context, namespace string, create bool) error {
     clientset, err := getKubeClient()
     if err != nil {
         return err
     }
-    _, err = clientset.CoreV1().Namespaces().Get(context.Background(), namespace, metav1.GetOptions{})
+    _, err = clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
     if err == nil {
         return nil 
     }
 
-    if create {
+    if create && dryrun.Enabled() {
+        dryrun.Record("helm", "create namespace", namespace, "")
+    } else if create {
         ns := &v1.Namespace{
             ObjectMeta: metav1.ObjectMeta{
                 Name: namespace,
             },
         }
-        _, err = clientset.CoreV1().Namespaces().Create(context.Background(), ns, metav1.CreateOptions{})
+        _, err = clientset.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{})
         if err != nil {
             return fmt.Errorf("Failed to create namespace '%s': %v", namespace, err)
         }
-        fmt.Println("Namespace created successfully")
+        fmt.Fprintln(output.Writer(), "Namespace created successfully")
     } else {
         return fmt.Errorf("Namespace '%s' does not exist and was not created", namespace)
     }
@@ -155,83 +211,144 @@ func ensureNamespace(namespace string, create bool) error {
     return nil
 }
 
-func HelmUpgrade(releaseName, chartPath, namespace string, setValues []string, valuesFiles []string, createNamespace, atomic bool, timeout time.Duration, debug bool) error {
-	settings := cli.New()
-	settings.Debug = debug
+// UpgradeOptions controls how HelmUpgrade merges values and rolls out a release.
+type UpgradeOptions struct {
+	// ValuesFiles are merged in order, then Values, then SetValues and
+	// SetStringValues, each overriding the ones before.
+	ValuesFiles     []string
+	Values          map[string]interface{}
+	SetValues       []string
+	SetStringValues []string
+	CreateNamespace bool
+	// Atomic rolls a failed upgrade back, or uninstalls a failed installation.
+	Atomic bool
+	// Install installs the release with the same values when it does not exist yet.
+	Install bool
+	Timeout time.Duration
+	Debug   bool
+}
+
+// HelmUpgrade upgrades a release to chartPath with the values of opts.
+func HelmUpgrade(ctx context.Context, releaseName, chartPath, namespace string, opts UpgradeOptions) (*release.Release, error) {
+	settings.Debug = opts.Debug
 	spinner, _ := pterm.DefaultSpinner.Start("Upgrading release...")
 
-	if createNamespace {
-		if err := ensureNamespace(namespace, true); err != nil {
+	if opts.CreateNamespace {
+		if err := ensureNamespace(ctx, namespace, true); err != nil {
 			spinner.Fail("Failed to ensure namespace: " + err.Error())
 			color.Red("Error: %v\n", err)
-			return err
+			return nil, err
 		}
 	}
 
 
 	actionConfig := new(action.Configuration)
 	if err := actionConfig.Init(settings.RESTClientGetter(), namespace, os.Getenv("HELM_DRIVER"), func(format string, v ...interface{}) {
-		fmt.Printf(format, v...)
+		fmt.Fprintf(output.Writer(), format, v...)
 	}); err != nil {
 		spinner.Fail("Failed to initialize Helm action configuration: " + err.Error())
 		color.Red("Error: %v\n", err)
-		return err
+		return nil, err
 	}
 
 	client := action.NewUpgrade(actionConfig)
 	client.Namespace = namespace
-	client.Atomic = atomic
-	client.Timeout = timeout
+	client.Atomic = opts.Atomic
+	client.Timeout = opts.Timeout
+	client.DryRun = dryrun.Enabled()
 
 	chart, err := loader.Load(chartPath)
 	if err != nil {
 		spinner.Fail("Failed to load chart: " + err.Error())
 		color.Red("Error: %v\n", err)
-		return err
+		return nil, err
 	}
 
 
 	vals := make(map[string]interface{})
-	for _, f := range valuesFiles {
+	for _, f := range opts.ValuesFiles {
 		additionalVals, err := chartutil.ReadValuesFile(f)
 		if err != nil {
 			spinner.Fail(fmt.Sprintf("Failed to read values file: %s", f))
 			color.Red("Error reading values file %s: %v\n", f, err)
-			return err
+			return nil, err
 		}
 		for key, value := range additionalVals {
 			vals[key] = value
 		}
 	}
 
-	for _, set := range setValues {
+	mergeValues(vals, opts.Values)
+
+	for _, set := range opts.SetValues {
 		if err := strvals.ParseInto(set, vals); err != nil {
 			spinner.Fail("Failed to parse set values: " + err.Error())
 			color.Red("Error: %v\n", err)
-			return err
+			return nil, err
+		}
+	}
+
+	for _, set := range opts.SetStringValues {
+		if err := strvals.ParseIntoString(set, vals); err != nil {
+			spinner.Fail("Failed to parse set-string values: " + err.Error())
+			color.Red("Error: %v\n", err)
+			return nil, err
 		}
 	}
 
-	rel, err := client.Run(releaseName, chart, vals)
+	if opts.Install {
+		history := action.NewHistory(actionConfig)
+		history.Max = 1
+		_, err := history.Run(releaseName)
+		if errors.Is(err, driver.ErrReleaseNotFound) {
+			spinner.Info(fmt.Sprintf("Release '%s' does not exist yet, installing it", releaseName))
+			return HelmInstall(ctx, releaseName, chartPath, namespace, vals, InstallOptions{Atomic: opts.Atomic, Timeout: opts.Timeout})
+		}
+		if err != nil {
+			spinner.Fail("Failed to read the release history: " + err.Error())
+			color.Red("Error: %v\n", err)
+			return nil, err
+		}
+	}
+
+	rel, err := client.RunWithContext(ctx, releaseName, chart, vals)
 	if err != nil {
+		if ctx.Err() != nil {
+			pterm.Warning.Println("Upgrade interrupted, stopped waiting on release hooks")
+		}
 		spinner.Fail("Upgrade failed: " + err.Error())
 		color.Red("Error: %v\n", err)
-		return err
+		return nil, err
+	}
+
+	if client.DryRun {
+		spinner.Stop()
+		dryrun.Record("helm", "upgrade", releaseName, fmt.Sprintf("existing release to revision %d of chart %s in namespace %s", rel.Version, chart.Metadata.Name, namespace))
+		printManifest(rel)
+		return rel, nil
 	}
 
 	spinner.Success(fmt.Sprintf("Release '%s' upgraded successfully in namespace '%s'", releaseName, namespace))
+	printRelease(rel)
+	return rel, nil
+}
+
+// printRelease prints the status and notes of a release after an upgrade.
+func printRelease(rel *release.Release) {
 	color.Green("NAME: %s\n", rel.Name)
 	color.Green("LAST DEPLOYED: %s\n", rel.Info.LastDeployed)
 	color.Green("NAMESPACE: %s\n", rel.Namespace)
 	color.Green("STATUS: %s\n", rel.Info.Status.String())
 	color.Green("REVISION: %d\n", rel.Version)
-	color.Green("NOTES:\n%s\n", rel.Info.Notes)
-
-	return nil
+	color.Green("NOTES:\n%s\n", redact(rel.Info.Notes))
 }
 
-func HelmList(namespace string) ([]*release.Release, error) {
-	settings := cli.New()
+// HelmList lists the releases of all namespaces. Helm cannot cancel the
+// listing itself, so ctx is checked around it.
+func HelmList(ctx context.Context, namespace string) ([]*release.Release, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("listing releases cancelled: %w", err)
+	}
 	actionConfig := new(action.Configuration)
 	spinner, _ := pterm.DefaultSpinner.Start("Listing releases in namespace: " + namespace)
 
@@ -250,9 +367,13 @@ func HelmList(namespace string) ([]*release.Release, error) {
 		color.Red("Error: %s", err.Error())
 		return nil, err
 	}
+	if ctx.Err() != nil {
+		spinner.Fail("Listing releases cancelled")
+		return nil, fmt.Errorf("listing releases cancelled: %w", ctx.Err())
+	}
 
 	spinner.Stop()
-	fmt.Println()
+	fmt.Fprintln(output.Writer())
 	color.Cyan("%-17s %-10s %-8s %-20s %-7s %-30s", "NAME", "NAMESPACE", "REVISION", "UPDATED", "STATUS", "CHART")
 	for _, rel := range releases {
 		updatedStr := rel.Info.LastDeployed.Local().Format("2006-01-02 15:04:05.999999999 -0700 MST")
@@ -263,12 +384,19 @@ func HelmList(namespace string) ([]*release.Release, error) {
 	return releases, nil
 }
 
-func HelmUninstall(releaseName, namespace string) error {
+// HelmUninstall uninstalls a release. Helm cannot cancel an uninstall once it
+// started, so ctx is checked before it and reported after it.
+func HelmUninstall(ctx context.Context, releaseName, namespace string) error {
+	if dryrun.Enabled() {
+		dryrun.Record("helm", "uninstall", releaseName, "namespace "+namespace)
+		return nil
+	}
+
 	spinner, _ := pterm.DefaultSpinner.Start(fmt.Sprintf("Uninstalling release '%s'", releaseName))
 
 	actionConfig := new(action.Configuration)
 	debugLog := func(format string, v ...interface{}) {
-		fmt.Printf(format, v...)
+		fmt.Fprintf(output.Writer(), format, v...)
 	}
 	if err := actionConfig.Init(settings.RESTClientGetter(), namespace, os.Getenv("HELM_DRIVER"), debugLog); err != nil {
 		spinner.Fail("Failed to initialize Helm action configuration: " + err.Error())
@@ -282,43 +410,51 @@ func HelmUninstall(releaseName, namespace string) error {
 		return fmt.Errorf("failed to create Helm uninstall client")
 	}
 
+	if ctx.Err() != nil {
+		spinner.Fail("Uninstall cancelled")
+		return fmt.Errorf("uninstall cancelled: %w", ctx.Err())
+	}
 	_, err := client.Run(releaseName)
 	if err != nil {
 		spinner.Fail("Uninstall failed: " + err.Error())
 		return err
 	}
+	if ctx.Err() != nil {
+		pterm.Warning.Println("Interrupted after the release was uninstalled")
+	}
 
 	spinner.Success(fmt.Sprintf("Release '%s' uninstalled successfully", releaseName))
 	return nil
 }
 
-// HelmLint lints a Helm chart
-func HelmLint(chartPath string) error {
+// HelmLint lints a Helm chart and returns the reported issues
+func HelmLint(chartPath string) ([]string, error) {
 	spinner, _ := pterm.DefaultSpinner.Start("Linting chart")
 	actionConfig := new(action.Configuration)
 	_ = actionConfig
 	client := action.NewLint()
 
 	result := client.Run([]string{chartPath}, nil)
+	messages := make([]string, 0, len(result.Messages))
 	if len(result.Messages) > 0 {
 		for _, msg := range result.Messages {
-			fmt.Println(msg)
+			fmt.Fprintln(output.Writer(), msg)
+			messages = append(messages, msg.Error())
 		}
 		spinner.Fail("Linting issues found")
 	} else {
 		spinner.Success("No linting issues")
 	}
-	return nil
+	return messages, nil
 }
 
-// HelmTemplate renders the Helm templates for a given chart
-func HelmTemplate(releaseName, chartPath, namespace string) error {
-	settings := cli.New() 
+// HelmTemplate renders the Helm templates for a given chart and returns the manifest
+func HelmTemplate(ctx context.Context, releaseName, chartPath, namespace string) (string, error) {
 	actionConfig := new(action.Configuration)
 
 	if err := actionConfig.Init(settings.RESTClientGetter(), namespace, os.Getenv("HELM_DRIVER"), nil); err != nil {
 		pterm.DefaultBasicText.WithStyle(pterm.NewStyle(pterm.FgRed)).Println(err.Error())
-		return err
+		return "", err
 	}
 
 	client := action.NewInstall(actionConfig)
@@ -332,38 +468,38 @@ func HelmTemplate(releaseName, chartPath, namespace string) error {
 	chart, err := loader.Load(chartPath)
 	if err != nil {
 		pterm.DefaultBasicText.WithStyle(pterm.NewStyle(pterm.FgRed)).Println(err.Error())
-		return err
+		return "", err
 	}
 
 	spinner, _ := pterm.DefaultSpinner.Start("Rendering Helm templates...")
-	rel, err := client.Run(chart, nil) 
+	rel, err := client.RunWithContext(ctx, chart, nil)
 	if err != nil {
 		spinner.Fail(err.Error())
-		return err
+		return "", err
 	}
 	spinner.Success("Templates rendered successfully")
 
 	green := color.New(color.FgGreen).SprintFunc()
-	fmt.Println(green(rel.Manifest))
+	fmt.Fprintln(output.Writer(), green(rel.Manifest))
 
-	return nil
+	return rel.Manifest, nil
 }
 
 // HelmProvision provisions a Helm chart by installing or upgrading it, linting it, and rendering its templates
-func HelmProvision(releaseName, chartPath, namespace string) error {
-	settings := cli.New()
+func HelmProvision(ctx context.Context, releaseName, chartPath, namespace string) (*release.Release, error) {
 	actionConfig := new(action.Configuration)
 	if err := actionConfig.Init(settings.RESTClientGetter(), namespace, os.Getenv("HELM_DRIVER"), nil); err != nil {
-		return err
+		return nil, err
 	}
 
 	client := action.NewList(actionConfig)
 	results, err := client.Run()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var wg sync.WaitGroup
+	var rel *release.Release
 	var installErr, upgradeErr, lintErr, templateErr error
 
 	exists := false
@@ -378,24 +514,24 @@ func HelmProvision(releaseName, chartPath, namespace string) error {
 	if exists {
 		go func() {
 			defer wg.Done()
-			upgradeErr = HelmUpgrade(releaseName, chartPath, namespace, nil, nil, false, false, 0, false)
+			rel, upgradeErr = HelmUpgrade(ctx, releaseName, chartPath, namespace, UpgradeOptions{})
 		}()
 	} else {
 		go func() {
 			defer wg.Done()
-			installErr = HelmInstall(releaseName, chartPath, namespace)
+			rel, installErr = HelmInstall(ctx, releaseName, chartPath, namespace, nil, InstallOptions{})
 		}()
 	}
 
 	wg.Add(2)
 	go func() {
 		defer wg.Done()
-		lintErr = HelmLint(chartPath)
+		_, lintErr = HelmLint(chartPath)
 	}()
 
 	go func() {
 		defer wg.Done()
-		templateErr = HelmTemplate(releaseName, chartPath, namespace)
+		_, templateErr = HelmTemplate(ctx, releaseName, chartPath, namespace)
 	}()
 
 	wg.Wait()
@@ -413,11 +549,11 @@ func HelmProvision(releaseName, chartPath, namespace string) error {
 		if templateErr != nil {
 			pterm.Error.Println("Template rendering failed:", templateErr)
 		}
-		return fmt.Errorf(color.RedString("Provisioning failed"))
+		return nil, fmt.Errorf("%s", color.RedString("Provisioning failed"))
 	}
 
 	pterm.Success.Println("Provisioning completed successfully.")
-	return nil
+	return rel, nil
 }
 
 
@@ -427,13 +563,6 @@ func HelmProvision(releaseName, chartPath, namespace string) error {
 
 // HelmReleaseExists checks if a specific release exists in the given namespace
 func HelmReleaseExists(releaseName, namespace string) (bool, error) {
-    settings := cli.New() 
-
-    if settings.KubeConfig == "" {
-        kubeConfigPath := filepath.Join(homedir.HomeDir(), ".kube", "config")
-        settings.KubeConfig = kubeConfigPath
-    }
-
     actionConfig := new(action.Configuration)
     if err := actionConfig.Init(settings.RESTClientGetter(), namespace, "secrets", nil); err != nil {
         return false, err
@@ -460,48 +589,97 @@ func HelmReleaseExists(releaseName, namespace string) (bool, error) {
 
 
 // HelmStatus retrieves the status of a Helm release
-func HelmStatus(releaseName, namespace string) error {
-    settings := cli.New()
-
+func HelmStatus(ctx context.Context, releaseName, namespace string) (*release.Release, error) {
+    if err := ctx.Err(); err != nil {
+        return nil, fmt.Errorf("status cancelled: %w", err)
+    }
     actionConfig := new(action.Configuration)
 	if err := actionConfig.Init(settings.RESTClientGetter(), namespace, "secrets", func(format string, v ...interface{}) {
 		if settings.Debug {
-			fmt.Printf(format, v...)
+			fmt.Fprintf(output.Writer(), format, v...)
 		}
 	}); err != nil {
-        return fmt.Errorf("failed to initialize Helm client: %w", err)
+        return nil, fmt.Errorf("failed to initialize Helm client: %w", err)
     }
 
     statusAction := action.NewStatus(actionConfig)
 
-    release, err := statusAction.Run(releaseName)
+    rel, err := statusAction.Run(releaseName)
     if err != nil {
         pterm.Error.Println("Retrieving status failed")
         color.Red("Error: %s", err.Error())
-        return err
+        return nil, err
+    }
+    if err := ctx.Err(); err != nil {
+        return nil, fmt.Errorf("status cancelled: %w", err)
     }
 
     data := [][]string{
-        {"NAME", release.Name},
-        {"NAMESPACE", release.Namespace},
-        {"STATUS", release.Info.Status.String()},
-        {"REVISION", fmt.Sprintf("%d", release.Version)},
+        {"NAME", rel.Name},
+        {"NAMESPACE", rel.Namespace},
+        {"STATUS", rel.Info.Status.String()},
+        {"REVISION", fmt.Sprintf("%d", rel.Version)},
         {"TEST SUITE", "None"}, 
     }
 
     pterm.DefaultTable.WithHasHeader(false).WithData(data).Render()
 
-    if release.Info.Notes != "" {
+    if rel.Info.Notes != "" {
         pterm.Info.Println("NOTES:")
-        fmt.Println(color.CyanString(release.Info.Notes))
-        fmt.Println(color.GreenString("Get the application URL by running these commands:"))
-        fmt.Println(color.GreenString("export POD_NAME=$(kubectl get pods --namespace " + release.Namespace + " -l \"app.kubernetes.io/name=" + release.Chart.Metadata.Name + ",app.kubernetes.io/instance=" + release.Name + "\" -o jsonpath=\"{.items[0].metadata.name}\")"))
-        fmt.Println(color.GreenString("export CONTAINER_PORT=$(kubectl get pod --namespace " + release.Namespace + " $POD_NAME -o jsonpath=\"{.spec.containers[0].ports[0].containerPort}\")"))
-        fmt.Println(color.GreenString("echo \"Visit http://127.0.0.1:8080 to use your application\""))
-        fmt.Println(color.GreenString("kubectl --namespace " + release.Namespace + " port-forward $POD_NAME 8080:$CONTAINER_PORT"))
+        fmt.Fprintln(output.Writer(), color.CyanString(rel.Info.Notes))
+        fmt.Fprintln(output.Writer(), color.GreenString("Get the application URL by running these commands:"))
+        fmt.Fprintln(output.Writer(), color.GreenString("export POD_NAME=$(kubectl get pods --namespace " + rel.Namespace + " -l \"app.kubernetes.io/name=" + rel.Chart.Metadata.Name + ",app.kubernetes.io/instance=" + rel.Name + "\" -o jsonpath=\"{.items[0].metadata.name}\")"))
+        fmt.Fprintln(output.Writer(), color.GreenString("export CONTAINER_PORT=$(kubectl get pod --namespace " + rel.Namespace + " $POD_NAME -o jsonpath=\"{.spec.containers[0].ports[0].containerPort}\")"))
+        fmt.Fprintln(output.Writer(), color.GreenString("echo \"Visit http://127.0.0.1:8080 to use your application\""))
+        fmt.Fprintln(output.Writer(), color.GreenString("kubectl --namespace " + rel.Namespace + " port-forward $POD_NAME 8080:$CONTAINER_PORT"))
     } else {
         pterm.Warning.Println(color.YellowString("No additional notes provided for this release."))
     }
 
-    return nil
-}
\ No newline at end of file
+    return rel, nil
+}
+// printManifest shows the manifest a dry-run install or upgrade rendered.
+func printManifest(rel *release.Release) {
+	pterm.Info.Printf("Rendered manifest for release '%s':\n", rel.Name)
+	fmt.Fprintln(output.Writer(), redact(rel.Manifest))
+}
+
+// ReleaseSummary is the machine-readable view of a Helm release
+type ReleaseSummary struct {
+	Name       string    `json:"name"`
+	Namespace  string    `json:"namespace"`
+	Revision   int       `json:"revision"`
+	Updated    time.Time `json:"updated"`
+	Status     string    `json:"status"`
+	Chart      string    `json:"chart"`
+	AppVersion string    `json:"appVersion,omitempty"`
+	Notes      string    `json:"notes,omitempty"`
+}
+
+// Summarize converts a Helm release into a ReleaseSummary
+func Summarize(rel *release.Release) ReleaseSummary {
+	summary := ReleaseSummary{
+		Name:      rel.Name,
+		Namespace: rel.Namespace,
+		Revision:  rel.Version,
+	}
+	if rel.Info != nil {
+		summary.Updated = rel.Info.LastDeployed.Time
+		summary.Status = rel.Info.Status.String()
+		summary.Notes = redact(rel.Info.Notes)
+	}
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		summary.Chart = rel.Chart.Metadata.Name + "-" + rel.Chart.Metadata.Version
+		summary.AppVersion = rel.Chart.Metadata.AppVersion
+	}
+	return summary
+}
+
+// SummarizeAll converts a list of Helm releases into summaries
+func SummarizeAll(releases []*release.Release) []ReleaseSummary {
+	summaries := make([]ReleaseSummary, 0, len(releases))
+	for _, rel := range releases {
+		summaries = append(summaries, Summarize(rel))
+	}
+	return summaries
+}
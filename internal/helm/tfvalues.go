@@ -0,0 +1,148 @@
+package helm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/clouddrove/smurf/internal/terraform"
+	"github.com/pterm/pterm"
+)
+
+// sensitiveValues holds the sensitive Terraform outputs passed into a release so
+// they can be masked in anything this package prints.
+var (
+	sensitiveMu     sync.Mutex
+	sensitiveValues []string
+)
+
+// TerraformValues reads the outputs of the Terraform configuration in dir and places
+// them at Helm value paths. Each mapping has the form "output_name=value.path".
+// Sensitive outputs are passed through but never printed.
+func TerraformValues(ctx context.Context, dir string, mappings []string) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	if len(mappings) == 0 {
+		return values, nil
+	}
+	if dir == "" {
+		dir = "."
+	}
+
+	paths := make(map[string]string, len(mappings))
+	for _, mapping := range mappings {
+		name, path, ok := strings.Cut(mapping, "=")
+		name, path = strings.TrimSpace(name), strings.TrimSpace(path)
+		if !ok || name == "" || path == "" {
+			return nil, fmt.Errorf("invalid Terraform output mapping '%s' (expected output_name=value.path)", mapping)
+		}
+		paths[name] = path
+	}
+
+	pterm.Info.Printf("Reading Terraform outputs from %s...\n", dir)
+	outputs, err := terraform.ReadOutputs(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(paths))
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := paths[name]
+		out, ok := outputs[name]
+		if !ok {
+			return nil, fmt.Errorf("Terraform output '%s' not found in %s", name, dir)
+		}
+
+		var value interface{}
+		if err := json.Unmarshal(out.Value, &value); err != nil {
+			return nil, fmt.Errorf("failed to decode Terraform output '%s': %w", name, err)
+		}
+		if err := setValuePath(values, path, value); err != nil {
+			return nil, err
+		}
+
+		if out.Sensitive {
+			registerSensitive(value)
+			pterm.Info.Printf("Setting %s from Terraform output %s (sensitive value hidden)\n", path, name)
+		} else {
+			pterm.Info.Printf("Setting %s from Terraform output %s\n", path, name)
+		}
+	}
+
+	return values, nil
+}
+
+// setValuePath stores value in vals at a dotted path, creating nested maps as needed.
+func setValuePath(vals map[string]interface{}, path string, value interface{}) error {
+	keys := strings.Split(path, ".")
+	current := vals
+	for i, key := range keys {
+		if key == "" {
+			return fmt.Errorf("invalid value path '%s'", path)
+		}
+		if i == len(keys)-1 {
+			current[key] = value
+			return nil
+		}
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			current[key] = next
+		}
+		current = next
+	}
+	return nil
+}
+
+// mergeValues deep merges src into dst, with src winning on conflicts.
+func mergeValues(dst, src map[string]interface{}) {
+	for key, value := range src {
+		srcMap, srcIsMap := value.(map[string]interface{})
+		dstMap, dstIsMap := dst[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			mergeValues(dstMap, srcMap)
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+// registerSensitive records every scalar inside value for masking.
+func registerSensitive(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, item := range v {
+			registerSensitive(item)
+		}
+	case []interface{}:
+		for _, item := range v {
+			registerSensitive(item)
+		}
+	case nil:
+	default:
+		s := fmt.Sprint(v)
+		if s == "" {
+			return
+		}
+		sensitiveMu.Lock()
+		sensitiveValues = append(sensitiveValues, s)
+		sensitiveMu.Unlock()
+	}
+}
+
+// redact masks sensitive Terraform outputs in text that is about to be printed.
+func redact(text string) string {
+	sensitiveMu.Lock()
+	defer sensitiveMu.Unlock()
+	for _, value := range sensitiveValues {
+		text = strings.ReplaceAll(text, value, "[sensitive]")
+	}
+	return text
+}
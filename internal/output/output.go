@@ -0,0 +1,74 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/pterm/pterm"
+	"sigs.k8s.io/yaml"
+)
+
+// Format selects how commands report their results.
+type Format string
+
+const (
+	Text Format = "text"
+	JSON Format = "json"
+	YAML Format = "yaml"
+)
+
+var current = Text
+
+// SetFormat selects the output format. In JSON and YAML mode all progress
+// output is moved to stderr so that stdout only carries the result document.
+func SetFormat(name string) error {
+	switch Format(name) {
+	case Text, "":
+		current = Text
+	case JSON, YAML:
+		current = Format(name)
+		pterm.SetDefaultOutput(os.Stderr)
+		color.Output = os.Stderr
+	default:
+		return fmt.Errorf("unsupported output format '%s' (expected json, yaml or text)", name)
+	}
+	return nil
+}
+
+// Structured reports whether a machine-readable format was selected.
+func Structured() bool {
+	return current != Text
+}
+
+// Writer returns the stream human-readable output should be written to.
+func Writer() *os.File {
+	if Structured() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
+
+// Print writes result to stdout as a single JSON or YAML document.
+// It does nothing in text mode, where commands print their own output.
+func Print(result interface{}) error {
+	var data []byte
+	var err error
+
+	switch current {
+	case JSON:
+		data, err = json.MarshalIndent(result, "", "  ")
+		data = append(data, '\n')
+	case YAML:
+		data, err = yaml.Marshal(result)
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode %s output: %w", current, err)
+	}
+
+	_, err = os.Stdout.Write(data)
+	return err
+}
@@ -0,0 +1,49 @@
+package terraform
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// interruptTerraform sends SIGINT to the Terraform processes started by smurf.
+// terraform-exec starts them in their own process group on Linux, so they do
+// not see the Ctrl-C sent to smurf by the terminal.
+func interruptTerraform() {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return
+	}
+
+	self := os.Getpid()
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		stat, err := os.ReadFile("/proc/" + entry.Name() + "/stat")
+		if err != nil {
+			continue
+		}
+
+		// The format is "pid (comm) state ppid ..."; comm may contain spaces.
+		start := strings.IndexByte(string(stat), '(')
+		end := strings.LastIndexByte(string(stat), ')')
+		if start < 0 || end < start {
+			continue
+		}
+		fields := strings.Fields(string(stat[end+1:]))
+		if len(fields) < 2 {
+			continue
+		}
+		ppid, err := strconv.Atoi(fields[1])
+		if err != nil || ppid != self {
+			continue
+		}
+		if string(stat[start+1:end]) == "terraform" {
+			syscall.Kill(pid, syscall.SIGINT)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package terraform
+
+// interruptTerraform is a no-op outside Linux: Terraform runs in the same
+// process group as smurf and receives the terminal's interrupt directly.
+func interruptTerraform() {}
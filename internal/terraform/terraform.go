@@ -3,18 +3,28 @@ package terraform
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 
 	"github.com/clouddrove/smurf/configs"
+	"github.com/clouddrove/smurf/internal/dryrun"
+	"github.com/clouddrove/smurf/internal/output"
 	"github.com/fatih/color"
 	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
 	"github.com/pterm/pterm"
 )
 
 // getTerraform locates the Terraform binary and initializes a Terraform instance
 func getTerraform() (*tfexec.Terraform, error) {
+	return getTerraformIn(".")
+}
+
+// getTerraformIn initializes a Terraform instance for the configuration in dir
+func getTerraformIn(dir string) (*tfexec.Terraform, error) {
 	// Attempt to find the Terraform binary in the system's PATH
 	terraformBinary, err := exec.LookPath("terraform")
 	if err != nil {
@@ -23,7 +33,7 @@ func getTerraform() (*tfexec.Terraform, error) {
 	}
 
 	// Create a new Terraform instance using the found binary
-	tf, err := tfexec.NewTerraform(".", terraformBinary)
+	tf, err := tfexec.NewTerraform(dir, terraformBinary)
 	if err != nil {
 		pterm.Error.Printf("Error creating Terraform instance: %v\n", err)
 		return nil, err
@@ -33,16 +43,56 @@ func getTerraform() (*tfexec.Terraform, error) {
 	return tf, nil
 }
 
-// Init initializes Terraform
-func Init() error {
+// runInterruptible runs a Terraform command that must not be killed mid-operation.
+// When ctx is cancelled Terraform receives an interrupt instead, so it can stop
+// at a safe point and release the state lock before fn returns.
+func runInterruptible(ctx context.Context, fn func(runCtx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			pterm.Warning.Println("Interrupting Terraform, waiting for it to release the state lock...")
+			interruptTerraform()
+		case <-done:
+		}
+	}()
+
+	err := fn(context.WithoutCancel(ctx))
+	if ctx.Err() != nil {
+		if err != nil {
+			return fmt.Errorf("terraform interrupted: %w", err)
+		}
+		return ctx.Err()
+	}
+	return err
+}
+
+// Init initializes Terraform and upgrades its providers and modules. A dry run
+// records the upgrade and only installs what is missing, so that a plan can
+// still run without touching the dependency lock file.
+func Init(ctx context.Context) error {
 	tf, err := getTerraform()
 	if err != nil {
 		return err
 	}
 
+	upgrade := !dryrun.Enabled()
+	if !upgrade {
+		dir, _ := os.Getwd()
+		dryrun.Record("terraform", "init -upgrade", dir, "providers and modules would be upgraded; only missing ones are installed")
+	}
+
 	pterm.Info.Println("Initializing Terraform...")
 	spinner, _ := pterm.DefaultSpinner.Start("Running terraform init")
-	err = tf.Init(context.Background(), tfexec.Upgrade(true))
+	err = runInterruptible(ctx, func(runCtx context.Context) error {
+		return tf.Init(runCtx, tfexec.Upgrade(upgrade))
+	})
 	if err != nil {
 		spinner.Fail("Terraform init failed")
 		pterm.Error.Printf("Terraform init failed: %v\n", err)
@@ -55,21 +105,25 @@ func Init() error {
 }
 
 // Validate checks the validity of the Terraform configuration
-func Validate() error {
+func Validate(ctx context.Context) (*tfjson.ValidateOutput, error) {
 	tf, err := getTerraform()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	pterm.Info.Println("Validating Terraform configuration...")
 	spinner, _ := pterm.DefaultSpinner.Start("Running terraform validate")
 
 	// Run the validate command
-	valid, err := tf.Validate(context.Background())
+	var valid *tfjson.ValidateOutput
+	err = runInterruptible(ctx, func(runCtx context.Context) error {
+		valid, err = tf.Validate(runCtx)
+		return err
+	})
 	if err != nil {
 		spinner.Fail("Terraform validation failed")
 		pterm.Error.Printf("Terraform validation failed: %v\n", err)
-		return err
+		return nil, err
 	}
 
 	if valid.Valid {
@@ -78,14 +132,15 @@ func Validate() error {
 		spinner.Fail("Terraform configuration is invalid.")
 	}
 
-	return nil
+	return valid, nil
 }
 
-// Plan runs 'terraform plan' and outputs the plan to the console
-func Plan(varNameValue string, varFile string) error {
+// Plan runs 'terraform plan' and outputs the plan to the console.
+// It reports whether the plan contains any changes.
+func Plan(ctx context.Context, vars []string, varFiles []string) (bool, error) {
 	tf, err := getTerraform()
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	// Create a buffer to store the output
@@ -94,7 +149,7 @@ func Plan(varNameValue string, varFile string) error {
 	// Set up custom writer
 	customWriter := &configs.CustomColorWriter{
 		Buffer: &outputBuffer,
-		Writer: os.Stdout,
+		Writer: output.Writer(),
 	}
 
 	// Set the stdout and stderr
@@ -104,43 +159,61 @@ func Plan(varNameValue string, varFile string) error {
 	pterm.Info.Println("Running Terraform plan...")
 	spinner, _ := pterm.DefaultSpinner.Start("Running terraform plan")
 
-	if varNameValue != "" {
-		pterm.Info.Printf("Setting variable: %s\n", varNameValue)
-		tf.Plan(context.Background(), tfexec.Var(varNameValue))
+	planOptions := []tfexec.PlanOption{}
+	for _, v := range vars {
+		pterm.Info.Printf("Setting variable: %s\n", v)
+		planOptions = append(planOptions, tfexec.Var(v))
 	}
-
-	if varFile != "" {
-		pterm.Info.Printf("Setting variable file: %s\n", varFile)
-		_, err = tf.Plan(context.Background(), tfexec.VarFile(varFile))
-		if err != nil {
-			spinner.Fail("Terraform plan failed")
-			pterm.Error.Printf("Terraform plan failed: %v\n", err)
-			return err
-		}
+	for _, f := range varFiles {
+		pterm.Info.Printf("Setting variable file: %s\n", f)
+		planOptions = append(planOptions, tfexec.VarFile(f))
 	}
 
 	// Run the plan and output to console
-	_, err = tf.Plan(context.Background())
+	var hasChanges bool
+	err = runInterruptible(ctx, func(runCtx context.Context) error {
+		hasChanges, err = tf.Plan(runCtx, planOptions...)
+		return err
+	})
 	if err != nil {
 		spinner.Fail("Terraform plan failed")
 		pterm.Error.Printf("Terraform plan failed: %v\n", err)
-		return err
+		return false, err
 	}
 	spinner.Success("Terraform plan completed successfully")
 
-	return nil
+	return hasChanges, nil
 }
 
 // Apply executes 'terraform apply' to apply the planned changes
-func Apply() error {
+func Apply(ctx context.Context, vars []string, varFiles []string) error {
 	tf, err := getTerraform()
 	if err != nil {
 		return err
 	}
 
+	applyOptions := []tfexec.ApplyOption{}
+	for _, v := range vars {
+		applyOptions = append(applyOptions, tfexec.Var(v))
+	}
+	for _, f := range varFiles {
+		applyOptions = append(applyOptions, tfexec.VarFile(f))
+	}
+
+	if dryrun.Enabled() {
+		hasChanges, err := Plan(ctx, vars, varFiles)
+		if err != nil {
+			return err
+		}
+		RecordPlan("apply", hasChanges)
+		return nil
+	}
+
 	pterm.Info.Println("Applying Terraform changes...")
 	spinner, _ := pterm.DefaultSpinner.Start("Running terraform apply")
-	err = tf.Apply(context.Background())
+	err = runInterruptible(ctx, func(runCtx context.Context) error {
+		return tf.Apply(runCtx, applyOptions...)
+	})
 	if err != nil {
 		spinner.Fail("Terraform apply failed")
 		pterm.Error.Printf("Terraform apply failed: %v\n", err)
@@ -152,15 +225,49 @@ func Apply() error {
 }
 
 // Destroy removes all resources managed by Terraform
-func Destroy() error {
+func Destroy(ctx context.Context, vars []string, varFiles []string) error {
 	tf, err := getTerraform()
 	if err != nil {
 		return err
 	}
 
+	destroyOptions := []tfexec.DestroyOption{}
+	for _, v := range vars {
+		destroyOptions = append(destroyOptions, tfexec.Var(v))
+	}
+	for _, f := range varFiles {
+		destroyOptions = append(destroyOptions, tfexec.VarFile(f))
+	}
+
+	if dryrun.Enabled() {
+		planOptions := []tfexec.PlanOption{tfexec.Destroy(true)}
+		for _, v := range vars {
+			planOptions = append(planOptions, tfexec.Var(v))
+		}
+		for _, f := range varFiles {
+			planOptions = append(planOptions, tfexec.VarFile(f))
+		}
+		tf.SetStdout(output.Writer())
+		tf.SetStderr(os.Stderr)
+
+		var hasChanges bool
+		err = runInterruptible(ctx, func(runCtx context.Context) error {
+			hasChanges, err = tf.Plan(runCtx, planOptions...)
+			return err
+		})
+		if err != nil {
+			pterm.Error.Printf("Terraform destroy plan failed: %v\n", err)
+			return err
+		}
+		RecordPlan("destroy", hasChanges)
+		return nil
+	}
+
 	pterm.Info.Println("Destroying Terraform resources...")
 	spinner, _ := pterm.DefaultSpinner.Start("Running terraform destroy")
-	err = tf.Destroy(context.Background())
+	err = runInterruptible(ctx, func(runCtx context.Context) error {
+		return tf.Destroy(runCtx, destroyOptions...)
+	})
 	if err != nil {
 		spinner.Fail("Terraform destroy failed")
 		pterm.Error.Printf("Terraform destroy failed: %v\n", err)
@@ -171,74 +278,120 @@ func Destroy() error {
 	return nil
 }
 
-// DetectDrift checks for drift between the Terraform state and the actual infrastructure
-func DetectDrift() error {
+// RecordPlan registers the apply or destroy a dry run planned but did not perform.
+func RecordPlan(kind string, hasChanges bool) {
+	dir, _ := os.Getwd()
+	if hasChanges {
+		dryrun.Record("terraform", kind, dir, "plan has changes, see the plan output above")
+	} else {
+		pterm.Info.Printf("Terraform %s would make no changes.\n", kind)
+	}
+}
+
+// DriftChange is a resource whose real state differs from the Terraform state
+type DriftChange struct {
+	Address string   `json:"address"`
+	Actions []string `json:"actions"`
+}
+
+// DetectDrift checks for drift between the Terraform state and the actual infrastructure.
+// The plan it runs is written to a temporary file, never to the working directory.
+func DetectDrift(ctx context.Context) ([]DriftChange, error) {
 	tf, err := getTerraform()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	file, err := os.CreateTemp("", "smurf-drift-*.plan")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the drift plan file: %w", err)
 	}
+	file.Close()
+	planFile := file.Name()
+	defer os.Remove(planFile)
 
-	planFile := "drift.plan"
 	pterm.Info.Println("Checking for drift...")
 	spinner, _ := pterm.DefaultSpinner.Start("Running terraform plan for drift detection")
-	_, err = tf.Plan(context.Background(), tfexec.Out(planFile), tfexec.Refresh(true))
+	err = runInterruptible(ctx, func(runCtx context.Context) error {
+		_, err := tf.Plan(runCtx, tfexec.Out(planFile), tfexec.Refresh(true))
+		return err
+	})
 
 	if err != nil {
 		spinner.Fail("Terraform plan for drift detection failed")
 		pterm.Error.Printf("Terraform plan for drift detection failed: %v\n", err)
-		return err
+		return nil, err
 	}
 	spinner.Success("Terraform drift detection plan completed")
 
-	plan, err := tf.ShowPlanFile(context.Background(), planFile)
+	plan, err := tf.ShowPlanFile(ctx, planFile)
 	if err != nil {
 		pterm.Error.Printf("Error showing plan file: %v\n", err)
-		return err
+		return nil, err
 	}
 
+	changes := []DriftChange{}
 	if len(plan.ResourceChanges) > 0 {
 		pterm.Warning.Println("Drift detected:")
 		for _, change := range plan.ResourceChanges {
 			pterm.Printf("- %s: %s\n", change.Address, change.Change.Actions)
+			actions := make([]string, 0, len(change.Change.Actions))
+			for _, action := range change.Change.Actions {
+				actions = append(actions, string(action))
+			}
+			changes = append(changes, DriftChange{Address: change.Address, Actions: actions})
 		}
 	} else {
 		pterm.Success.Println("No drift detected.")
 	}
 
-	return nil
+	return changes, nil
+}
+
+// OutputValue is the machine-readable view of a Terraform output; sensitive values are withheld
+type OutputValue struct {
+	Sensitive bool            `json:"sensitive"`
+	Value     json.RawMessage `json:"value,omitempty"`
 }
 
-// Output displays the outputs defined in the Terraform configuration
-func Output() error {
+// Output displays the outputs defined in the Terraform configuration and returns them
+func Output(ctx context.Context) (map[string]OutputValue, error) {
 	tf, err := getTerraform()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Set stdout and stderr to capture any messages
-	tf.SetStdout(os.Stdout)
+	tf.SetStdout(output.Writer())
 	tf.SetStderr(os.Stderr)
 
 	// Refresh the state to ensure outputs are up to date
-	pterm.Info.Println("Refreshing Terraform state...")
-	spinner, _ := pterm.DefaultSpinner.Start("Running terraform refresh")
-	err = tf.Refresh(context.Background())
-	if err != nil {
-		spinner.Fail("Error refreshing Terraform state")
-		pterm.Error.Printf("Error refreshing Terraform state: %v\n", err)
-		return err
+	if dryrun.Enabled() {
+		dryrun.Record("terraform", "refresh", "state", "outputs below are read from the current state")
+	} else {
+		pterm.Info.Println("Refreshing Terraform state...")
+		spinner, _ := pterm.DefaultSpinner.Start("Running terraform refresh")
+		err = runInterruptible(ctx, func(runCtx context.Context) error {
+			return tf.Refresh(runCtx)
+		})
+		if err != nil {
+			spinner.Fail("Error refreshing Terraform state")
+			pterm.Error.Printf("Error refreshing Terraform state: %v\n", err)
+			return nil, err
+		}
+		spinner.Success("Terraform state refreshed successfully.")
 	}
-	spinner.Success("Terraform state refreshed successfully.")
 
-	outputs, err := tf.Output(context.Background())
+	outputs, err := tf.Output(ctx)
 	if err != nil {
 		pterm.Error.Printf("Error getting Terraform outputs: %v\n", err)
-		return err
+		return nil, err
 	}
 
+	values := make(map[string]OutputValue, len(outputs))
 	if len(outputs) == 0 {
 		pterm.Info.Println("No outputs found.")
-		return nil
+		return values, nil
 	}
 
 	// Create green color printer
@@ -247,26 +400,62 @@ func Output() error {
 	pterm.Info.Println("Terraform outputs:")
 	for key, value := range outputs {
 		if value.Sensitive {
-			fmt.Println(green("%s: [sensitive value hidden]", key))
+			fmt.Fprintln(output.Writer(), green("%s: [sensitive value hidden]", key))
+			values[key] = OutputValue{Sensitive: true}
 		} else {
-			fmt.Println(green("%s: %v", key, value.Value))
+			fmt.Fprintln(output.Writer(), green("%s: %v", key, string(value.Value)))
+			values[key] = OutputValue{Value: value.Value}
 		}
 	}
 
-	return nil
+	return values, nil
+}
+
+// ReadOutputs returns the outputs recorded in the state of the configuration in dir
+// without refreshing it. Sensitive values are included, so callers must not print them.
+func ReadOutputs(ctx context.Context, dir string) (map[string]OutputValue, error) {
+	tf, err := getTerraformIn(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	outputs, err := tf.Output(ctx)
+	if err != nil {
+		pterm.Error.Printf("Error reading Terraform outputs from %s: %v\n", dir, err)
+		return nil, err
+	}
+
+	values := make(map[string]OutputValue, len(outputs))
+	for key, value := range outputs {
+		values[key] = OutputValue{Sensitive: value.Sensitive, Value: value.Value}
+	}
+	return values, nil
 }
 
 // Format applies a canonical format to Terraform configuration files
-func Format() error {
+func Format(ctx context.Context) error {
 	tf, err := getTerraform()
 	if err != nil {
 		return err
 	}
 
+	if dryrun.Enabled() {
+		// -check exits with status 3 and lists the files when any would be rewritten
+		out, err := exec.CommandContext(ctx, tf.ExecPath(), "fmt", "-check", "-list=true").Output()
+		if exitErr, ok := err.(*exec.ExitError); err != nil && !(ok && exitErr.ExitCode() == 3) {
+			pterm.Error.Printf("Terraform format check failed: %v\n", err)
+			return err
+		}
+		for _, file := range strings.Fields(string(out)) {
+			dryrun.Record("terraform", "format", file, "")
+		}
+		return nil
+	}
+
 	pterm.Info.Println("Formatting Terraform configuration files...")
 	spinner, _ := pterm.DefaultSpinner.Start("Running terraform fmt")
 
-	cmd := exec.Command(tf.ExecPath(), "fmt")
+	cmd := exec.CommandContext(ctx, tf.ExecPath(), "fmt")
 
 	cmd.Dir = "." // This formats files in the current directory
 